@@ -87,8 +87,8 @@ func setMiddleWareSkipper(s *micro.Server) {
 		return !strings.Contains(uri, "/arc")
 	}
 
-	// timeout middleware
+	// timeout middleware, streaming downloads are not limited
 	s.APITimeOutSkipper = func(uri string) bool {
-		return !strings.Contains(uri, "/history")
+		return !strings.Contains(uri, "/history") || strings.Contains(uri, "/arc/stream")
 	}
 }
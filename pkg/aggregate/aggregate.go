@@ -0,0 +1,284 @@
+package aggregate
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Function 聚合函数
+type Function string
+
+const (
+	// FunctionAvg 平均值
+	FunctionAvg Function = "avg"
+	// FunctionSum 求和
+	FunctionSum Function = "sum"
+	// FunctionMin 最小值
+	FunctionMin Function = "min"
+	// FunctionMax 最大值
+	FunctionMax Function = "max"
+	// FunctionFirst 窗口内第一个值
+	FunctionFirst Function = "first"
+	// FunctionLast 窗口内最后一个值
+	FunctionLast Function = "last"
+)
+
+// FillMode 窗口数据缺失时的填充模式
+type FillMode string
+
+const (
+	// FillNone 不输出缺失窗口
+	FillNone FillMode = "NONE"
+	// FillNull 输出空值
+	FillNull FillMode = "NULL"
+	// FillValue 输出指定值
+	FillValue FillMode = "VALUE"
+	// FillPrev 使用前一个窗口的值
+	FillPrev FillMode = "PREV"
+	// FillNext 使用后一个窗口的值
+	FillNext FillMode = "NEXT"
+	// FillLinear 使用前后窗口的线性插值
+	FillLinear FillMode = "LINEAR"
+)
+
+const (
+	// MinInterval 最短聚合窗口
+	MinInterval = 10 * time.Millisecond
+	// MaxWindows 单个序列最大窗口数
+	MaxWindows = 100000
+)
+
+// Fill 填充模式及VALUE模式的填充值
+type Fill struct {
+	Mode  FillMode
+	Value float64
+}
+
+// Point 聚合结果
+type Point struct {
+	Time  time.Time
+	Value *float64 // FillNull 时为 nil
+}
+
+// ParseFunction avg,sum,min,max,first,last
+func ParseFunction(s string) (Function, error) {
+	fn := Function(strings.ToLower(s))
+	switch fn {
+	case FunctionAvg, FunctionSum, FunctionMin, FunctionMax, FunctionFirst, FunctionLast:
+		return fn, nil
+	}
+	return "", fmt.Errorf("invalid function %s", s)
+}
+
+// ParseInterval 支持 10a(毫秒) 100ms 1s 1m 1h, 纯数字按毫秒处理
+func ParseInterval(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	switch {
+	case s == "":
+		return 0, fmt.Errorf("interval is empty")
+	case strings.HasSuffix(s, "a"):
+		var ms int64
+		ms, err = strconv.ParseInt(strings.TrimSuffix(s, "a"), 10, 64)
+		d = time.Duration(ms) * time.Millisecond
+	case isDigit(s):
+		var ms int64
+		ms, err = strconv.ParseInt(s, 10, 64)
+		d = time.Duration(ms) * time.Millisecond
+	default:
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("invalid interval %s: %v", s, err)
+	}
+	if d < MinInterval {
+		return 0, fmt.Errorf("interval %s less than %s", s, MinInterval)
+	}
+	return d, nil
+}
+
+// ParseFill NONE,NULL,PREV,NEXT,LINEAR,VALUE:<v>
+func ParseFill(s string) (Fill, error) {
+	if s == "" {
+		return Fill{Mode: FillNone}, nil
+	}
+	mode, value, hasValue := strings.Cut(s, ":")
+	f := Fill{Mode: FillMode(strings.ToUpper(mode))}
+	switch f.Mode {
+	case FillNone, FillNull, FillPrev, FillNext, FillLinear:
+		return f, nil
+	case FillValue:
+		if !hasValue {
+			return f, fmt.Errorf("fill VALUE without value")
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return f, fmt.Errorf("invalid fill value %s", value)
+		}
+		f.Value = v
+		return f, nil
+	}
+	return f, fmt.Errorf("invalid fill %s", s)
+}
+
+// window 窗口聚合状态
+type window struct {
+	count     int64
+	sum       float64
+	min       float64
+	max       float64
+	first     float64
+	firstTime time.Time
+	last      float64
+	lastTime  time.Time
+}
+
+// Aggregator 按固定窗口对采样点降采样, 窗口从from开始对齐
+type Aggregator struct {
+	fn       Function
+	fill     Fill
+	from     time.Time
+	to       time.Time
+	interval time.Duration
+	windows  []window
+}
+
+// New -
+func New(fn Function, from, to time.Time, interval time.Duration, fill Fill) (*Aggregator, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid time range %s - %s", from, to)
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %s", interval)
+	}
+	n := int64((to.Sub(from) + interval - 1) / interval)
+	if n > MaxWindows {
+		return nil, fmt.Errorf("too many windows %d > %d", n, MaxWindows)
+	}
+	return &Aggregator{
+		fn:       fn,
+		fill:     fill,
+		from:     from,
+		to:       to,
+		interval: interval,
+		windows:  make([]window, n),
+	}, nil
+}
+
+// Add 添加采样点, 超出时间范围的点忽略
+func (a *Aggregator) Add(t time.Time, v float64) {
+	if t.Before(a.from) || !t.Before(a.to) {
+		return
+	}
+	w := &a.windows[t.Sub(a.from)/a.interval]
+	if w.count == 0 {
+		w.min, w.max = v, v
+		w.first, w.firstTime = v, t
+		w.last, w.lastTime = v, t
+	} else {
+		w.min = math.Min(w.min, v)
+		w.max = math.Max(w.max, v)
+		if t.Before(w.firstTime) {
+			w.first, w.firstTime = v, t
+		}
+		if !t.Before(w.lastTime) {
+			w.last, w.lastTime = v, t
+		}
+	}
+	w.count++
+	w.sum += v
+}
+
+// value 窗口聚合值
+func (a *Aggregator) value(w *window) float64 {
+	switch a.fn {
+	case FunctionSum:
+		return w.sum
+	case FunctionMin:
+		return w.min
+	case FunctionMax:
+		return w.max
+	case FunctionFirst:
+		return w.first
+	case FunctionLast:
+		return w.last
+	default:
+		return w.sum / float64(w.count)
+	}
+}
+
+// Points 输出聚合结果, 时间为窗口起始时间
+func (a *Aggregator) Points() []Point {
+	n := len(a.windows)
+	values := make([]*float64, n)
+	for i := range a.windows {
+		if a.windows[i].count > 0 {
+			v := a.value(&a.windows[i])
+			values[i] = &v
+		}
+	}
+
+	// 前后最近的非空窗口
+	prev := make([]int, n)
+	next := make([]int, n)
+	last := -1
+	for i := 0; i < n; i++ {
+		if values[i] != nil {
+			last = i
+		}
+		prev[i] = last
+	}
+	last = -1
+	for i := n - 1; i >= 0; i-- {
+		if values[i] != nil {
+			last = i
+		}
+		next[i] = last
+	}
+
+	points := make([]Point, 0, n)
+	for i := 0; i < n; i++ {
+		p := Point{Time: a.from.Add(time.Duration(i) * a.interval)}
+		if values[i] != nil {
+			p.Value = values[i]
+			points = append(points, p)
+			continue
+		}
+
+		switch a.fill.Mode {
+		case FillNone:
+			continue
+		case FillValue:
+			v := a.fill.Value
+			p.Value = &v
+		case FillPrev:
+			if prev[i] >= 0 {
+				p.Value = values[prev[i]]
+			}
+		case FillNext:
+			if next[i] >= 0 {
+				p.Value = values[next[i]]
+			}
+		case FillLinear:
+			if prev[i] >= 0 && next[i] >= 0 {
+				p0, p1 := prev[i], next[i]
+				v := *values[p0] + (*values[p1]-*values[p0])*float64(i-p0)/float64(p1-p0)
+				p.Value = &v
+			}
+		}
+		points = append(points, p)
+	}
+	return points
+}
+
+func isDigit(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
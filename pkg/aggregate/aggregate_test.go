@@ -0,0 +1,118 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func CaseParse(t *testing.T) {
+	Convey("ParseInterval", t, func() {
+		d, err := ParseInterval("10a")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 10*time.Millisecond)
+
+		d, err = ParseInterval("1s")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, time.Second)
+
+		_, err = ParseInterval("1a")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("ParseFill", t, func() {
+		f, err := ParseFill("prev")
+		So(err, ShouldBeNil)
+		So(f.Mode, ShouldEqual, FillPrev)
+
+		f, err = ParseFill("VALUE:1.5")
+		So(err, ShouldBeNil)
+		So(f.Value, ShouldEqual, 1.5)
+
+		_, err = ParseFill("VALUE")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("ParseFunction", t, func() {
+		_, err := ParseFunction("AVG")
+		So(err, ShouldBeNil)
+		_, err = ParseFunction("median")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func CaseAggregator(t *testing.T) {
+	from := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	to := from.Add(4 * time.Second)
+
+	// window 0: 1,3  window 1: empty  window 2: empty  window 3: 7
+	add := func(a *Aggregator) {
+		a.Add(from, 1)
+		a.Add(from.Add(500*time.Millisecond), 3)
+		a.Add(from.Add(3*time.Second), 7)
+		a.Add(to, 100)
+	}
+
+	Convey("Aggregator", t, func() {
+		Convey("avg none", func() {
+			a, err := New(FunctionAvg, from, to, time.Second, Fill{Mode: FillNone})
+			So(err, ShouldBeNil)
+			add(a)
+			points := a.Points()
+			So(len(points), ShouldEqual, 2)
+			So(*points[0].Value, ShouldEqual, 2)
+			So(*points[1].Value, ShouldEqual, 7)
+			So(points[1].Time.Equal(from.Add(3*time.Second)), ShouldBeTrue)
+		})
+		Convey("max null", func() {
+			a, _ := New(FunctionMax, from, to, time.Second, Fill{Mode: FillNull})
+			add(a)
+			points := a.Points()
+			So(len(points), ShouldEqual, 4)
+			So(*points[0].Value, ShouldEqual, 3)
+			So(points[1].Value, ShouldBeNil)
+		})
+		Convey("first prev", func() {
+			a, _ := New(FunctionFirst, from, to, time.Second, Fill{Mode: FillPrev})
+			add(a)
+			points := a.Points()
+			So(*points[2].Value, ShouldEqual, 1)
+		})
+		Convey("last linear", func() {
+			a, _ := New(FunctionLast, from, to, time.Second, Fill{Mode: FillLinear})
+			add(a)
+			points := a.Points()
+			So(*points[1].Value, ShouldAlmostEqual, 3+4.0/3)
+			So(*points[2].Value, ShouldAlmostEqual, 3+8.0/3)
+		})
+		Convey("too many windows", func() {
+			_, err := New(FunctionSum, from, from.Add(time.Hour), time.Millisecond, Fill{})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func CaseSamples(t *testing.T) {
+	Convey("Samples", t, func() {
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		profile := config.SensorProfile{SampleRate: 2, Channel: 2, SampleWidth: 2}
+		// (1, -1) (2, -2)
+		data := []byte{0x01, 0x00, 0xFF, 0xFF, 0x02, 0x00, 0xFE, 0xFF}
+		var times []time.Time
+		var values []float64
+		Samples(data, profile, start, func(t time.Time, v float64) {
+			times = append(times, t)
+			values = append(values, v)
+		})
+		So(values, ShouldResemble, []float64{1, -1, 2, -2})
+		So(times[2].Equal(start.Add(500*time.Millisecond)), ShouldBeTrue)
+	})
+}
+
+func TestAggregate(t *testing.T) {
+	CaseParse(t)
+	CaseAggregator(t)
+	CaseSamples(t)
+}
@@ -0,0 +1,44 @@
+package aggregate
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+)
+
+// Samples 解码PCM数据(小端), start为第一个采样点时间, 每个通道的采样值依次回调
+func Samples(data []byte, profile config.SensorProfile, start time.Time, fn func(t time.Time, v float64)) {
+	width := profile.SampleWidth
+	blockAlign := profile.BlockAlign()
+	if blockAlign <= 0 || profile.SampleRate <= 0 {
+		return
+	}
+	period := float64(time.Second) / float64(profile.SampleRate)
+
+	for i := 0; i+blockAlign <= len(data); i += blockAlign {
+		t := start.Add(time.Duration(float64(i/blockAlign) * period))
+		for c := 0; c < profile.Channel; c++ {
+			fn(t, sampleValue(data[i+c*width:i+(c+1)*width]))
+		}
+	}
+}
+
+// sampleValue 8bit无符号, 16/24/32bit有符号
+func sampleValue(b []byte) float64 {
+	switch len(b) {
+	case 1:
+		return float64(int(b[0]) - 128)
+	case 2:
+		return float64(int16(binary.LittleEndian.Uint16(b)))
+	case 3:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
+		if v&0x800000 != 0 {
+			v |= ^0xFFFFFF
+		}
+		return float64(v)
+	case 4:
+		return float64(int32(binary.LittleEndian.Uint32(b)))
+	}
+	return 0
+}
@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/aggregate"
+	"github.com/kiga-hub/arc-storage/pkg/util"
+	"github.com/kiga-hub/arc/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultAggregateInterval recommended aggregation window
+const defaultAggregateInterval = 100 * time.Millisecond
+
+// getSensorAggregate downsample stored arc samples of several sensors, one series per sensor.
+func (arc *ArcStorage) getSensorAggregate(c echo.Context) error {
+	ids := c.QueryParam("sensorids")
+	if ids == "" {
+		ids = c.QueryParam("sensorid")
+	}
+	var sensorids []string
+	for _, id := range strings.Split(ids, ",") {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if id != "" && !util.IsContainItem(sensorids, id) {
+			sensorids = append(sensorids, id)
+		}
+	}
+
+	filetype := c.QueryParam("type")
+	if filetype == "" {
+		filetype = TypeArc
+	}
+
+	fn, err := aggregate.ParseFunction(c.QueryParam("function"))
+	if err != nil || len(sensorids) < 1 || filetype != TypeArc {
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest)},
+		)
+	}
+
+	interval := defaultAggregateInterval
+	if c.QueryParam("interval") != "" {
+		if interval, err = aggregate.ParseInterval(c.QueryParam("interval")); err != nil {
+			return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+				Code: http.StatusBadRequest,
+				Msg:  err.Error()},
+			)
+		}
+	}
+
+	fill, err := aggregate.ParseFill(c.QueryParam("fill"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  err.Error()},
+		)
+	}
+
+	t1, t2, err := parseQueryTimeRange(c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil || !t1.Before(t2) {
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest)},
+		)
+	}
+
+	start := time.Now()
+	defer func() {
+		arc.logger.Infof("aggregate data spend %s\n", time.Since(start).String())
+	}()
+
+	ctx := c.Request().Context()
+	series := []SensorSeries{}
+	found := false
+	for _, sensorid := range sensorids {
+		a, err := aggregate.New(fn, t1, t2, interval, fill)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+				Code: http.StatusBadRequest,
+				Msg:  err.Error()},
+			)
+		}
+		profile := arc.config.Sensor.GetProfile(sensorid)
+
+		end, err := arc.arcFileStore.ReadFrames(ctx, sensorid, filetype, t1, t2, func(t time.Time, data []byte) error {
+			if len(data) > 0 {
+				found = true
+			}
+			aggregate.Samples(data, profile, t, a.Add)
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			arc.logger.Errorw("ReadFrames", "sensorid", sensorid, "err", err)
+		}
+
+		// data not yet persisted is still in the cache
+		from := t1
+		if end.After(t1) {
+			from = end
+		}
+		if tail := arc.searchCacheTail(sensorid, filetype, from, t2); len(tail) > 0 {
+			found = true
+			aggregate.Samples(tail, profile, from, a.Add)
+		}
+
+		points := a.Points()
+		item := SensorSeries{
+			SensorID: sensorid,
+			Data:     make([]SeriesPoint, 0, len(points)),
+			Count:    len(points),
+		}
+		for _, p := range points {
+			item.Data = append(item.Data, SeriesPoint{
+				Time: p.Time.UnixMilli(),
+				Arc:  p.Value,
+			})
+		}
+		series = append(series, item)
+	}
+
+	if !found {
+		return c.JSON(http.StatusNotFound, utils.ResponseV2{
+			Code: http.StatusNotFound,
+			Msg:  http.StatusText(http.StatusNotFound)},
+		)
+	}
+
+	return c.JSON(http.StatusOK, utils.ResponseV2{
+		Code: Success,
+		Msg:  "OK",
+		Data: series},
+	)
+}
@@ -3,6 +3,8 @@ package pkg
 import (
 	"net/http"
 
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+	"github.com/kiga-hub/arc-storage/pkg/deadletter"
 	"github.com/labstack/echo/v4"
 	"github.com/pangpanglabs/echoswagger/v2"
 )
@@ -58,12 +60,14 @@ func (arc *ArcStorage) SetupWeb(root echoswagger.ApiRoot, base, selfServiceName
 		AddParamQuery("", "function", "可选项,聚合查询", false).
 		AddParamQuery("", "interval", "可选项,聚合时间段的窗口", false).
 		AddParamQuery("", "fill", "可选项,数据填充格式", false).
+		AddParamQuery("", "sensorid", "单个ID,不使用聚合查询时返回文件列表", false).
+		AddParamQuery("Arc", "type", "数据类型, 见/arc/types", false).
 		AddResponse(http.StatusOK, `
 		- 可选项说明: SQL查询使用函数(聚合函数、选择函数、计算函数、按窗口切分聚合等)。
 		- 不使用可选项，则输出查询到的所有数据。
 		- function - 单个输出选择函数,推荐first, 参数:avg,sum,min,max,first,last。
 		- interval - 聚合时间段的窗口,interval指定,最短时间间隔10毫秒(10a),推荐100ms。
-		- fill     - 指定某一窗口区间数据缺失的情况下的填充模式,推荐使用PREV,详细查看文档。
+		- fill     - 指定某一窗口区间数据缺失的情况下的填充模式,推荐使用PREV,参数:NONE,NULL,PREV,NEXT,LINEAR,VALUE:<值>。
 		{
 			"code": 0,
 			"msg": "OK",
@@ -107,4 +111,303 @@ func (arc *ArcStorage) SetupWeb(root echoswagger.ApiRoot, base, selfServiceName
 		SetOperationId("arc").
 		SetSummary("Return arc values. Querying from TDEngine")
 
+	g.GET("/arc/data", arc.handlerWrapper(selfServiceName, arc.getSensorData)).
+		AddParamQuery(true, "inside", "inside swarm or not", false).
+		AddParamQuery("", "sensorid", "传感器ID", true).
+		AddParamQuery("Arc", "type", "数据类型, 见/arc/types", true).
+		AddParamQuery(int64(0), "from", "起始时间", true).
+		AddParamQuery(int64(0), "to", "终止时间", true).
+		AddResponse(http.StatusOK, `
+		- 返回时间段内的原始arc数据, Content-Type: application/octet-stream
+		- 尚未落盘的数据从实时缓存中补齐
+		`, []byte{}, nil).
+		AddResponse(http.StatusMultipleChoices, `
+		{
+			"code": 300,
+			"msg": "Multiple Choices"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusBadRequest, `
+		{
+			"code": 400,
+			"msg": "Bad Request"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusNotFound, `
+		{
+			"code": 404,
+			"msg": "Not Found"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusGatewayTimeout, `
+		{
+			"code": 504,
+			"msg": "Gateway Timeout"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusTooManyRequests, `
+		{
+			"code": 429,
+			"msg": "Too Many Requests:"+ id
+		}
+		`, nil, nil).
+		SetOperationId("arcdata").
+		SetSummary("Return raw arc data of the time range")
+
+	g.GET("/arc/wav", arc.handlerWrapper(selfServiceName, arc.getSensorWav)).
+		AddParamQuery(true, "inside", "inside swarm or not", false).
+		AddParamQuery("", "sensorid", "传感器ID", true).
+		AddParamQuery("Arc", "type", "数据类型", true).
+		AddParamQuery(int64(0), "from", "起始时间", true).
+		AddParamQuery(int64(0), "to", "终止时间", true).
+		AddResponse(http.StatusOK, `
+		- 时间段内的arc数据导出为WAV文件, Content-Type: audio/wav
+		- 采样率、位宽、通道数取自传感器配置(sensor.profiles)
+		`, []byte{}, nil).
+		AddResponse(http.StatusBadRequest, `
+		{
+			"code": 400,
+			"msg": "Bad Request"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusNotFound, `
+		{
+			"code": 404,
+			"msg": "Not Found"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusGatewayTimeout, `
+		{
+			"code": 504,
+			"msg": "Gateway Timeout"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusTooManyRequests, `
+		{
+			"code": 429,
+			"msg": "Too Many Requests:"+ id
+		}
+		`, nil, nil).
+		SetOperationId("arcwav").
+		SetSummary("Export arc data of the time range as WAV")
+
+	g.GET("/arc/stream", arc.handlerWrapper(selfServiceName, arc.getSensorStream)).
+		AddParamQuery(true, "inside", "inside swarm or not", false).
+		AddParamQuery("", "sensorid", "传感器ID", true).
+		AddParamQuery("Arc", "type", "数据类型, 见/arc/types", true).
+		AddParamQuery(int64(0), "from", "起始时间", true).
+		AddParamQuery(int64(0), "to", "终止时间", true).
+		AddResponse(http.StatusOK, `
+		- 分块传输(Transfer-Encoding: chunked)时间段内的原始arc数据, 不限制查询时长
+		- 尚未落盘的数据从实时缓存中补齐
+		- Content-Type: application/octet-stream
+		`, []byte{}, nil).
+		AddResponse(http.StatusBadRequest, `
+		{
+			"code": 400,
+			"msg": "Bad Request"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusNotFound, `
+		{
+			"code": 404,
+			"msg": "Not Found"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusTooManyRequests, `
+		{
+			"code": 429,
+			"msg": "Too Many Requests:"+ id
+		}
+		`, nil, nil).
+		SetOperationId("arcstream").
+		SetSummary("Stream raw arc data of the time range")
+
+	g.POST("/arc/upload", arc.handlerWrapper(selfServiceName, arc.postSensorFrames)).
+		AddParamBody([]byte{}, "body", "连续的协议帧, 或multipart/form-data的多个批次, 帧格式由decoder.http配置: arc, protobuf", true).
+		AddResponse(http.StatusOK, `
+		- 按传感器ID分配到解码队列, 解码完成后返回
+		- 被拒绝的数据写入死信目录, reason: short,head,size,truncated,end,crc,decode
+		{
+			"code": 0,
+			"msg": "OK",
+			"data": {
+				"bytes": 2048,
+				"accepted": 3,
+				"rejected": 1,
+				"reasons": {
+					"crc": 1
+				}
+			}
+		}
+		`, UploadResult{}, nil).
+		AddResponse(http.StatusBadRequest, `
+		{
+			"code": 400,
+			"msg": "Bad Request"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusRequestEntityTooLarge, `
+		{
+			"message": "Request Entity Too Large"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusServiceUnavailable, `
+		{
+			"code": 503,
+			"msg": "Service Unavailable"
+		}
+		`, nil, nil).
+		SetOperationId("arcupload").
+		SetSummary("Upload concatenated protocol frames")
+
+	g.GET("/arc/types", arc.handlerWrapper(selfServiceName, arc.getSegmentTypes)).
+		AddResponse(http.StatusOK, `
+		- 已注册的数据段类型, 每种类型写入 <sensor>/<day>/<dir>/*<ext>, late为迟到帧数据卷
+		{
+			"code": 0,
+			"msg": "OK",
+			"data": [
+				{
+					"stype": 10,
+					"name": "Arc",
+					"dir": "TypeArc",
+					"ext": ".arc",
+					"description": "arc audio",
+					"late": false
+				}
+			]
+		}
+		`, []arc_volume.SegmentType{}, nil).
+		SetOperationId("arctypes").
+		SetSummary("List registered segment types")
+
+	g.GET("/arc/compact", arc.handlerWrapper(selfServiceName, arc.getCompactPlan)).
+		AddResponse(http.StatusOK, `
+		- 合并计划, 不修改文件, compact.interval内返回缓存的计划. 同一天目录内compact.span对齐时间跨度内时间不重叠的相邻数据卷合并为target
+		{
+			"code": 0,
+			"msg": "OK",
+			"data": {
+				"dry_run": true,
+				"groups": [
+					{
+						"sources": [
+							"/data/A00000000000/20240102/TypeArc/A00000000000_Arc_20240102030000000000_20240102030459999000.arc",
+							"/data/A00000000000/20240102/TypeArc/A00000000000_Arc_20240102030500000000_20240102030959999000.arc"
+						],
+						"target": "/data/A00000000000/20240102/TypeArc/A00000000000_Arc_20240102030000000000_20240102030959999000.arc",
+						"size": 2048,
+						"frames": 20
+					}
+				],
+				"volumes": 2,
+				"merged": 0,
+				"bytes": 0,
+				"failed": 0
+			}
+		}
+		`, arc_volume.CompactReport{}, nil).
+		SetOperationId("arccompact").
+		SetSummary("Preview the compaction of small volumes")
+
+	g.GET("/arc/retention", arc.handlerWrapper(selfServiceName, arc.getRetentionPlan)).
+		AddResponse(http.StatusOK, `
+		- 保留策略的删除计划, 不修改文件, retention.interval内返回缓存的计划. kind: day,volume; reason: max_age,max_sensor_bytes,max_total_bytes; rule为生效的规则名称
+		{
+			"code": 0,
+			"msg": "OK",
+			"data": {
+				"dry_run": true,
+				"deletions": [
+					{
+						"sensorid": "A00000000000",
+						"kind": "day",
+						"path": "/data/A00000000000/20240102",
+						"size": 1073741824,
+						"from": "2024-01-02T00:00:00Z",
+						"to": "2024-01-02T23:59:59.999Z",
+						"reason": "max_age",
+						"rule": "test"
+					}
+				],
+				"total_bytes": 10737418240,
+				"bytes": 0,
+				"failed": 0
+			}
+		}
+		`, arc_volume.RetentionReport{}, nil).
+		SetOperationId("arcretention").
+		SetSummary("Preview the data removed by the retention policy")
+
+	g.GET("/deadletter", arc.handlerWrapper(selfServiceName, arc.getDeadLetters)).
+		AddResponse(http.StatusOK, `
+		- 解码失败被拒绝的原始数据列表, reason: short,head,size,truncated,end,crc,decode
+		{
+			"code": 0,
+			"msg": "OK",
+			"data": [
+				{
+					"id": "20240102030405123456-000001",
+					"reason": "crc",
+					"detail": "crc:1234 want:5678",
+					"sensorid": "A00000000000",
+					"offset": 0,
+					"size": 1024,
+					"time": "2024-01-02T03:04:05.123456Z"
+				}
+			]
+		}
+		`, []deadletter.Entry{}, nil).
+		AddResponse(http.StatusNotFound, `
+		{
+			"code": 404,
+			"msg": "dead letter disabled"
+		}
+		`, nil, nil).
+		SetOperationId("deadletters").
+		SetSummary("List bytes rejected by decode")
+
+	g.GET("/deadletter/:id", arc.handlerWrapper(selfServiceName, arc.getDeadLetter)).
+		AddParamPath("", "id", "死信ID").
+		AddResponse(http.StatusOK, `
+		- 被拒绝的原始数据, Content-Type: application/octet-stream
+		- X-Reject-Reason: 拒绝原因
+		`, []byte{}, nil).
+		AddResponse(http.StatusNotFound, `
+		{
+			"code": 404,
+			"msg": "Not Found"
+		}
+		`, nil, nil).
+		SetOperationId("deadletter").
+		SetSummary("Download the raw bytes of a dead letter")
+
+	g.POST("/deadletter/:id/reinject", arc.handlerWrapper(selfServiceName, arc.reinjectDeadLetter)).
+		AddParamPath("", "id", "死信ID").
+		AddResponse(http.StatusOK, `
+		- 原始数据重新进入解码流程, 死信被删除, 仍然无效的数据作为新的死信保存
+		{
+			"code": 0,
+			"msg": "OK",
+			"data": {
+				"id": "20240102030405123456-000001",
+				"reason": "crc"
+			}
+		}
+		`, deadletter.Entry{}, nil).
+		AddResponse(http.StatusNotFound, `
+		{
+			"code": 404,
+			"msg": "Not Found"
+		}
+		`, nil, nil).
+		AddResponse(http.StatusServiceUnavailable, `
+		{
+			"code": 503,
+			"msg": "Service Unavailable"
+		}
+		`, nil, nil).
+		SetOperationId("deadletterreinject").
+		SetSummary("Re-inject a dead letter into the decode pipeline")
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"time"
 
+	"github.com/kiga-hub/arc-storage/pkg/config"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 )
@@ -71,12 +73,44 @@ var DefaultOptions = Options{
 	Reuse:                true,
 }
 
-// Dial return a grpc connection with defined configurations.
+// NewOptions return DefaultOptions dialing by the grpc config. with tls enabled, the server is verified against
+// CAFile & ServerName, CertFile & KeyFile are sent as the client certificate for mTLS.
+func NewOptions(conf *config.GRPCConfig) (Options, error) {
+	opts := DefaultOptions
+	if !conf.TLS {
+		return opts, nil
+	}
+	creds, err := ClientCredentials(TLSOptions{
+		CertFile:       conf.CertFile,
+		KeyFile:        conf.KeyFile,
+		CAFile:         conf.CAFile,
+		ServerName:     conf.ServerName,
+		ReloadInterval: time.Duration(conf.ReloadInterval) * time.Second,
+	})
+	if err != nil {
+		return opts, err
+	}
+	opts.Dial = NewDial(creds)
+	return opts, nil
+}
+
+// Dial return a plaintext grpc connection with defined configurations.
 func Dial(address string) (*grpc.ClientConn, error) {
+	return dial(address, insecure.NewCredentials())
+}
+
+// NewDial return a Dial func using the transport credentials, e.g. ClientCredentials for TLS.
+func NewDial(creds credentials.TransportCredentials) func(address string) (*grpc.ClientConn, error) {
+	return func(address string) (*grpc.ClientConn, error) {
+		return dial(address, creds)
+	}
+}
+
+func dial(address string, creds credentials.TransportCredentials) (*grpc.ClientConn, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), DialTimeout)
 	defer cancel()
 	return grpc.DialContext(ctx, address,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithTransportCredentials(creds),
 		// grpc.WithBackoffMaxDelay(BackoffMaxDelay),
 		grpc.WithInitialWindowSize(InitialWindowSize),
 		grpc.WithInitialConnWindowSize(InitialConnWindowSize),
@@ -0,0 +1,209 @@
+package arc_grpc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// DefaultReloadInterval 证书文件检查间隔
+const DefaultReloadInterval = 10 * time.Second
+
+// TLSOptions are params for grpc transport security.
+type TLSOptions struct {
+	// CertFile & KeyFile certificate of the server, or the client certificate for mTLS.
+	CertFile string
+	KeyFile  string
+
+	// CAFile verifies the peer. the server verifies client certificates, the client verifies the server.
+	// the client uses system roots when empty.
+	CAFile string
+
+	// ClientAuth the server requires and verifies client certificates against CAFile.
+	ClientAuth bool
+
+	// ServerName overrides the name used by the client to verify the server certificate.
+	ServerName string
+
+	// ReloadInterval files are checked at most once per interval during handshakes and reloaded on change.
+	ReloadInterval time.Duration
+}
+
+// certReloader loads certificate & CA files and reloads them when they change.
+type certReloader struct {
+	mu        sync.RWMutex
+	opts      TLSOptions
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	stamp     string // mtime & size of the files
+	lastCheck time.Time
+}
+
+func newCertReloader(opts TLSOptions) (*certReloader, error) {
+	if opts.ReloadInterval <= 0 {
+		opts.ReloadInterval = DefaultReloadInterval
+	}
+	r := &certReloader{opts: opts}
+	stamp, err := r.fileStamp()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(stamp); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// fileStamp -
+func (r *certReloader) fileStamp() (string, error) {
+	stamp := ""
+	for _, file := range []string{r.opts.CertFile, r.opts.KeyFile, r.opts.CAFile} {
+		if file == "" {
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			return "", err
+		}
+		stamp += fmt.Sprintf("%d/%d;", fi.ModTime().UnixNano(), fi.Size())
+	}
+	return stamp, nil
+}
+
+// load -
+func (r *certReloader) load(stamp string) error {
+	var cert *tls.Certificate
+	if r.opts.CertFile != "" || r.opts.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+		if err != nil {
+			return fmt.Errorf("LoadX509KeyPair: %v", err)
+		}
+		cert = &c
+	}
+
+	var pool *x509.CertPool
+	if r.opts.CAFile != "" {
+		pem, err := os.ReadFile(r.opts.CAFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", r.opts.CAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert, r.pool, r.stamp = cert, pool, stamp
+	r.lastCheck = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// maybeReload reloads the files when they changed. the previous certificates are kept if reloading fails,
+// e.g. the cert file has been replaced but the key file not yet.
+func (r *certReloader) maybeReload() {
+	r.mu.RLock()
+	due := time.Since(r.lastCheck) >= r.opts.ReloadInterval
+	stamp := r.stamp
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	current, err := r.fileStamp()
+	if err == nil && current != stamp {
+		err = r.load(current)
+	}
+	if err != nil || current == stamp {
+		r.mu.Lock()
+		r.lastCheck = time.Now()
+		r.mu.Unlock()
+	}
+}
+
+func (r *certReloader) get() (*tls.Certificate, *x509.CertPool) {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, r.pool
+}
+
+// ServerCredentials return server transport credentials. certificates are reloaded on file change.
+func ServerCredentials(opts TLSOptions) (credentials.TransportCredentials, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("tls: cert and key file required")
+	}
+	if opts.ClientAuth && opts.CAFile == "" {
+		return nil, errors.New("tls: ca file required to verify client certificates")
+	}
+	r, err := newCertReloader(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool := r.get()
+			c := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+				NextProtos:   []string{"h2"},
+			}
+			if opts.ClientAuth {
+				c.ClientAuth = tls.RequireAndVerifyClientCert
+				c.ClientCAs = pool
+			}
+			return c, nil
+		},
+	}), nil
+}
+
+// ClientCredentials return client transport credentials. CertFile & KeyFile are optional and sent for mTLS.
+// certificates and CA are reloaded on file change.
+func ClientCredentials(opts TLSOptions) (credentials.TransportCredentials, error) {
+	r, err := newCertReloader(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: opts.ServerName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := r.get()
+			if cert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+	}
+	if opts.CAFile != "" {
+		// the CA may be rotated, verify against the current pool instead of a fixed RootCAs.
+		c.InsecureSkipVerify = true
+		c.VerifyConnection = func(cs tls.ConnectionState) error {
+			_, pool := r.get()
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("tls: no server certificate")
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range cs.PeerCertificates[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+				DNSName:       cs.ServerName,
+				Roots:         pool,
+				Intermediates: intermediates,
+			})
+			return err
+		}
+	}
+	return credentials.NewTLS(c), nil
+}
@@ -0,0 +1,223 @@
+package arc_grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// writeCert 签发证书并写入dir, ca为空时生成自签名CA
+func writeCert(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         isCA,
+
+		BasicConstraintsValid: true,
+	}
+	parent, parentKey := tmpl, key
+	if ca != nil {
+		parent, parentKey = ca, caKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func serveTLS(t *testing.T, creds credentials.TransportCredentials) (*bufconn.Listener, func()) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.Creds(creds))
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(lis)
+	return lis, server.Stop
+}
+
+func checkHealth(lis *bufconn.Listener, creds credentials.TransportCredentials) error {
+	conn, err := grpc.Dial("localhost",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+func TestTLS(t *testing.T) {
+	CaseMutualTLS(t)
+	CaseCertReload(t)
+	CaseNewOptions(t)
+}
+
+func CaseMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", nil, nil, true)
+	writeCert(t, dir, "server", ca, caKey, false)
+	writeCert(t, dir, "client", ca, caKey, false)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	Convey("MutualTLS", t, func() {
+		serverCreds, err := ServerCredentials(TLSOptions{
+			CertFile:   path("server.crt"),
+			KeyFile:    path("server.key"),
+			CAFile:     path("ca.crt"),
+			ClientAuth: true,
+		})
+		So(err, ShouldBeNil)
+		lis, stop := serveTLS(t, serverCreds)
+		defer stop()
+
+		clientCreds, err := ClientCredentials(TLSOptions{
+			CertFile: path("client.crt"),
+			KeyFile:  path("client.key"),
+			CAFile:   path("ca.crt"),
+		})
+		So(err, ShouldBeNil)
+		So(checkHealth(lis, clientCreds), ShouldBeNil)
+
+		// without client certificate
+		anonymous, err := ClientCredentials(TLSOptions{CAFile: path("ca.crt")})
+		So(err, ShouldBeNil)
+		So(checkHealth(lis, anonymous), ShouldNotBeNil)
+
+		_, err = ServerCredentials(TLSOptions{CertFile: path("server.crt"), KeyFile: path("server.key"), ClientAuth: true})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func CaseCertReload(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", nil, nil, true)
+	first, _ := writeCert(t, dir, "server", ca, caKey, false)
+
+	Convey("CertReload", t, func() {
+		r, err := newCertReloader(TLSOptions{
+			CertFile:       filepath.Join(dir, "server.crt"),
+			KeyFile:        filepath.Join(dir, "server.key"),
+			ReloadInterval: time.Millisecond,
+		})
+		So(err, ShouldBeNil)
+		cert, _ := r.get()
+		So(cert.Certificate[0], ShouldResemble, first.Raw)
+
+		time.Sleep(10 * time.Millisecond)
+		second, _ := writeCert(t, dir, "server", ca, caKey, false)
+		time.Sleep(10 * time.Millisecond)
+		cert, _ = r.get()
+		So(cert.Certificate[0], ShouldResemble, second.Raw)
+
+		// a broken file keeps the previous certificate
+		So(os.WriteFile(filepath.Join(dir, "server.key"), []byte("broken"), 0644), ShouldBeNil)
+		time.Sleep(10 * time.Millisecond)
+		cert, _ = r.get()
+		So(cert.Certificate[0], ShouldResemble, second.Raw)
+	})
+}
+
+func CaseNewOptions(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeCert(t, dir, "ca", nil, nil, true)
+	writeCert(t, dir, "server", ca, caKey, false)
+	writeCert(t, dir, "client", ca, caKey, false)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	Convey("NewOptions", t, func() {
+		serverCreds, err := ServerCredentials(TLSOptions{
+			CertFile:   path("server.crt"),
+			KeyFile:    path("server.key"),
+			CAFile:     path("ca.crt"),
+			ClientAuth: true,
+		})
+		So(err, ShouldBeNil)
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		server := grpc.NewServer(grpc.Creds(serverCreds))
+		healthpb.RegisterHealthServer(server, health.NewServer())
+		go server.Serve(lis)
+		defer server.Stop()
+
+		check := func(opts Options) error {
+			conn, err := opts.Dial(lis.Addr().String())
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+			return err
+		}
+
+		opts, err := NewOptions(&config.GRPCConfig{
+			TLS:        true,
+			CertFile:   path("client.crt"),
+			KeyFile:    path("client.key"),
+			CAFile:     path("ca.crt"),
+			ServerName: "localhost",
+		})
+		So(err, ShouldBeNil)
+		So(check(opts), ShouldBeNil)
+
+		// the server name does not match the certificate
+		opts, err = NewOptions(&config.GRPCConfig{
+			TLS:        true,
+			CertFile:   path("client.crt"),
+			KeyFile:    path("client.key"),
+			CAFile:     path("ca.crt"),
+			ServerName: "arc-storage",
+		})
+		So(err, ShouldBeNil)
+		So(check(opts), ShouldNotBeNil)
+
+		// plaintext is refused by the tls server
+		opts, err = NewOptions(&config.GRPCConfig{})
+		So(err, ShouldBeNil)
+		So(check(opts), ShouldNotBeNil)
+
+		_, err = NewOptions(&config.GRPCConfig{TLS: true, CAFile: path("missing.crt")})
+		So(err, ShouldNotBeNil)
+	})
+}
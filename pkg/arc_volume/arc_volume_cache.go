@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/http"
 	"os"
-	"path"
 	"sort"
 	"sync"
 	"time"
@@ -20,25 +19,18 @@ import (
 	"github.com/kiga-hub/arc/utils"
 )
 
-var (
-	// DataTypeMap Data type, description conversion
-	DataTypeMap = map[string]string{
-		"Arc": "TypeArc",
-	}
-)
-
-const (
-	// FileType
-	FileType = ".arc"
-)
-
 // ArcVolumeCache -
 type ArcVolumeCache struct {
 	logger        logging.ILogger
 	config        *config.ArcConfig
 	DataCache     *sync.Map
 	queue         *Queue
+	writer        *volumeWriter
 	exportMetrics *metric.FileCacheMonitor
+	Version       string         // 服务版本, 写入数据卷文件头
+	background    sync.WaitGroup // 后台任务, 如数据卷压缩及去重窗口读取
+	compactPlan   reportCache    // 合并计划预览
+	retentionPlan reportCache    // 保留策略删除计划预览
 }
 
 // ArcVolume -
@@ -51,10 +43,39 @@ type ArcVolume struct {
 	LastTimestamp time.Time
 	MinuteStr     string
 	Type          string
+	Index         []FrameIndex   // 帧时间戳与Buffer偏移
+	Reorder       *ReorderBuffer // 乱序窗口, 尚未追加到Buffer的帧
+	Dedup         *DedupWindow   // 去重窗口, 数据卷切换时保留
+}
+
+// Append 追加帧数据并记录帧索引
+func (bf *ArcVolume) Append(t time.Time, data []byte) {
+	bf.Index = append(bf.Index, FrameIndex{
+		Timestamp: t.UnixMicro(),
+		Offset:    int64(bf.Buffer.Len()),
+	})
+	bf.Buffer.Write(data)
+}
+
+// Frames 按追加顺序遍历Buffer中的帧
+func (bf *ArcVolume) Frames(fn func(t time.Time, data []byte)) {
+	data := bf.Buffer.Bytes()
+	for i, e := range bf.Index {
+		end := frameEnd(bf.Index, int64(len(data)), i)
+		if end < e.Offset {
+			continue
+		}
+		fn(time.UnixMicro(e.Timestamp), data[e.Offset:end])
+	}
 }
 
 // NewArcVolumeCache -
 func NewArcVolumeCache(logger logging.ILogger, config *config.ArcConfig, fileType string) (*ArcVolumeCache, error) {
+	if config.Compress != nil && config.Compress.Enable {
+		if _, err := CodecByName(config.Compress.Codec); err != nil {
+			return nil, err
+		}
+	}
 	// Initialize the metric collection module
 	ct := monitor.NewConsumingTime(fileType)
 	ds := monitor.NewDataSize(fileType)
@@ -64,22 +85,87 @@ func NewArcVolumeCache(logger logging.ILogger, config *config.ArcConfig, fileTyp
 	if err != nil {
 		return nil, err
 	}
+	w, err := newVolumeWriter(config.Work)
+	if err != nil {
+		return nil, err
+	}
+	q := NewQueue(logger, config.Work.ArcVolumeQueueLen, config.Work.ArcVolumeQueueNum)
+	if w.durability == DurabilityGroup {
+		q.setGroupCommit(config.Work.DurabilityGroupBytes, time.Duration(config.Work.DurabilityGroupInterval)*time.Millisecond)
+	}
 	return &ArcVolumeCache{
 		logger:        logger,
 		config:        config,
 		DataCache:     &sync.Map{},
+		writer:        w,
 		exportMetrics: m,
-		queue:         NewQueue(logger, config.Work.ArcVolumeQueueLen, config.Work.ArcVolumeQueueNum),
+		queue:         q,
 	}, nil
 }
 
+// runBackground 每隔interval执行fn, stop关闭后取消ctx并退出. SafeClose等待退出
+func (b *ArcVolumeCache) runBackground(stop <-chan struct{}, interval time.Duration, fn func(ctx context.Context)) {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.background.Add(1)
+	go func() {
+		defer b.background.Done()
+		defer cancel()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			done := make(chan struct{})
+			go func() {
+				fn(ctx)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-stop:
+				cancel()
+				<-done
+				return
+			}
+		}
+	}()
+}
+
+// reportCache 扫描数据目录的预览结果, interval内复用上一次的结果, 同一时间只有一次扫描
+type reportCache struct {
+	mu     sync.Mutex
+	at     time.Time
+	report interface{}
+}
+
+// get 缓存过期时执行scan
+func (c *reportCache) get(interval time.Duration, scan func() interface{}) interface{} {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.report == nil || time.Since(c.at) >= interval {
+		c.report = scan()
+		c.at = time.Now()
+	}
+	return c.report
+}
+
 // SafeClose -close Data process channel
 func (b *ArcVolumeCache) SafeClose() {
+	b.background.Wait()
 	b.queue.Close()
 }
 
-// ReadDataByQueue -
-func (b *ArcVolumeCache) ReadDataByQueue(sensorID, fileType string, t1, t2 time.Time) ([]byte, *utils.ResponseV2) {
+// ReadDataByQueue - 返回数据及磁盘数据覆盖的结束时间
+func (b *ArcVolumeCache) ReadDataByQueue(sensorID, fileType string, t1, t2 time.Time) ([]byte, time.Time, *utils.ResponseV2) {
 
 	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Duration(b.config.Work.ArcVolumeQueueReadTimeoutSeconds)*time.Second)
 	defer cancel()
@@ -88,83 +174,35 @@ func (b *ArcVolumeCache) ReadDataByQueue(sensorID, fileType string, t1, t2 time.
 	readTask := createReadTask(timeoutCtx, sensorID, b, sensorID, fileType, t1, t2)
 	b.queue.DoTask(readTask)
 
-	return readTask.data, readTask.err
+	return readTask.data, readTask.end, readTask.err
 }
-func (b *ArcVolumeCache) readDataLogic(ctx context.Context, sensorID, fileType string, t1, t2 time.Time) ([]byte, *utils.ResponseV2) {
-	// 获取以天为单位的时间范围
-	daysdiffer, count, err := util.GetDaysDiffer(t1.UTC().Format("2006-01-02 15:04:05"), t2.UTC().Format("2006-01-02 15:04:05"))
-	if err != nil {
-		b.logger.Errorw("getDaysDiffer", "err", err)
-		return nil, &utils.ResponseV2{
-			Code: http.StatusBadRequest,
-			Msg:  http.StatusText(http.StatusBadRequest),
-		}
-	}
-
-	b.logger.Debugw("util.GetDaysDiffer", "daysdiffer", daysdiffer, "count", count)
-
+func (b *ArcVolumeCache) readDataLogic(ctx context.Context, sensorID, fileType string, t1, t2 time.Time) ([]byte, time.Time, *utils.ResponseV2) {
+	var coverEnd time.Time
 	start := time.Now()
 	defer func() {
 		b.logger.Debugf("get data spend %s\n", time.Since(start).String())
 	}()
 
 	if isCtxTimeout(ctx) {
-		return nil, &utils.ResponseV2{
+		return nil, coverEnd, &utils.ResponseV2{
 			Code: http.StatusGatewayTimeout,
 			Msg:  http.ErrHandlerTimeout.Error(),
 		}
 	}
 
-	// 获取大文件列表
-	bigfilelists := make(map[string]string, count) // map[createtime]fullpath
-
-	for _, day := range daysdiffer {
-		path := b.config.Work.DataPath + "/" + sensorID + "/" + day + "/" + fileType
-		err := util.GetBigFileLists(path, bigfilelists, "")
-		if err != nil {
-			b.logger.Debugw("GetBigFileList", "path", path, "bigFileList", bigfilelists, "err", err)
-			continue
-		}
-	}
-	b.logger.Debugw("bigfilelists", "bigfilelists", bigfilelists)
-
-	var timestamplist []string
-	var starttimestamps []string
-
-	for key := range bigfilelists {
-		timestamplist = append(timestamplist, key)
-	}
-	sort.Strings(timestamplist)
-	b.logger.Debugw("sort.Strings", "(timestamplist)", timestamplist)
-
-	if isCtxTimeout(ctx) {
-		return nil, &utils.ResponseV2{
-			Code: http.StatusGatewayTimeout,
-			Msg:  http.ErrHandlerTimeout.Error(),
-		}
-	}
-
-	starttimestamps = append(starttimestamps, timestamplist...)
-
-	if isCtxTimeout(ctx) {
-		return nil, &utils.ResponseV2{
-			Code: http.StatusGatewayTimeout,
-			Msg:  http.ErrHandlerTimeout.Error(),
+	// 获取时间段内的数据卷列表
+	filepathlist, err := b.ListVolumes(sensorID, fileType, t1, t2)
+	if err != nil {
+		b.logger.Errorw("ListVolumes", "err", err)
+		return nil, coverEnd, &utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest),
 		}
 	}
-
-	// 文件列表排序
-	sort.Strings(starttimestamps)
-	b.logger.Debugw("sort starttimestamps", "starttimestamps", starttimestamps)
-
-	var filepathlist []string
-	filescount := 0
-
-	// 遍历当前文件夹下得目录
-	filescount = len(starttimestamps)
+	filescount := len(filepathlist)
 
 	if isCtxTimeout(ctx) {
-		return nil, &utils.ResponseV2{
+		return nil, coverEnd, &utils.ResponseV2{
 			Code: http.StatusGatewayTimeout,
 			Msg:  http.ErrHandlerTimeout.Error(),
 		}
@@ -175,7 +213,7 @@ func (b *ArcVolumeCache) readDataLogic(ctx context.Context, sensorID, fileType s
 	// 查询时间大于10分钟，拒绝访问
 	if t2.Sub(t1).Minutes() > 10.0 {
 		b.exportMetrics.SetFileReadValues(sensorID, metric.MonitorSuccess)
-		return nil, &utils.ResponseV2{
+		return nil, coverEnd, &utils.ResponseV2{
 			Code: http.StatusMultipleChoices,
 			Msg:  http.StatusText(http.StatusMultipleChoices),
 		}
@@ -186,54 +224,130 @@ func (b *ArcVolumeCache) readDataLogic(ctx context.Context, sensorID, fileType s
 		// var buffer bytes.Buffer
 		for _, v := range filepathlist {
 			if isCtxTimeout(ctx) {
-				return nil, &utils.ResponseV2{
+				return nil, coverEnd, &utils.ResponseV2{
 					Code: http.StatusGatewayTimeout,
 					Msg:  http.ErrHandlerTimeout.Error(),
 				}
 			}
 
+			start, end, fileEnd, err := volumeRange(v, t1, t2)
 			if err != nil {
-				b.logger.Errorw("getTimeRangeFromFileName", "err", err)
+				b.logger.Debugw("volumeRange", "err", err, "filepath", v)
 				continue
 			}
-
-			fi, err := os.Stat(v)
-			if os.IsNotExist(err) {
-				b.logger.Debugw("file is not exist", "err", err, "filepath", v)
+			if end <= start {
 				continue
 			}
 
-			seekSize := 1024
-			// find data ,write to buffer and return
-			data, err := util.SeekBigFileHeader(v, 0, int64(seekSize))
+			// find data ,write to buffer
+			data, err := readVolumeRange(v, start, end-start)
 			if err != nil {
-				b.logger.Errorw("SeekBigFileHeader", "err", err, "t1", t1, "t2", t2, "startdate", "fileSize", fi.Size())
+				b.logger.Errorw("readVolumeRange", "err", err, "t1", t1, "t2", t2, "filepath", v, "start", start, "end", end)
 				continue
 			}
 
 			if isCtxTimeout(ctx) {
-				return nil, &utils.ResponseV2{
+				return nil, coverEnd, &utils.ResponseV2{
 					Code: http.StatusGatewayTimeout,
 					Msg:  http.ErrHandlerTimeout.Error(),
 				}
 			}
 
 			Response.Write(data)
+			if fileEnd.After(coverEnd) {
+				coverEnd = fileEnd
+			}
+		}
 
+		if Response.Len() > 0 {
 			b.logger.Debugw("Response info", "sensorid", sensorID, "Response size", Response.Len())
 			b.exportMetrics.SetFileReadValues(sensorID, metric.MonitorSuccess)
-
-			return Response.Bytes(), nil
+			return Response.Bytes(), coverEnd, nil
 		}
 	}
 
-	return nil, &utils.ResponseV2{
+	b.exportMetrics.SetFileReadValues(sensorID, metric.MonitorFailed)
+	return nil, coverEnd, &utils.ResponseV2{
 		Code: http.StatusNotFound,
 		Msg:  http.StatusText(http.StatusNotFound),
 	}
 }
 
-// Update 更新文件存储信息
+// ListVolumes 获取时间段内的数据卷列表, 按创建时间排序. fileType为已注册的数据段类型名
+func (b *ArcVolumeCache) ListVolumes(sensorID, fileType string, t1, t2 time.Time) ([]string, error) {
+	st, ok := SegmentTypeByName(fileType)
+	if !ok {
+		return nil, fmt.Errorf("unknown segment type %q", fileType)
+	}
+
+	// 获取以天为单位的时间范围
+	daysdiffer, count, err := util.GetDaysDiffer(t1.UTC().Format("2006-01-02 15:04:05"), t2.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+
+	b.logger.Debugw("util.GetDaysDiffer", "daysdiffer", daysdiffer, "count", count)
+
+	// 获取大文件列表
+	bigfilelists := make(map[string]string, count) // map[createtime]fullpath
+
+	for _, day := range daysdiffer {
+		path := b.config.Work.DataPath + "/" + sensorID + "/" + day + "/" + st.Dir
+		err := util.GetBigFileLists(path, bigfilelists, st.Ext)
+		if err != nil {
+			b.logger.Debugw("GetBigFileList", "path", path, "bigFileList", bigfilelists, "err", err)
+			continue
+		}
+	}
+	b.logger.Debugw("bigfilelists", "bigfilelists", bigfilelists)
+
+	var timestamplist []string
+	for key := range bigfilelists {
+		timestamplist = append(timestamplist, key)
+	}
+	sort.Strings(timestamplist)
+
+	var filepathlist []string
+	for _, creattime := range timestamplist {
+		begin, end, _, err := util.GetTimeRangeFromFileName(bigfilelists[creattime])
+		if err != nil {
+			b.logger.Errorw("GetTimeRangeFromFileName", "fileName", bigfilelists[creattime], "err", err)
+			continue
+		}
+		// 文件保存结束时间早于查询开始时间，或文件创建时间晚于查询结束时间，跳过
+		if end.Before(t1) || t2.Before(begin) {
+			continue
+		}
+		filepathlist = append(filepathlist, bigfilelists[creattime])
+	}
+
+	return filepathlist, nil
+}
+
+// volumeRange 根据帧索引计算数据卷中[t1,t2)的字节范围(压缩前的文件偏移)及覆盖的结束时间.
+// 旧数据卷没有索引时返回整个数据区, 结束时间取文件头或文件名中的保存时间.
+func volumeRange(filename string, t1, t2 time.Time) (start, end int64, coverEnd time.Time, err error) {
+	f, err := OpenVolume(filename)
+	if err != nil {
+		return 0, 0, coverEnd, err
+	}
+	defer f.Close()
+
+	header, offset := readVolumeHeader(f)
+
+	index, err := readFrameIndex(filename + IndexFileType)
+	if err != nil {
+		if header != nil {
+			return offset, f.Size(), header.SaveTime, nil
+		}
+		_, coverEnd, _, err = util.GetTimeRangeFromFileName(filename)
+		return 0, f.Size(), coverEnd, err
+	}
+	start, end = seekFrameRange(index, f.Size()-offset, t1, t2)
+	return start + offset, end + offset, frameCoverEnd(index, t2), nil
+}
+
+// Update 更新文件存储信息, t为新数据卷的创建时间
 func (bf *ArcVolume) Update(t time.Time) {
 	// reset buffer
 	buffer := bytes.NewBuffer([]byte{})
@@ -245,6 +359,27 @@ func (bf *ArcVolume) Update(t time.Time) {
 
 	bf.MinuteStr = minuteStr
 	bf.Buffer = buffer
+	bf.Index = nil
+	bf.CreateTime = t
+}
+
+// ShouldRollover 追加帧前判断是否切换数据卷: 帧时间跨过对齐的时间边界(saveDuration*saveNum),
+// 或数据卷大小、帧数达到上限
+func (b *ArcVolumeCache) ShouldRollover(bf *ArcVolume, t time.Time, size int) bool {
+	if bf.Buffer.Len() < 1 {
+		return false
+	}
+	w := b.config.Work
+	if w.SaveDuration != "" && util.IsZeroTime(bf.CreateTime, t, w.SaveDuration, w.SaveNum) {
+		return true
+	}
+	if w.MaxVolumeSize > 0 && int64(bf.Buffer.Len()+size) > w.MaxVolumeSize {
+		return true
+	}
+	if w.MaxVolumeFrames > 0 && len(bf.Index) >= w.MaxVolumeFrames {
+		return true
+	}
+	return false
 }
 
 // PreWriteToFileCache 深拷贝数据,准备写入FileCache
@@ -262,18 +397,43 @@ func (b *ArcVolumeCache) PreWriteToFileCache(bf *ArcVolume, t time.Time, secondH
 		buffer.Write(bf.Buffer.Bytes())
 	}
 
+	// 索引只保留写入部分的帧
+	index := make([]FrameIndex, 0, len(bf.Index))
+	for _, e := range bf.Index {
+		if e.Offset < int64(buffer.Len()) {
+			index = append(index, e)
+		}
+	}
+
+	// 迟到帧数据卷或回放的数据可能乱序, 写入前按时间戳排序, 文件名覆盖全部帧的时间范围
+	createTime := bf.CreateTime
+	sorted, index := sortFrames(buffer.Bytes(), index)
+	if len(index) > 0 {
+		if first := time.UnixMicro(index[0].Timestamp); first.Before(createTime) {
+			createTime = first
+		}
+		// 文件名及文件头的保存时间为最后一帧的时间. 切换数据卷时t为下一个数据卷第一帧的时间
+		t = time.UnixMicro(index[len(index)-1].Timestamp)
+		buffer = bytes.NewBuffer(sorted)
+	}
+
 	b.logger.Debugw("PreWriteToFileCache", "type", bf.Type, "buffer_len", bf.Buffer.Len(), "secondHalfSize", secondHalfSize)
 	// 保存文件时确定写入文件路径
-	dateFolderName := bf.CreateTime.Format("20060102")
-	dir := bf.Dir + "/" + bf.SensorID + "/" + dateFolderName + "/" + DataTypeMap[bf.Type]
+	dateFolderName := createTime.Format("20060102")
+	st, ok := SegmentTypeByName(bf.Type)
+	if !ok {
+		return fmt.Errorf("unknown segment type %q", bf.Type)
+	}
+	dir := bf.Dir + "/" + bf.SensorID + "/" + dateFolderName + "/" + st.Dir
 	data := &ArcVolume{
-		CreateTime: bf.CreateTime,
+		CreateTime: createTime,
 		SaveTime:   t,
 		Dir:        dir,
 		SensorID:   bf.SensorID,
 		Buffer:     buffer,
 		MinuteStr:  bf.MinuteStr,
 		Type:       bf.Type,
+		Index:      index,
 	}
 	if err := b.WriteDataByQueue(data); err != nil {
 		b.logger.Errorw("SetRowDataByTask tmp fail", "type", bf.Type, "buffer_len", data.Buffer.Len(), "err", err)
@@ -283,6 +443,29 @@ func (b *ArcVolumeCache) PreWriteToFileCache(bf *ArcVolume, t time.Time, secondH
 	return nil
 }
 
+// LoadDedupWindow 在传感器的队列中读取数据卷内[t1,t2)的帧恢复去重窗口, 不阻塞调用者. 窗口的第一次Seen等待读取完成,
+// 最长为读取超时, 重启后立即重试的帧也能被识别. 读取失败时不恢复, 超时后读取结果在之后的Seen中合并. SafeClose等待读取完成
+func (b *ArcVolumeCache) LoadDedupWindow(d *DedupWindow, sensorID string, fileTypes []string, t1, t2 time.Time) {
+	timeout := time.Duration(b.config.Work.ArcVolumeQueueReadTimeoutSeconds) * time.Second
+	b.background.Add(1)
+	d.loadAsync(func() []dedupKey {
+		defer b.background.Done()
+		var keys []dedupKey
+		for _, fileType := range fileTypes {
+			// 读取不设超时, 读取结果在任务完成后才使用; 等待上限由Seen控制
+			t := createDedupTask(context.Background(), sensorID, b, sensorID, fileType, t1, t2)
+			b.queue.DoTask(t)
+			if t.err != nil {
+				b.logger.Warnw("LoadDedupWindow", "id", sensorID, "type", fileType, "err", t.err)
+				continue
+			}
+			keys = append(keys, t.keys...)
+		}
+		b.logger.Debugw("LoadDedupWindow", "id", sensorID, "frames", len(keys))
+		return keys
+	}, timeout)
+}
+
 // WriteDataByQueue -
 func (b *ArcVolumeCache) WriteDataByQueue(data *ArcVolume) error {
 
@@ -292,73 +475,35 @@ func (b *ArcVolumeCache) WriteDataByQueue(data *ArcVolume) error {
 
 	return writeTask.err
 }
-func (b *ArcVolumeCache) writeDataLogic(ctx context.Context, cc *ArcVolume) error {
+
+// writeDataLogic 按arc.writeMode写入数据卷及帧索引. 持久化级别为group时返回等待组提交的文件
+func (b *ArcVolumeCache) writeDataLogic(ctx context.Context, cc *ArcVolume) ([]*os.File, error) {
 	dataSize := cc.Buffer.Len()
 
 	if dataSize < 1 {
-		return nil
+		return nil, nil
 	}
 
 	startTime := time.Now().UTC()
 
-	filepath := ""
-	isFirst := false
-
-	filepath = cc.Dir + "/" + cc.SensorID + "_" + cc.Type + "_" + util.TimeStringReplace(cc.CreateTime) + "_" + util.TimeStringReplace(cc.SaveTime) + FileType
-	b.logger.Debugw("no files are generated in the current directory", "filePath", filepath)
-
-	dataToStore := make([]byte, dataSize)
-	copy(dataToStore, cc.Buffer.Bytes())
-
-	if isFirst {
-		//dump.Dump(cc)
-		filepath = cc.Dir + "/" + cc.SensorID + "_" + cc.Type + "_" + util.TimeStringReplace(cc.CreateTime) + "_" + util.TimeStringReplace(cc.CreateTime) + FileType
-
-		b.logger.Debugw("data appending is not required, create a new file", "filePath", filepath, "new data creation time", cc.CreateTime, "dataSize", dataSize)
-
-		if err := createnewFile(ctx, b.logger, filepath, dataToStore); err != nil {
-			b.exportMetrics.SetDiskWriteErrorLabelValues(cc.SensorID)
-			b.logger.Debugw("CreateNewFile", "filePath", filepath, "err", err)
-			return err
-		}
-	}
-
-	b.exportMetrics.SetConsumingTimeLabelValues(cc.SensorID, startTime)
-	b.exportMetrics.SetDataSizeLabelValues(cc.SensorID, float64(dataSize))
-
-	return nil
-}
-
-// createnewFile 创建新文件
-func createnewFile(ctx context.Context, logger logging.ILogger, filepath string, data []byte) error {
-
-	err := os.MkdirAll(path.Dir(filepath), os.ModePerm)
-	if err != nil {
-		return fmt.Errorf("MKdirAll: %v", err)
+	st, ok := SegmentTypeByName(cc.Type)
+	if !ok {
+		b.exportMetrics.SetDiskWriteErrorLabelValues(cc.SensorID)
+		return nil, fmt.Errorf("unknown segment type %q", cc.Type)
 	}
 
-	file, err := os.Create(filepath)
+	filepath, pending, err := b.writer.write(cc, st.Ext, b.volumeHeader)
 	if err != nil {
-		return fmt.Errorf("file create error: %v", err)
+		b.exportMetrics.SetDiskWriteErrorLabelValues(cc.SensorID)
+		b.logger.Errorw("writeDataLogic", "sensorID", cc.SensorID, "type", cc.Type, "mode", b.writer.mode, "durability", b.writer.durability, "dataSize", dataSize, "err", err)
+		return nil, err
 	}
-	defer file.Close()
-	return nil
-}
+	b.logger.Debugw("writeDataLogic", "filePath", filepath, "mode", b.writer.mode, "dataSize", dataSize)
 
-func appendBigFileData(ctx context.Context, logger logging.ILogger, filename string, data []byte) error {
-
-	// 文件存在，追加写入
-	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
-	if err != nil { //os.O_TRUNC
-		return fmt.Errorf("OpenFile: %v", err)
-	}
-	defer f.Close()
-
-	if _, err = f.Write(data); err != nil {
-		return fmt.Errorf("file write error: %v", err)
-	}
+	b.exportMetrics.SetConsumingTimeLabelValues(cc.SensorID, startTime)
+	b.exportMetrics.SetDataSizeLabelValues(cc.SensorID, float64(dataSize))
 
-	return nil
+	return pending, nil
 }
 
 func isCtxTimeout(ctx context.Context) bool {
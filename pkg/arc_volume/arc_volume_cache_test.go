@@ -0,0 +1,43 @@
+package arc_volume
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestArcVolumeCache(t *testing.T) {
+	CaseShouldRollover(t)
+}
+
+func CaseShouldRollover(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	b := &ArcVolumeCache{config: &config.ArcConfig{Work: &config.WorkConfig{
+		SaveDuration:    "hour",
+		SaveNum:         1,
+		MaxVolumeSize:   1000,
+		MaxVolumeFrames: 3,
+	}}}
+
+	Convey("ShouldRollover", t, func() {
+		bf := &ArcVolume{CreateTime: base, Buffer: &bytes.Buffer{}}
+		So(b.ShouldRollover(bf, base.Add(2*time.Hour), 10), ShouldBeFalse)
+
+		bf.Append(base, make([]byte, 100))
+		So(b.ShouldRollover(bf, base.Add(time.Minute), 10), ShouldBeFalse)
+		So(b.ShouldRollover(bf, time.Date(2024, 1, 2, 4, 0, 0, 0, time.UTC), 10), ShouldBeTrue)
+		So(b.ShouldRollover(bf, base.Add(time.Minute), 901), ShouldBeTrue)
+
+		bf.Append(base, make([]byte, 100))
+		bf.Append(base, make([]byte, 100))
+		So(b.ShouldRollover(bf, base.Add(time.Minute), 10), ShouldBeTrue)
+
+		bf.Update(base.Add(time.Hour))
+		So(bf.CreateTime.Equal(base.Add(time.Hour)), ShouldBeTrue)
+		So(bf.Buffer.Len(), ShouldEqual, 0)
+		So(len(bf.Index), ShouldEqual, 0)
+	})
+}
@@ -0,0 +1,71 @@
+package arc_volume
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/kiga-hub/arc-storage/pkg/util"
+	"github.com/kiga-hub/arc/logging"
+)
+
+// Codec 数据卷压缩算法, 按块压缩
+type Codec interface {
+	// Name 配置及压缩数据卷中使用的名称
+	Name() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[string]Codec{}
+)
+
+func init() {
+	RegisterCodec(gzipCodec{logger: &logging.NoopLogger{}})
+	RegisterCodec(zstdCodec{})
+}
+
+// RegisterCodec 注册压缩算法, 同名覆盖
+func RegisterCodec(c Codec) {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+// CodecByName -
+func CodecByName(name string) (Codec, error) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		names := make([]string, 0, len(codecs))
+		for n := range codecs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown codec %q, available: %v", name, names)
+	}
+	return c, nil
+}
+
+// gzipCodec util.GzipData
+type gzipCodec struct {
+	logger logging.ILogger
+}
+
+// Name -
+func (gzipCodec) Name() string {
+	return "gzip"
+}
+
+// Compress -
+func (c gzipCodec) Compress(data []byte) ([]byte, error) {
+	return util.GzipData(data, c.logger)
+}
+
+// Decompress -
+func (c gzipCodec) Decompress(data []byte) ([]byte, error) {
+	return util.UnGzipData(data, c.logger)
+}
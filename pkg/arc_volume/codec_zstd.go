@@ -0,0 +1,28 @@
+package arc_volume
+
+import (
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// zstdCodec github.com/klauspost/compress/zstd
+type zstdCodec struct{}
+
+// Name -
+func (zstdCodec) Name() string {
+	return "zstd"
+}
+
+// Compress -
+func (zstdCodec) Compress(data []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(data, nil), nil
+}
+
+// Decompress -
+func (zstdCodec) Decompress(data []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(data, nil)
+}
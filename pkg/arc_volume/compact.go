@@ -0,0 +1,401 @@
+package arc_volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/util"
+)
+
+// compactJournalExt 合并日志后缀. 合并后的数据卷重命名前写入, 原数据卷删除后删除
+const compactJournalExt = ".compact"
+
+// compactJournal 合并日志, 重启后据此完成或撤销未完成的合并
+type compactJournal struct {
+	Target  string   `json:"target"`
+	Size    int64    `json:"size"` // 合并后的数据卷大小, 含文件头
+	Sources []string `json:"sources"`
+}
+
+// CompactGroup 合并为一个数据卷的相邻数据卷
+type CompactGroup struct {
+	Sources []string `json:"sources"`
+	Target  string   `json:"target"`
+	Size    int64    `json:"size"` // 合并后数据区大小
+	Frames  int      `json:"frames"`
+}
+
+// CompactReport 一次合并扫描的结果, DryRun时只包含合并计划
+type CompactReport struct {
+	DryRun  bool           `json:"dry_run"`
+	Groups  []CompactGroup `json:"groups"`
+	Volumes int            `json:"volumes"` // 被合并的数据卷数
+	Merged  int            `json:"merged"`  // 已完成合并的组数
+	Bytes   int64          `json:"bytes"`
+	Failed  int            `json:"failed"`
+}
+
+// compactSource 参与合并的数据卷
+type compactSource struct {
+	path       string
+	header     *VolumeHeader
+	createTime time.Time
+	saveTime   time.Time
+	size       int64 // 数据区大小
+	frames     int
+}
+
+// statCompactSource 只合并有文件头及帧索引的数据卷
+func statCompactSource(filename string) (*compactSource, error) {
+	createTime, saveTime, _, err := util.GetTimeRangeFromFileName(filename)
+	if err != nil {
+		return nil, err
+	}
+	v, err := OpenVolume(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Close()
+	h, offset := readVolumeHeader(v)
+	if h == nil {
+		return nil, errNoVolumeHeader
+	}
+	index, err := readFrameIndex(filename + IndexFileType)
+	if err != nil {
+		return nil, err
+	}
+	if len(index) == 0 {
+		return nil, fmt.Errorf("empty frame index")
+	}
+	return &compactSource{
+		path:       filename,
+		header:     h,
+		createTime: createTime,
+		saveTime:   saveTime,
+		size:       v.Size() - offset,
+		frames:     len(index),
+	}, nil
+}
+
+// StartCompactor 先处理上次退出时未完成的合并, 再定期合并保存时间早于compact.age的相邻数据卷, stop关闭后退出. SafeClose等待退出
+func (b *ArcVolumeCache) StartCompactor(stop <-chan struct{}) {
+	conf := b.config.Compact
+	b.recoverCompaction()
+	b.runBackground(stop, time.Duration(conf.Interval)*time.Second, func(ctx context.Context) {
+		r := b.Compact(ctx, conf.DryRun)
+		if len(r.Groups) > 0 || r.Failed > 0 {
+			b.logger.Infow("Compact", "dryRun", r.DryRun, "groups", len(r.Groups), "volumes", r.Volumes, "merged", r.Merged, "bytes", r.Bytes, "failed", r.Failed)
+		}
+		if r.DryRun {
+			for _, g := range r.Groups {
+				b.logger.Infow("CompactPlan", "target", g.Target, "sources", g.Sources, "size", g.Size, "frames", g.Frames)
+			}
+		}
+	})
+}
+
+// CompactPlan 合并计划, compact.interval内返回缓存的计划, 不随请求扫描数据目录
+func (b *ArcVolumeCache) CompactPlan() CompactReport {
+	var interval time.Duration
+	if b.config.Compact != nil {
+		interval = time.Duration(b.config.Compact.Interval) * time.Second
+	}
+	return b.compactPlan.get(interval, func() interface{} {
+		return b.Compact(context.Background(), true)
+	}).(CompactReport)
+}
+
+// Compact 按compact配置合并天目录内的相邻数据卷. 合并任务与同一传感器的读写任务在同一个队列中执行,
+// 提交前按compact.bytesPerSecond限速
+func (b *ArcVolumeCache) Compact(ctx context.Context, dryRun bool) CompactReport {
+	conf := b.config.Compact
+	r := CompactReport{DryRun: dryRun, Groups: []CompactGroup{}}
+	if conf == nil {
+		return r
+	}
+	span := time.Duration(conf.Span) * time.Second
+	if span <= 0 {
+		span = time.Hour
+	}
+	before := time.Now().Add(-time.Duration(conf.Age) * time.Second)
+	throttler := util.NewWriteThrottler(conf.BytesPerSecond)
+
+	sensors, err := os.ReadDir(b.config.Work.DataPath)
+	if err != nil {
+		b.logger.Warnw("Compact", "dataPath", b.config.Work.DataPath, "err", err)
+		return r
+	}
+	for _, sensor := range sensors {
+		if !sensor.IsDir() {
+			continue
+		}
+		days, err := os.ReadDir(b.config.Work.DataPath + "/" + sensor.Name())
+		if err != nil {
+			continue
+		}
+		for _, day := range days {
+			if d, err := time.Parse("20060102", day.Name()); err != nil || !day.IsDir() || !d.Before(before) {
+				continue
+			}
+			for _, st := range SegmentTypes() {
+				dir := b.config.Work.DataPath + "/" + sensor.Name() + "/" + day.Name() + "/" + st.Dir
+				for _, group := range b.planCompaction(dir, st.Ext, span, before) {
+					if ctx.Err() != nil {
+						return r
+					}
+					g := CompactGroup{Target: volumePath(dir, group[0].header.SensorID, group[0].header.Type, group[0].createTime, group[len(group)-1].saveTime, st.Ext)}
+					for _, s := range group {
+						g.Sources = append(g.Sources, s.path)
+						g.Size += s.size
+						g.Frames += s.frames
+					}
+					r.Groups = append(r.Groups, g)
+					r.Volumes += len(group)
+					if dryRun {
+						continue
+					}
+
+					throttler.MaybeSlowdown(g.Size)
+					// 合并不随ctx取消, 取消时等待执行中的合并完成
+					t := createCompactTask(context.WithoutCancel(ctx), sensor.Name(), b, group, g.Target)
+					b.queue.DoTask(t)
+					if t.err != nil {
+						r.Failed++
+						b.logger.Errorw("mergeVolumes", "target", g.Target, "sources", g.Sources, "err", t.err)
+						continue
+					}
+					r.Merged++
+					r.Bytes += g.Size
+				}
+			}
+		}
+	}
+	return r
+}
+
+// planCompaction 按创建时间排序, 同一对齐时间跨度内时间不重叠的相邻数据卷合并为一组, 合并后不超过数据卷上限
+func (b *ArcVolumeCache) planCompaction(dir, ext string, span time.Duration, before time.Time) [][]*compactSource {
+	files := map[string]string{}
+	if err := util.GetBigFileLists(dir, files, ext); err != nil || len(files) < 2 {
+		return nil
+	}
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	w := b.config.Work
+	var groups [][]*compactSource
+	var group []*compactSource
+	var size int64
+	var frames int
+	flush := func() {
+		if len(group) > 1 {
+			groups = append(groups, group)
+		}
+		group, size, frames = nil, 0, 0
+	}
+	for _, k := range keys {
+		s, err := statCompactSource(files[k])
+		if err != nil || !s.saveTime.Before(before) {
+			flush()
+			continue
+		}
+		if len(group) > 0 {
+			last := group[len(group)-1]
+			if !s.createTime.Truncate(span).Equal(group[0].createTime.Truncate(span)) ||
+				s.createTime.Before(last.saveTime) ||
+				s.header.Type != last.header.Type ||
+				(w.MaxVolumeSize > 0 && size+s.size > w.MaxVolumeSize) ||
+				(w.MaxVolumeFrames > 0 && frames+s.frames > w.MaxVolumeFrames) {
+				flush()
+			}
+		}
+		group = append(group, s)
+		size += s.size
+		frames += s.frames
+	}
+	flush()
+	return groups
+}
+
+// mergeVolumes 合并数据卷: 先写入合并日志, 合并后的帧索引及数据卷写入临时文件, fsync后重命名, fsync目录后再删除原数据卷及合并日志.
+// 原数据卷在合并后的数据卷持久化之前保持不变, 删除中途退出时由recoverCompaction按合并日志继续删除
+func (b *ArcVolumeCache) mergeVolumes(group []*compactSource, target string) error {
+	h := *group[0].header
+	h.SaveTime = group[len(group)-1].saveTime
+	header := encodeVolumeHeader(&h)
+
+	journal := compactJournal{Target: target, Size: int64(len(header))}
+	for _, s := range group {
+		journal.Sources = append(journal.Sources, s.path)
+		journal.Size += s.size
+	}
+	data, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+	syncer := &fileSyncer{durability: DurabilityDir}
+	if err := syncer.createAtomic(target+compactJournalExt, data); err != nil {
+		return err
+	}
+	if err := syncer.dir(target); err != nil {
+		os.Remove(target + compactJournalExt)
+		return err
+	}
+
+	var index []FrameIndex
+	var offset int64
+	for _, s := range group {
+		entries, err := readFrameIndex(s.path + IndexFileType)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			index = append(index, FrameIndex{Timestamp: e.Timestamp, Offset: e.Offset + offset})
+		}
+		offset += s.size
+	}
+
+	if err := syncer.createAtomic(target+IndexFileType, encodeFrameIndex(index)); err != nil {
+		os.Remove(target + compactJournalExt)
+		return err
+	}
+	if err := copyVolumes(syncer, target, header, group); err != nil {
+		os.Remove(target + IndexFileType)
+		os.Remove(target + compactJournalExt)
+		return err
+	}
+	if err := syncer.dir(target); err != nil {
+		return err
+	}
+
+	for _, s := range group {
+		b.writer.forget(s.path)
+	}
+	return removeCompactSources(&journal)
+}
+
+// removeCompactSources 删除已合并的原数据卷及帧索引, fsync目录后删除合并日志
+func removeCompactSources(j *compactJournal) error {
+	for _, s := range j.Sources {
+		if s == j.Target {
+			continue
+		}
+		if err := os.Remove(s); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %v", s, err)
+		}
+		os.Remove(s + IndexFileType)
+	}
+	if err := syncPath(path.Dir(j.Target)); err != nil {
+		return err
+	}
+	if err := os.Remove(j.Target + compactJournalExt); err != nil {
+		return err
+	}
+	return syncPath(path.Dir(j.Target))
+}
+
+// recoverCompaction 处理上次退出时留下的合并日志: 合并后的数据卷已完整时继续删除原数据卷,
+// 否则删除未完成的合并文件, 原数据卷保持不变
+func (b *ArcVolumeCache) recoverCompaction() {
+	sensors, err := os.ReadDir(b.config.Work.DataPath)
+	if err != nil {
+		return
+	}
+	for _, sensor := range sensors {
+		if !sensor.IsDir() {
+			continue
+		}
+		for _, st := range SegmentTypes() {
+			journals, _ := filepath.Glob(b.config.Work.DataPath + "/" + sensor.Name() + "/*/" + st.Dir + "/*" + compactJournalExt)
+			for _, filename := range journals {
+				if err := recoverCompactJournal(filename); err != nil {
+					b.logger.Errorw("recoverCompaction", "journal", filename, "err", err)
+				}
+			}
+		}
+	}
+}
+
+// recoverCompactJournal 按一个合并日志完成或撤销合并
+func recoverCompactJournal(filename string) error {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var j compactJournal
+	if err := json.Unmarshal(data, &j); err != nil || j.Target+compactJournalExt != filename {
+		// 无法识别的日志, 不删除任何数据卷
+		return os.Remove(filename)
+	}
+	if fi, err := os.Stat(j.Target); err == nil && fi.Size() == j.Size {
+		return removeCompactSources(&j)
+	}
+
+	// 合并后的数据卷未重命名, 删除临时文件及已写入的帧索引
+	os.Remove(j.Target + tmpFileExt)
+	os.Remove(j.Target + IndexFileType + tmpFileExt)
+	created := true
+	for _, s := range j.Sources {
+		if s == j.Target {
+			created = false
+		}
+	}
+	if created {
+		os.Remove(j.Target + IndexFileType)
+	}
+	return os.Remove(filename)
+}
+
+// copyVolumes 文件头及各数据卷的数据区写入临时文件后重命名为target
+func copyVolumes(syncer *fileSyncer, target string, header []byte, group []*compactSource) error {
+	tmp := target + tmpFileExt
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("file create error: %v", err)
+	}
+	if err := func() error {
+		if _, err := f.Write(header); err != nil {
+			return fmt.Errorf("file write error: %v", err)
+		}
+		for _, s := range group {
+			v, err := OpenVolume(s.path)
+			if err != nil {
+				return err
+			}
+			_, offset := readVolumeHeader(v)
+			if v.Size()-offset != s.size {
+				v.Close()
+				return fmt.Errorf("volume %s changed since planned", s.path)
+			}
+			_, err = io.Copy(f, io.NewSectionReader(v, offset, s.size))
+			v.Close()
+			if err != nil {
+				return fmt.Errorf("file write error: %v", err)
+			}
+		}
+		return nil
+	}(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := syncer.done(f); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package arc_volume
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompact(t *testing.T) {
+	CaseCompact(t)
+	CaseRecoverCompaction(t)
+}
+
+func CaseCompact(t *testing.T) {
+	Convey("Compact", t, func() {
+		dataPath := t.TempDir()
+		b, err := NewArcVolumeCache(&logging.NoopLogger{}, &config.ArcConfig{
+			Work:    &config.WorkConfig{DataPath: dataPath, ArcVolumeQueueLen: 4, ArcVolumeQueueNum: 1},
+			Compact: &config.CompactConfig{Span: 3600, Age: 3600},
+		}, "compact")
+		So(err, ShouldBeNil)
+		defer b.SafeClose()
+
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		dir := dataPath + "/A00000000001/20240102/" + SegmentTypeArc.Dir
+		for _, v := range []*ArcVolume{
+			testVolume(dir, start, []byte{1, 2}, []byte{3}),
+			testVolume(dir, start.Add(time.Minute), []byte{4}),
+			testVolume(dir, start.Add(2*time.Minute), []byte{5, 6}),
+			// next span
+			testVolume(dir, start.Add(time.Hour), []byte{7}),
+		} {
+			_, _, err := b.writer.write(v, SegmentTypeArc.Ext, testHeader)
+			So(err, ShouldBeNil)
+		}
+
+		r := b.Compact(context.Background(), true)
+		So(len(r.Groups), ShouldEqual, 1)
+		So(r.Volumes, ShouldEqual, 3)
+		So(r.Merged, ShouldEqual, 0)
+		So(r.Groups[0].Size, ShouldEqual, 6)
+		So(r.Groups[0].Frames, ShouldEqual, 4)
+		volumes, _ := filepath.Glob(dir + "/*" + SegmentTypeArc.Ext)
+		So(len(volumes), ShouldEqual, 4)
+		So(b.CompactPlan().Groups, ShouldResemble, r.Groups)
+
+		r = b.Compact(context.Background(), false)
+		So(r.Merged, ShouldEqual, 1)
+		So(r.Failed, ShouldEqual, 0)
+		volumes, _ = filepath.Glob(dir + "/*" + SegmentTypeArc.Ext)
+		So(len(volumes), ShouldEqual, 2)
+		tmp, _ := filepath.Glob(dir + "/*" + tmpFileExt)
+		So(tmp, ShouldBeEmpty)
+		journals, _ := filepath.Glob(dir + "/*" + compactJournalExt)
+		So(journals, ShouldBeEmpty)
+
+		target := r.Groups[0].Target
+		h, err := ReadVolumeHeader(target)
+		So(err, ShouldBeNil)
+		So(h.CreateTime.Equal(start), ShouldBeTrue)
+		So(h.SaveTime.Equal(start.Add(2*time.Minute)), ShouldBeTrue)
+		for _, s := range r.Groups[0].Sources {
+			_, err := os.Stat(s + IndexFileType)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		}
+
+		var got [][]byte
+		_, err = readVolumeFrames(target, start, start.Add(time.Hour), func(t time.Time, data []byte) error {
+			got = append(got, data)
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(got, ShouldResemble, [][]byte{{1, 2}, {3}, {4}, {5, 6}})
+
+		// nothing left to merge
+		r = b.Compact(context.Background(), false)
+		So(r.Groups, ShouldBeEmpty)
+		// the plan is cached within compact.interval
+		So(b.CompactPlan().Groups, ShouldNotBeEmpty)
+	})
+}
+
+func CaseRecoverCompaction(t *testing.T) {
+	Convey("recoverCompaction", t, func() {
+		dataPath := t.TempDir()
+		b, err := NewArcVolumeCache(&logging.NoopLogger{}, &config.ArcConfig{
+			Work:    &config.WorkConfig{DataPath: dataPath, ArcVolumeQueueLen: 4, ArcVolumeQueueNum: 1},
+			Compact: &config.CompactConfig{Span: 3600, Age: 3600},
+		}, "recoverCompaction")
+		So(err, ShouldBeNil)
+		defer b.SafeClose()
+
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		dir := dataPath + "/A00000000001/20240102/" + SegmentTypeArc.Dir
+		for _, v := range []*ArcVolume{
+			testVolume(dir, start, []byte{1, 2}),
+			testVolume(dir, start.Add(time.Minute), []byte{3}),
+		} {
+			_, _, err := b.writer.write(v, SegmentTypeArc.Ext, testHeader)
+			So(err, ShouldBeNil)
+		}
+		sources, _ := filepath.Glob(dir + "/*" + SegmentTypeArc.Ext)
+		So(len(sources), ShouldEqual, 2)
+		saved := map[string][]byte{}
+		for _, s := range sources {
+			for _, name := range []string{s, s + IndexFileType} {
+				saved[name], err = os.ReadFile(name)
+				So(err, ShouldBeNil)
+			}
+		}
+		restore := func() {
+			for name, data := range saved {
+				So(os.WriteFile(name, data, 0644), ShouldBeNil)
+			}
+		}
+		writeJournal := func(j compactJournal) {
+			data, err := json.Marshal(j)
+			So(err, ShouldBeNil)
+			So(os.WriteFile(j.Target+compactJournalExt, data, 0644), ShouldBeNil)
+		}
+
+		r := b.Compact(context.Background(), false)
+		So(r.Merged, ShouldEqual, 1)
+		target := r.Groups[0].Target
+		fi, err := os.Stat(target)
+		So(err, ShouldBeNil)
+
+		// sources left behind after the rename are removed
+		restore()
+		writeJournal(compactJournal{Target: target, Size: fi.Size(), Sources: sources})
+		b.recoverCompaction()
+		volumes, _ := filepath.Glob(dir + "/*" + SegmentTypeArc.Ext)
+		So(volumes, ShouldResemble, []string{target})
+		journals, _ := filepath.Glob(dir + "/*" + compactJournalExt)
+		So(journals, ShouldBeEmpty)
+
+		// an unfinished merge keeps the sources
+		restore()
+		So(os.Remove(target), ShouldBeNil)
+		So(os.Rename(target+IndexFileType, target+IndexFileType+tmpFileExt), ShouldBeNil)
+		writeJournal(compactJournal{Target: target, Size: fi.Size(), Sources: sources})
+		b.recoverCompaction()
+		volumes, _ = filepath.Glob(dir + "/*" + SegmentTypeArc.Ext)
+		So(volumes, ShouldResemble, sources)
+		leftovers, _ := filepath.Glob(dir + "/*" + tmpFileExt)
+		So(leftovers, ShouldBeEmpty)
+		journals, _ = filepath.Glob(dir + "/*" + compactJournalExt)
+		So(journals, ShouldBeEmpty)
+		for _, s := range sources {
+			_, err := os.Stat(s + IndexFileType)
+			So(err, ShouldBeNil)
+		}
+	})
+}
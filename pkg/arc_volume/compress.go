@@ -0,0 +1,407 @@
+package arc_volume
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/util"
+)
+
+const (
+	// CompressedMagic 压缩数据卷标识
+	CompressedMagic = "ARCZ"
+	// compressedVersion -
+	compressedVersion = 1
+	// compressedFooter tableOffset(8) magic(4)
+	compressedFooter = 12
+	// compressedBlockEntry offset(8) size(4)
+	compressedBlockEntry = 12
+)
+
+// errCompressed 数据卷已压缩
+var errCompressed = errors.New("volume already compressed")
+
+// 压缩数据卷布局(BigEndian), 压缩前的整个文件(含文件头)按blockSize分块压缩, 帧索引及文件名不变:
+// magic(4) version(2) codec(1+n) blockSize(4) | blocks | count(4) size(8) [offset(8) size(4)]*count crc32(4) | tableOffset(8) magic(4)
+
+// compressedBlock 压缩块在文件中的位置, 第i块的压缩前偏移为i*blockSize
+type compressedBlock struct {
+	offset int64
+	size   uint32
+}
+
+// VolumeFile 打开的数据卷. 压缩数据卷按块解压, ReadAt的偏移为压缩前的文件偏移
+type VolumeFile struct {
+	f         *os.File
+	size      int64 // 压缩前大小
+	stored    int64 // 文件大小
+	codec     Codec
+	blockSize int64
+	blocks    []compressedBlock
+	cached    int // 最近解压的块, -1为空
+	cache     []byte
+}
+
+// OpenVolume 打开数据卷, 自动识别压缩格式
+func OpenVolume(filename string) (*VolumeFile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	v := &VolumeFile{f: f, size: fi.Size(), stored: fi.Size(), cached: -1}
+
+	magic := make([]byte, len(CompressedMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil || string(magic) != CompressedMagic {
+		return v, nil
+	}
+	if err := v.readTable(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("compressed volume %s: %v", filename, err)
+	}
+	return v, nil
+}
+
+// readTable 读取块表
+func (v *VolumeFile) readTable() error {
+	if v.stored < compressedFooter {
+		return errVolumeHeaderCorrupt
+	}
+	footer := make([]byte, compressedFooter)
+	if _, err := v.f.ReadAt(footer, v.stored-compressedFooter); err != nil {
+		return err
+	}
+	if string(footer[8:]) != CompressedMagic {
+		return errVolumeHeaderCorrupt
+	}
+	tableOffset := int64(binary.BigEndian.Uint64(footer[:8]))
+	if tableOffset < 0 || tableOffset > v.stored-compressedFooter-16 {
+		return errVolumeHeaderCorrupt
+	}
+	table := make([]byte, v.stored-compressedFooter-tableOffset)
+	if _, err := v.f.ReadAt(table, tableOffset); err != nil {
+		return err
+	}
+	n := len(table) - 4
+	if crc32.ChecksumIEEE(table[:n]) != binary.BigEndian.Uint32(table[n:]) {
+		return errVolumeHeaderCorrupt
+	}
+	count := int(binary.BigEndian.Uint32(table[0:4]))
+	if 12+count*compressedBlockEntry != n {
+		return errVolumeHeaderCorrupt
+	}
+	v.size = int64(binary.BigEndian.Uint64(table[4:12]))
+	v.blocks = make([]compressedBlock, count)
+	for i := range v.blocks {
+		e := table[12+i*compressedBlockEntry:]
+		v.blocks[i] = compressedBlock{offset: int64(binary.BigEndian.Uint64(e)), size: binary.BigEndian.Uint32(e[8:])}
+	}
+
+	// magic(4) version(2) codec(1+n) blockSize(4)
+	head := make([]byte, 7+255+4)
+	m, err := v.f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	head = head[:m]
+	if len(head) < 7 {
+		return errVolumeHeaderCorrupt
+	}
+	l := int(head[6])
+	if len(head) < 7+l+4 {
+		return errVolumeHeaderCorrupt
+	}
+	if v.codec, err = CodecByName(string(head[7 : 7+l])); err != nil {
+		return err
+	}
+	v.blockSize = int64(binary.BigEndian.Uint32(head[7+l:]))
+	if v.blockSize == 0 {
+		return errVolumeHeaderCorrupt
+	}
+	return nil
+}
+
+// Size 压缩前大小
+func (v *VolumeFile) Size() int64 {
+	return v.size
+}
+
+// StoredSize 磁盘占用
+func (v *VolumeFile) StoredSize() int64 {
+	return v.stored
+}
+
+// Codec 压缩算法, 未压缩时为空
+func (v *VolumeFile) Codec() string {
+	if v.codec == nil {
+		return ""
+	}
+	return v.codec.Name()
+}
+
+// ReadAt io.ReaderAt
+func (v *VolumeFile) ReadAt(p []byte, off int64) (int, error) {
+	if v.codec == nil {
+		return v.f.ReadAt(p, off)
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	n := 0
+	for n < len(p) {
+		if off >= v.size {
+			return n, io.EOF
+		}
+		i := int(off / v.blockSize)
+		block, err := v.block(i)
+		if err != nil {
+			return n, err
+		}
+		start := off - int64(i)*v.blockSize
+		if start >= int64(len(block)) {
+			return n, io.ErrUnexpectedEOF
+		}
+		m := copy(p[n:], block[start:])
+		n += m
+		off += int64(m)
+	}
+	return n, nil
+}
+
+// block 解压第i块
+func (v *VolumeFile) block(i int) ([]byte, error) {
+	if i == v.cached {
+		return v.cache, nil
+	}
+	if i >= len(v.blocks) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := v.blocks[i]
+	data := make([]byte, b.size)
+	if _, err := v.f.ReadAt(data, b.offset); err != nil {
+		return nil, err
+	}
+	block, err := v.codec.Decompress(data)
+	if err != nil {
+		return nil, fmt.Errorf("decompress block %d: %v", i, err)
+	}
+	v.cached, v.cache = i, block
+	return block, nil
+}
+
+// Close -
+func (v *VolumeFile) Close() error {
+	return v.f.Close()
+}
+
+// VolumeStat 数据卷大小
+type VolumeStat struct {
+	Size       int64  `json:"size"`        // 压缩前大小
+	StoredSize int64  `json:"stored_size"` // 磁盘占用
+	Codec      string `json:"codec,omitempty"`
+}
+
+// StatVolume -
+func StatVolume(filename string) (VolumeStat, error) {
+	v, err := OpenVolume(filename)
+	if err != nil {
+		return VolumeStat{}, err
+	}
+	defer v.Close()
+	return VolumeStat{Size: v.Size(), StoredSize: v.StoredSize(), Codec: v.Codec()}, nil
+}
+
+// readVolumeRange 读取压缩前[start,start+size)的数据
+func readVolumeRange(filename string, start, size int64) ([]byte, error) {
+	v, err := OpenVolume(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer v.Close()
+
+	data := make([]byte, size)
+	n, err := v.ReadAt(data, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return data[:n], nil
+}
+
+// compressVolume 按块压缩数据卷, 写入临时文件并刷盘后替换原文件. 返回压缩前后的大小
+func compressVolume(filename string, codec Codec, blockSize int) (int64, int64, error) {
+	src, err := OpenVolume(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer src.Close()
+	if src.codec != nil {
+		return 0, 0, errCompressed
+	}
+	if blockSize <= 0 {
+		blockSize = 1 << 20
+	}
+
+	tmp := filename + tmpFileExt
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return 0, 0, fmt.Errorf("file create error: %v", err)
+	}
+	defer func() {
+		if dst != nil {
+			dst.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	name := codec.Name()
+	head := append([]byte(CompressedMagic), 0, 0, byte(len(name)))
+	binary.BigEndian.PutUint16(head[4:6], compressedVersion)
+	head = append(head, name...)
+	head = binary.BigEndian.AppendUint32(head, uint32(blockSize))
+	if _, err := dst.Write(head); err != nil {
+		return 0, 0, fmt.Errorf("file write error: %v", err)
+	}
+
+	offset := int64(len(head))
+	table := binary.BigEndian.AppendUint32(nil, 0)
+	table = binary.BigEndian.AppendUint64(table, uint64(src.Size()))
+	buf := make([]byte, blockSize)
+	count := 0
+	for pos := int64(0); pos < src.Size(); pos += int64(blockSize) {
+		n, err := src.ReadAt(buf, pos)
+		if err != nil && err != io.EOF {
+			return 0, 0, err
+		}
+		block, err := codec.Compress(buf[:n])
+		if err != nil {
+			return 0, 0, fmt.Errorf("compress: %v", err)
+		}
+		if _, err := dst.Write(block); err != nil {
+			return 0, 0, fmt.Errorf("file write error: %v", err)
+		}
+		table = binary.BigEndian.AppendUint64(table, uint64(offset))
+		table = binary.BigEndian.AppendUint32(table, uint32(len(block)))
+		offset += int64(len(block))
+		count++
+	}
+	binary.BigEndian.PutUint32(table[0:4], uint32(count))
+	table = binary.BigEndian.AppendUint32(table, crc32.ChecksumIEEE(table))
+	table = binary.BigEndian.AppendUint64(table, uint64(offset))
+	table = append(table, CompressedMagic...)
+	if _, err := dst.Write(table); err != nil {
+		return 0, 0, fmt.Errorf("file write error: %v", err)
+	}
+	stored := offset + int64(len(table))
+
+	if err := dst.Sync(); err != nil {
+		return 0, 0, fmt.Errorf("file sync error: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		dst = nil
+		os.Remove(tmp)
+		return 0, 0, fmt.Errorf("file close error: %v", err)
+	}
+	dst = nil
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return 0, 0, fmt.Errorf("rename: %v", err)
+	}
+	if err := syncPath(path.Dir(filename)); err != nil {
+		return 0, 0, err
+	}
+	return src.Size(), stored, nil
+}
+
+// CompressReport 一次压缩扫描的结果
+type CompressReport struct {
+	Volumes    int   `json:"volumes"`
+	Size       int64 `json:"size"`        // 压缩前大小
+	StoredSize int64 `json:"stored_size"` // 压缩后大小
+	Failed     int   `json:"failed"`
+}
+
+// StartCompressor 定期压缩保存时间早于compress.age的数据卷, stop关闭后退出. SafeClose等待退出
+func (b *ArcVolumeCache) StartCompressor(stop <-chan struct{}) error {
+	conf := b.config.Compress
+	codec, err := CodecByName(conf.Codec)
+	if err != nil {
+		return err
+	}
+	b.runBackground(stop, time.Duration(conf.Interval)*time.Second, func(ctx context.Context) {
+		r := b.CompressVolumes(ctx, codec, time.Now().Add(-time.Duration(conf.Age)*time.Second))
+		if r.Volumes > 0 || r.Failed > 0 {
+			b.logger.Infow("CompressVolumes", "volumes", r.Volumes, "size", r.Size, "storedSize", r.StoredSize, "failed", r.Failed)
+		}
+	})
+	return nil
+}
+
+// CompressVolumes 压缩保存时间早于before的数据卷, 压缩任务与同一传感器的读写任务在同一个队列中执行
+func (b *ArcVolumeCache) CompressVolumes(ctx context.Context, codec Codec, before time.Time) CompressReport {
+	var r CompressReport
+	sensors, err := os.ReadDir(b.config.Work.DataPath)
+	if err != nil {
+		b.logger.Warnw("CompressVolumes", "dataPath", b.config.Work.DataPath, "err", err)
+		return r
+	}
+
+	for _, sensor := range sensors {
+		if !sensor.IsDir() {
+			continue
+		}
+		days, err := os.ReadDir(b.config.Work.DataPath + "/" + sensor.Name())
+		if err != nil {
+			continue
+		}
+		for _, day := range days {
+			if d, err := time.Parse("20060102", day.Name()); err != nil || !day.IsDir() || !d.Before(before) {
+				continue
+			}
+			for _, st := range SegmentTypes() {
+				if zip, sure := util.IsGzippableFileType(st.Ext, ""); sure && !zip {
+					continue
+				}
+				dir := b.config.Work.DataPath + "/" + sensor.Name() + "/" + day.Name() + "/" + st.Dir
+				files := map[string]string{}
+				if err := util.GetBigFileLists(dir, files, st.Ext); err != nil {
+					continue
+				}
+				for _, filename := range files {
+					if ctx.Err() != nil {
+						return r
+					}
+					_, end, _, err := util.GetTimeRangeFromFileName(filename)
+					if err != nil || !end.Before(before) {
+						continue
+					}
+					// 任务不随ctx取消, 取消时等待执行中的任务完成, 只在任务之间退出
+					t := createCompressTask(context.WithoutCancel(ctx), sensor.Name(), b, filename, codec, b.config.Compress.BlockSize)
+					b.queue.DoTask(t)
+					switch {
+					case t.err == errCompressed:
+					case t.err != nil:
+						r.Failed++
+						b.logger.Errorw("compressVolume", "filepath", filename, "err", t.err)
+					default:
+						r.Volumes++
+						r.Size += t.size
+						r.StoredSize += t.stored
+					}
+				}
+			}
+		}
+	}
+	return r
+}
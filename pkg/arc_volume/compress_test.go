@@ -0,0 +1,141 @@
+package arc_volume
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompress(t *testing.T) {
+	CaseCompressVolume(t)
+	CaseCompressVolumes(t)
+	CaseCompressAppendVolume(t)
+}
+
+func CaseCompressVolume(t *testing.T) {
+	for _, c := range []string{"gzip", "zstd"} {
+		Convey("CompressVolume "+c, t, func() {
+			w, err := newVolumeWriter(&config.WorkConfig{WriteMode: WriteModeRename, Durability: DurabilityNone})
+			So(err, ShouldBeNil)
+			start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			frames := [][]byte{bytes.Repeat([]byte{1}, 100), bytes.Repeat([]byte{2}, 50), bytes.Repeat([]byte{3}, 70)}
+			name, _, err := w.write(testVolume(t.TempDir(), start, frames...), ".arc", testHeader)
+			So(err, ShouldBeNil)
+			plain, err := os.ReadFile(name)
+			So(err, ShouldBeNil)
+
+			codec, err := CodecByName(c)
+			So(err, ShouldBeNil)
+			size, stored, err := compressVolume(name, codec, 64)
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, len(plain))
+			_, _, err = compressVolume(name, codec, 64)
+			So(err, ShouldEqual, errCompressed)
+
+			stat, err := StatVolume(name)
+			So(err, ShouldBeNil)
+			So(stat, ShouldResemble, VolumeStat{Size: int64(len(plain)), StoredSize: stored, Codec: c})
+
+			// offsets are the ones of the plain volume, across blocks
+			v, err := OpenVolume(name)
+			So(err, ShouldBeNil)
+			data := make([]byte, 100)
+			_, err = v.ReadAt(data, 30)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, plain[30:130])
+			So(v.Close(), ShouldBeNil)
+
+			h, err := ReadVolumeHeader(name)
+			So(err, ShouldBeNil)
+			So(h.CreateTime.Equal(start), ShouldBeTrue)
+
+			var got [][]byte
+			_, err = readVolumeFrames(name, start, start.Add(time.Minute), func(t time.Time, data []byte) error {
+				got = append(got, data)
+				return nil
+			})
+			So(err, ShouldBeNil)
+			So(got, ShouldResemble, frames)
+		})
+	}
+}
+
+func CaseCompressVolumes(t *testing.T) {
+	Convey("CompressVolumes", t, func() {
+		dataPath := t.TempDir()
+		b, err := NewArcVolumeCache(&logging.NoopLogger{}, &config.ArcConfig{
+			Work:     &config.WorkConfig{DataPath: dataPath, ArcVolumeQueueLen: 4, ArcVolumeQueueNum: 1},
+			Compress: &config.CompressConfig{Enable: true, Codec: "gzip", BlockSize: 1 << 10},
+		}, SegmentTypeArc.Dir)
+		So(err, ShouldBeNil)
+		defer b.SafeClose()
+
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		dir := dataPath + "/A00000000001/20240102/" + SegmentTypeArc.Dir
+		old, _, err := b.writer.write(testVolume(dir, start, []byte{1, 2, 3}), SegmentTypeArc.Ext, testHeader)
+		So(err, ShouldBeNil)
+		recent, _, err := b.writer.write(testVolume(dir, start.Add(5*time.Minute), []byte{4}), SegmentTypeArc.Ext, testHeader)
+		So(err, ShouldBeNil)
+
+		codec, _ := CodecByName("gzip")
+		r := b.CompressVolumes(context.Background(), codec, start.Add(time.Minute))
+		So(r.Volumes, ShouldEqual, 1)
+		So(r.Failed, ShouldEqual, 0)
+
+		stat, err := StatVolume(old)
+		So(err, ShouldBeNil)
+		So(stat.Codec, ShouldEqual, "gzip")
+		stat, err = StatVolume(recent)
+		So(err, ShouldBeNil)
+		So(stat.Codec, ShouldEqual, "")
+
+		data, _, resp := b.readDataLogic(context.Background(), "A00000000001", SegmentTypeArc.Name, start, start.Add(10*time.Minute))
+		So(resp, ShouldBeNil)
+		So(data, ShouldResemble, []byte{1, 2, 3, 4})
+
+		_, err = NewArcVolumeCache(&logging.NoopLogger{}, &config.ArcConfig{
+			Work:     &config.WorkConfig{DataPath: dataPath, ArcVolumeQueueLen: 4, ArcVolumeQueueNum: 1},
+			Compress: &config.CompressConfig{Enable: true, Codec: "lz4"},
+		}, SegmentTypeArc.Dir)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func CaseCompressAppendVolume(t *testing.T) {
+	Convey("CompressAppendVolume", t, func() {
+		dataPath := t.TempDir()
+		work := &config.WorkConfig{DataPath: dataPath, WriteMode: WriteModeAppend, SaveDuration: "hour", SaveNum: 1}
+		w, err := newVolumeWriter(work)
+		So(err, ShouldBeNil)
+		b := &ArcVolumeCache{
+			logger: &logging.NoopLogger{},
+			config: &config.ArcConfig{Work: work, Compress: &config.CompressConfig{BlockSize: 1 << 10}},
+			writer: w,
+			queue:  NewQueue(&logging.NoopLogger{}, 4, 1),
+		}
+		defer b.SafeClose()
+
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		dir := dataPath + "/A00000000001/20240102/" + SegmentTypeArc.Dir
+		first, _, err := w.write(testVolume(dir, start, []byte{1}), SegmentTypeArc.Ext, testHeader)
+		So(err, ShouldBeNil)
+
+		codec, _ := CodecByName("gzip")
+		r := b.CompressVolumes(context.Background(), codec, start.Add(time.Minute))
+		So(r.Volumes, ShouldEqual, 1)
+
+		// the compressed volume is no longer appended to
+		second, _, err := w.write(testVolume(dir, start.Add(2*time.Minute), []byte{2}), SegmentTypeArc.Ext, testHeader)
+		So(err, ShouldBeNil)
+		So(second, ShouldNotEqual, first)
+		stat, err := StatVolume(first)
+		So(err, ShouldBeNil)
+		So(stat.Codec, ShouldEqual, "gzip")
+	})
+}
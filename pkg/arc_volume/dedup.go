@@ -0,0 +1,134 @@
+package arc_volume
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// dedupKey 帧的时间戳与数据hash
+type dedupKey struct {
+	timestamp int64 // us
+	hash      uint64
+}
+
+// DedupWindow 传感器最近帧的(时间戳, 数据hash). 重试或重复投递的帧在窗口内被识别并丢弃,
+// 早于最新帧时间戳减去horizon的记录被淘汰.
+type DedupWindow struct {
+	horizon int64 // us
+	seen    map[dedupKey]struct{}
+	order   []dedupKey // 记录顺序, 用于淘汰
+	newest  int64
+	loading chan []dedupKey // 后台恢复的记录, 为空时没有进行中的恢复
+	wait    time.Duration   // 恢复后第一次Seen等待恢复完成的最长时间
+}
+
+// NewDedupWindow -
+func NewDedupWindow(horizon time.Duration) *DedupWindow {
+	return &DedupWindow{
+		horizon: horizon.Microseconds(),
+		seen:    make(map[dedupKey]struct{}),
+	}
+}
+
+// FrameHash 帧数据hash, FNV-1a 64
+func FrameHash(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// newDedupKey -
+func newDedupKey(t time.Time, data []byte) dedupKey {
+	return dedupKey{timestamp: t.UnixMicro(), hash: FrameHash(data)}
+}
+
+// Seen 帧在窗口内出现过时返回true, 否则记录该帧
+func (d *DedupWindow) Seen(t time.Time, data []byte) bool {
+	// 只有恢复后的第一帧等待, 超时后的帧在恢复完成后合并
+	d.merge(d.wait)
+	d.wait = 0
+	k := newDedupKey(t, data)
+	if _, ok := d.seen[k]; ok {
+		return true
+	}
+	d.add(k)
+	return false
+}
+
+// Add 记录已存储的帧, 从数据卷恢复窗口时调用
+func (d *DedupWindow) Add(t time.Time, data []byte) {
+	d.add(newDedupKey(t, data))
+}
+
+// loadAsync 在后台协程中调用load恢复窗口, 结果在之后的Seen中合并. 第一次Seen最多等待wait,
+// 超时后恢复完成前只识别已记录的帧
+func (d *DedupWindow) loadAsync(load func() []dedupKey, wait time.Duration) {
+	ch := make(chan []dedupKey, 1)
+	d.loading = ch
+	d.wait = wait
+	go func() {
+		ch <- load()
+	}()
+}
+
+// merge 合并后台恢复的记录, 恢复未完成时最多等待wait
+func (d *DedupWindow) merge(wait time.Duration) {
+	if d.loading == nil {
+		return
+	}
+	var keys []dedupKey
+	select {
+	case keys = <-d.loading:
+	default:
+		if wait <= 0 {
+			return
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case keys = <-d.loading:
+		case <-timer.C:
+			return
+		}
+	}
+	d.loading = nil
+	for _, k := range keys {
+		d.add(k)
+	}
+}
+
+// Len 窗口中的记录数
+func (d *DedupWindow) Len() int {
+	return len(d.seen)
+}
+
+func (d *DedupWindow) add(k dedupKey) {
+	if k.timestamp < d.newest-d.horizon {
+		// 超出窗口, 不再记录
+		return
+	}
+	if _, ok := d.seen[k]; ok {
+		return
+	}
+	d.seen[k] = struct{}{}
+	d.order = append(d.order, k)
+	if k.timestamp > d.newest {
+		d.newest = k.timestamp
+		d.evict()
+	}
+}
+
+// evict 按记录顺序淘汰超出窗口的记录
+func (d *DedupWindow) evict() {
+	cutoff := d.newest - d.horizon
+	i := 0
+	for ; i < len(d.order) && d.order[i].timestamp < cutoff; i++ {
+		delete(d.seen, d.order[i])
+	}
+	if i == 0 {
+		return
+	}
+	// 复用底层数组, 避免淘汰的记录一直被引用
+	n := copy(d.order, d.order[i:])
+	d.order = d.order[:n]
+}
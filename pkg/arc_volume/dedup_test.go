@@ -0,0 +1,61 @@
+package arc_volume
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDedupWindow(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	Convey("DedupWindow", t, func() {
+		d := NewDedupWindow(time.Second)
+		So(d.Seen(base, []byte{1}), ShouldBeFalse)
+		So(d.Seen(base, []byte{1}), ShouldBeTrue)
+		// same timestamp, different payload
+		So(d.Seen(base, []byte{2}), ShouldBeFalse)
+
+		// the first frames fall out of the horizon
+		So(d.Seen(base.Add(2*time.Second), []byte{3}), ShouldBeFalse)
+		So(d.Len(), ShouldEqual, 1)
+		So(d.Seen(base, []byte{1}), ShouldBeFalse)
+	})
+
+	Convey("DedupWindow restored from a volume", t, func() {
+		bf := &ArcVolume{Buffer: &bytes.Buffer{}}
+		bf.Append(base, []byte{1, 1})
+		bf.Append(base.Add(time.Millisecond), []byte{2})
+
+		d := NewDedupWindow(time.Minute)
+		bf.Frames(d.Add)
+		So(d.Seen(base, []byte{1, 1}), ShouldBeTrue)
+		So(d.Seen(base.Add(time.Millisecond), []byte{2}), ShouldBeTrue)
+		So(d.Seen(base.Add(time.Millisecond), []byte{1, 1}), ShouldBeFalse)
+	})
+
+	Convey("DedupWindow loaded from disk volumes", t, func() {
+		dataPath := t.TempDir()
+		b, err := NewArcVolumeCache(&logging.NoopLogger{}, &config.ArcConfig{
+			Work: &config.WorkConfig{DataPath: dataPath, ArcVolumeQueueLen: 4, ArcVolumeQueueNum: 1, ArcVolumeQueueReadTimeoutSeconds: 10},
+		}, "dedup")
+		So(err, ShouldBeNil)
+		defer b.SafeClose()
+
+		dir := dataPath + "/A00000000001/" + base.Format("20060102") + "/" + SegmentTypeArc.Dir
+		_, _, err = b.writer.write(testVolume(dir, base, []byte{1}, []byte{2}), SegmentTypeArc.Ext, testHeader)
+		So(err, ShouldBeNil)
+
+		d := NewDedupWindow(time.Minute)
+		b.LoadDedupWindow(d, "A00000000001", []string{SegmentTypeArc.Name}, base.Add(-time.Minute), base.Add(time.Minute))
+		// a frame already on disk, retried right after the window is created
+		So(d.Seen(base, []byte{1}), ShouldBeTrue)
+		So(d.Len(), ShouldEqual, 2)
+		So(d.Seen(base.Add(time.Second), []byte{2}), ShouldBeTrue)
+		So(d.Seen(base, []byte{3}), ShouldBeFalse)
+	})
+}
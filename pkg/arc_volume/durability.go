@@ -0,0 +1,205 @@
+package arc_volume
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+)
+
+// 数据卷写入的持久化级别
+const (
+	// DurabilityNone 不调用fsync, 由操作系统回写
+	DurabilityNone = "none"
+	// DurabilityVolume 每个数据卷及帧索引写入后fsync
+	DurabilityVolume = "volume"
+	// DurabilityGroup 同一写入队列的多个数据卷合并fsync, 达到字节数或间隔时提交.
+	// 只用于追加方式写入, 重命名方式写入的文件在重命名前必须fsync, 无法合并
+	DurabilityGroup = "group"
+	// DurabilityDir 每个数据卷fsync, 并fsync所在目录, 创建及重命名在崩溃后保留
+	DurabilityDir = "dir"
+)
+
+// checkDurability -
+func checkDurability(durability string) error {
+	switch durability {
+	case DurabilityNone, DurabilityVolume, DurabilityGroup, DurabilityDir:
+		return nil
+	}
+	return fmt.Errorf("unknown durability %q, available: %s, %s, %s, %s",
+		durability, DurabilityNone, DurabilityVolume, DurabilityGroup, DurabilityDir)
+}
+
+// syncCostType fsync耗时在filecache_task_cost中的task_type
+func syncCostType(durability string) string {
+	return "sync_" + durability
+}
+
+// syncPath fsync已关闭的文件或目录
+func syncPath(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("sync %s: %v", name, err)
+	}
+	return nil
+}
+
+// fileSyncer 一次写入中文件的fsync. 组提交时文件保持打开, 重命名后仍可fsync
+type fileSyncer struct {
+	durability string
+	pending    []*os.File
+}
+
+// done 文件写入完成
+func (s *fileSyncer) done(f *os.File) error {
+	switch s.durability {
+	case DurabilityGroup:
+		s.pending = append(s.pending, f)
+		return nil
+	case DurabilityVolume, DurabilityDir:
+		start := time.Now()
+		err := f.Sync()
+		addTaskCostMetric(syncCostType(s.durability), time.Since(start).Seconds())
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("file sync error: %v", err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("file close error: %v", err)
+	}
+	return nil
+}
+
+// dir 创建或重命名文件后fsync所在目录
+func (s *fileSyncer) dir(name string) error {
+	if s.durability != DurabilityDir {
+		return nil
+	}
+	start := time.Now()
+	defer func() {
+		addTaskCostMetric(syncCostType(s.durability), time.Since(start).Seconds())
+	}()
+	return syncPath(path.Dir(name))
+}
+
+// close 写入失败时关闭等待组提交的文件
+func (s *fileSyncer) close() {
+	for _, f := range s.pending {
+		f.Close()
+	}
+	s.pending = nil
+}
+
+// create 创建文件并写入
+func (s *fileSyncer) create(filename string, data ...[]byte) error {
+	if err := os.MkdirAll(path.Dir(filename), os.ModePerm); err != nil {
+		return fmt.Errorf("MKdirAll: %v", err)
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("file create error: %v", err)
+	}
+	for _, b := range data {
+		if _, err := file.Write(b); err != nil {
+			file.Close()
+			return fmt.Errorf("file write error: %v", err)
+		}
+	}
+	return s.done(file)
+}
+
+// createAtomic 写入临时文件, 按持久化级别fsync后重命名, 失败时删除临时文件.
+// 重命名前必须完成fsync, 组提交时临时文件单独fsync. 不fsync时只保证读取方看不到写入中的文件
+func (s *fileSyncer) createAtomic(filename string, data ...[]byte) error {
+	tmp := filename + tmpFileExt
+	c := s
+	if s.durability == DurabilityGroup {
+		c = &fileSyncer{durability: DurabilityVolume}
+	}
+	if err := c.create(tmp, data...); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename: %v", err)
+	}
+	return nil
+}
+
+// groupCommit 组提交. 写入任务写完文件后释放队列, 等待同一批次的文件一起fsync
+type groupCommit struct {
+	bytes    int64
+	interval time.Duration
+
+	mu      sync.Mutex
+	files   []*os.File
+	size    int64
+	waiters []chan error
+	timer   *time.Timer
+}
+
+// newGroupCommit -
+func newGroupCommit(bytes int64, interval time.Duration) *groupCommit {
+	return &groupCommit{
+		bytes:    bytes,
+		interval: interval,
+	}
+}
+
+// commit 加入当前批次并等待fsync, 文件在fsync后关闭. 批次数据达到bytes时立即提交, 否则在第一个文件加入interval后提交
+func (g *groupCommit) commit(files []*os.File, size int64) error {
+	done := make(chan error, 1)
+
+	g.mu.Lock()
+	g.files = append(g.files, files...)
+	g.size += size
+	g.waiters = append(g.waiters, done)
+	if g.bytes > 0 && g.size >= g.bytes {
+		g.mu.Unlock()
+		g.flush()
+	} else {
+		if g.timer == nil {
+			g.timer = time.AfterFunc(g.interval, g.flush)
+		}
+		g.mu.Unlock()
+	}
+
+	return <-done
+}
+
+// flush fsync当前批次, 结果通知批次中的全部写入任务
+func (g *groupCommit) flush() {
+	g.mu.Lock()
+	files, waiters := g.files, g.waiters
+	g.files, g.size, g.waiters = nil, 0, nil
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	g.mu.Unlock()
+	if len(waiters) == 0 {
+		return
+	}
+
+	s := time.Now()
+	var err error
+	for _, f := range files {
+		if e := f.Sync(); e != nil && err == nil {
+			err = fmt.Errorf("file sync error: %v", e)
+		}
+		f.Close()
+	}
+	addTaskCostMetric(syncCostType(DurabilityGroup), time.Since(s).Seconds())
+
+	for _, w := range waiters {
+		w <- err
+	}
+}
@@ -0,0 +1,76 @@
+package arc_volume
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDurability(t *testing.T) {
+	CaseDurability(t)
+	CaseGroupCommit(t)
+}
+
+func CaseDurability(t *testing.T) {
+	Convey("Durability", t, func() {
+		for _, d := range []string{DurabilityNone, DurabilityVolume, DurabilityDir} {
+			w, err := newVolumeWriter(&config.WorkConfig{WriteMode: WriteModeRename, Durability: d})
+			So(err, ShouldBeNil)
+			_, pending, err := w.write(testVolume(t.TempDir(), time.Now(), []byte{1}), ".arc", testHeader)
+			So(err, ShouldBeNil)
+			So(pending, ShouldBeEmpty)
+		}
+
+		// the rename is not visible before the fsync, nothing is left for the group commit
+		_, err := newVolumeWriter(&config.WorkConfig{WriteMode: WriteModeRename, Durability: DurabilityGroup})
+		So(err, ShouldNotBeNil)
+
+		_, err = newVolumeWriter(&config.WorkConfig{Durability: "always"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func CaseGroupCommit(t *testing.T) {
+	Convey("GroupCommit", t, func() {
+		w, err := newVolumeWriter(&config.WorkConfig{WriteMode: WriteModeAppend, Durability: DurabilityGroup, SaveDuration: "hour", SaveNum: 1})
+		So(err, ShouldBeNil)
+
+		// files stay open for the batch, even after the append renames the volume
+		dir := t.TempDir()
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		_, first, err := w.write(testVolume(dir, start, []byte{1}), ".arc", testHeader)
+		So(err, ShouldBeNil)
+		So(len(first), ShouldEqual, 2)
+		_, second, err := w.write(testVolume(dir, start.Add(time.Second), []byte{2}), ".arc", testHeader)
+		So(err, ShouldBeNil)
+		So(len(second), ShouldEqual, 2)
+
+		g := newGroupCommit(1<<20, 50*time.Millisecond)
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, files := range [][]*os.File{first, second} {
+			wg.Add(1)
+			go func(i int, files []*os.File) {
+				defer wg.Done()
+				errs[i] = g.commit(files, 1)
+			}(i, files)
+		}
+		wg.Wait()
+		So(errs, ShouldResemble, []error{nil, nil})
+
+		// closed after the batch
+		_, err = first[0].Stat()
+		So(err, ShouldNotBeNil)
+
+		Convey("size", func() {
+			g := newGroupCommit(1, time.Hour)
+			_, files, err := w.write(testVolume(dir, start.Add(2*time.Second), []byte{3}), ".arc", testHeader)
+			So(err, ShouldBeNil)
+			So(g.commit(files, 1), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package arc_volume
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// IndexFileType 帧索引文件后缀, 与数据卷同名: <volume>.arc.idx
+	IndexFileType = ".idx"
+
+	// frameIndexSize 每条索引长度: timestamp(8) + offset(8)
+	frameIndexSize = 16
+)
+
+// FrameIndex 帧时间戳与数据卷内字节偏移的映射
+type FrameIndex struct {
+	Timestamp int64 // 帧时间戳, 单位:us
+	Offset    int64 // 帧数据在数据卷中的起始偏移
+}
+
+// encodeFrameIndex 索引编码, BigEndian
+func encodeFrameIndex(entries []FrameIndex) []byte {
+	buf := make([]byte, len(entries)*frameIndexSize)
+	for i, e := range entries {
+		binary.BigEndian.PutUint64(buf[i*frameIndexSize:], uint64(e.Timestamp))
+		binary.BigEndian.PutUint64(buf[i*frameIndexSize+8:], uint64(e.Offset))
+	}
+	return buf
+}
+
+// decodeFrameIndex 索引解码
+func decodeFrameIndex(data []byte) ([]FrameIndex, error) {
+	if len(data)%frameIndexSize != 0 {
+		return nil, fmt.Errorf("invalid frame index size(%d)", len(data))
+	}
+	entries := make([]FrameIndex, len(data)/frameIndexSize)
+	for i := range entries {
+		entries[i].Timestamp = int64(binary.BigEndian.Uint64(data[i*frameIndexSize:]))
+		entries[i].Offset = int64(binary.BigEndian.Uint64(data[i*frameIndexSize+8:]))
+	}
+	return entries, nil
+}
+
+// writeFrameIndex 创建数据卷索引文件
+func writeFrameIndex(filename string, entries []FrameIndex) error {
+	if err := ioutil.WriteFile(filename, encodeFrameIndex(entries), 0644); err != nil {
+		return fmt.Errorf("write frame index: %v", err)
+	}
+	return nil
+}
+
+// readFrameIndex 读取数据卷索引文件
+func readFrameIndex(filename string) ([]FrameIndex, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return decodeFrameIndex(data)
+}
+
+// seekFrames 根据索引计算[t1,t2)对应的帧序号范围[i,j).
+// 起始为包含t1的帧, 结束为第一个时间戳不小于t2的帧.
+func seekFrames(entries []FrameIndex, t1, t2 time.Time) (i, j int) {
+	from := t1.UnixMicro()
+	to := t2.UnixMicro()
+
+	// 第一个时间戳大于t1的帧, 其前一帧包含t1
+	i = sort.Search(len(entries), func(i int) bool { return entries[i].Timestamp > from })
+	if i > 0 {
+		i--
+	}
+	j = sort.Search(len(entries), func(j int) bool { return entries[j].Timestamp >= to })
+	if j < i {
+		j = i
+	}
+	return i, j
+}
+
+// frameEnd 第i帧在数据卷中的结束偏移
+func frameEnd(entries []FrameIndex, fileSize int64, i int) int64 {
+	if i+1 < len(entries) && entries[i+1].Offset < fileSize {
+		return entries[i+1].Offset
+	}
+	return fileSize
+}
+
+// seekFrameRange 根据索引计算[t1,t2)在数据卷中的字节范围.
+func seekFrameRange(entries []FrameIndex, fileSize int64, t1, t2 time.Time) (start, end int64) {
+	if len(entries) == 0 {
+		return 0, fileSize
+	}
+	i, j := seekFrames(entries, t1, t2)
+	if i == j {
+		return entries[i].Offset, entries[i].Offset
+	}
+
+	start = entries[i].Offset
+	end = frameEnd(entries, fileSize, j-1)
+	if start > end {
+		start = end
+	}
+	return start, end
+}
+
+// frameCoverEnd 数据卷[t1,t2)读取结果覆盖的结束时间: 第一个未读取帧的时间戳.
+// 读取到数据卷末尾时, 按平均帧间隔估算最后一帧的结束时间.
+func frameCoverEnd(entries []FrameIndex, t2 time.Time) time.Time {
+	n := len(entries)
+	if n == 0 {
+		return time.Time{}
+	}
+	to := t2.UnixMicro()
+	j := sort.Search(n, func(j int) bool { return entries[j].Timestamp >= to })
+	if j < n {
+		return time.UnixMicro(entries[j].Timestamp)
+	}
+
+	last := entries[n-1].Timestamp
+	if n > 1 {
+		last += (last - entries[0].Timestamp) / int64(n-1)
+	}
+	return time.UnixMicro(last)
+}
+
+// sortFrames 帧数据按时间戳重新排列, 时间戳相同时保持原顺序. 已排序时原样返回
+func sortFrames(data []byte, entries []FrameIndex) ([]byte, []FrameIndex) {
+	if sort.SliceIsSorted(entries, func(i, j int) bool { return entries[i].Timestamp < entries[j].Timestamp }) {
+		return data, entries
+	}
+	size := int64(len(data))
+	type frame struct {
+		timestamp  int64
+		start, end int64
+	}
+	frames := make([]frame, len(entries))
+	for i, e := range entries {
+		frames[i] = frame{timestamp: e.Timestamp, start: e.Offset, end: frameEnd(entries, size, i)}
+	}
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].timestamp < frames[j].timestamp })
+
+	sorted := make([]byte, 0, len(data))
+	index := make([]FrameIndex, len(frames))
+	for i, f := range frames {
+		index[i] = FrameIndex{Timestamp: f.timestamp, Offset: int64(len(sorted))}
+		sorted = append(sorted, data[f.start:f.end]...)
+	}
+	return sorted, index
+}
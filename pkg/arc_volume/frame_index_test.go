@@ -0,0 +1,59 @@
+package arc_volume
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func CaseFrameIndexCodec(t *testing.T) {
+	Convey("FrameIndexCodec", t, func() {
+		entries := []FrameIndex{{Timestamp: 1, Offset: 0}, {Timestamp: 2, Offset: 100}}
+		got, err := decodeFrameIndex(encodeFrameIndex(entries))
+		So(err, ShouldBeNil)
+		So(got, ShouldResemble, entries)
+
+		_, err = decodeFrameIndex([]byte{0x01})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func CaseSeekFrameRange(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	// 4 frames, 1s each, 100 bytes each
+	entries := []FrameIndex{}
+	for i := 0; i < 4; i++ {
+		entries = append(entries, FrameIndex{
+			Timestamp: base.Add(time.Duration(i) * time.Second).UnixMicro(),
+			Offset:    int64(i * 100),
+		})
+	}
+
+	Convey("SeekFrameRange", t, func() {
+		Convey("inside", func() {
+			start, end := seekFrameRange(entries, 400, base.Add(1500*time.Millisecond), base.Add(3*time.Second))
+			So(start, ShouldEqual, 100)
+			So(end, ShouldEqual, 300)
+		})
+		Convey("cover", func() {
+			start, end := seekFrameRange(entries, 400, base.Add(-time.Hour), base.Add(time.Hour))
+			So(start, ShouldEqual, 0)
+			So(end, ShouldEqual, 400)
+		})
+		Convey("before", func() {
+			start, end := seekFrameRange(entries, 400, base.Add(-time.Hour), base.Add(-time.Minute))
+			So(end-start, ShouldEqual, 0)
+		})
+		Convey("no index", func() {
+			start, end := seekFrameRange(nil, 400, base, base.Add(time.Second))
+			So(start, ShouldEqual, 0)
+			So(end, ShouldEqual, 400)
+		})
+	})
+}
+
+func TestFrameIndex(t *testing.T) {
+	CaseFrameIndexCodec(t)
+	CaseSeekFrameRange(t)
+}
@@ -0,0 +1,183 @@
+package arc_volume
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+)
+
+const (
+	// VolumeMagic 数据卷文件头标识
+	VolumeMagic = "ARCV"
+	// VolumeVersion 当前文件头版本. 2: 增加数据段类型码
+	VolumeVersion = 2
+	// volumeHeaderPrefix magic(4) version(2) headerSize(2)
+	volumeHeaderPrefix = 8
+	// volumeHeaderFixed prefix + createTime(8) saveTime(8) sampleRate(4) channel(2) sampleWidth(2)
+	volumeHeaderFixed = volumeHeaderPrefix + 24
+	// volumeHeaderMaxString 字符串字段的长度为1字节, 超出的部分被截断
+	volumeHeaderMaxString = 255
+)
+
+var (
+	// errNoVolumeHeader 旧格式数据卷, 没有文件头
+	errNoVolumeHeader = errors.New("volume header not found")
+	// errVolumeHeaderCorrupt -
+	errVolumeHeaderCorrupt = errors.New("volume header corrupt")
+)
+
+// VolumeHeader 数据卷文件头, 文件被复制或重命名后仍可识别数据归属.
+// 布局(BigEndian): magic(4) version(2) headerSize(2) createTime(8,us) saveTime(8,us)
+// sampleRate(4) channel(2) sampleWidth(2) sensorID(1+n) type(1+n) serviceVersion(1+n) sType(1) crc32(4).
+// 帧索引中的偏移相对于文件头之后的数据区.
+type VolumeHeader struct {
+	Version        uint16
+	SensorID       string
+	Type           string
+	SType          byte // protocols 数据段类型, 版本1的文件头为0
+	CreateTime     time.Time
+	SaveTime       time.Time
+	Profile        config.SensorProfile
+	ServiceVersion string
+}
+
+// encodeVolumeHeader 超过volumeHeaderMaxString字节的字符串字段被截断
+func encodeVolumeHeader(h *VolumeHeader) []byte {
+	fields := []string{h.SensorID, h.Type, h.ServiceVersion}
+	size := volumeHeaderFixed + 1 + 4
+	for i, s := range fields {
+		if len(s) > volumeHeaderMaxString {
+			fields[i] = s[:volumeHeaderMaxString]
+		}
+		size += 1 + len(fields[i])
+	}
+	b := make([]byte, size)
+	copy(b[0:4], VolumeMagic)
+	binary.BigEndian.PutUint16(b[4:6], VolumeVersion)
+	binary.BigEndian.PutUint16(b[6:8], uint16(size))
+	binary.BigEndian.PutUint64(b[8:16], uint64(h.CreateTime.UnixMicro()))
+	binary.BigEndian.PutUint64(b[16:24], uint64(h.SaveTime.UnixMicro()))
+	binary.BigEndian.PutUint32(b[24:28], uint32(h.Profile.SampleRate))
+	binary.BigEndian.PutUint16(b[28:30], uint16(h.Profile.Channel))
+	binary.BigEndian.PutUint16(b[30:32], uint16(h.Profile.SampleWidth))
+	off := volumeHeaderFixed
+	for _, s := range fields {
+		b[off] = byte(len(s))
+		off += 1 + copy(b[off+1:], s)
+	}
+	b[off] = h.SType
+	off++
+	binary.BigEndian.PutUint32(b[off:], crc32.ChecksumIEEE(b[:off]))
+	return b
+}
+
+// volumeHeaderSize 根据前缀判断是否有文件头, 返回文件头大小
+func volumeHeaderSize(prefix []byte) (int, error) {
+	if len(prefix) < volumeHeaderPrefix || string(prefix[0:4]) != VolumeMagic {
+		return 0, errNoVolumeHeader
+	}
+	size := int(binary.BigEndian.Uint16(prefix[6:8]))
+	if size < volumeHeaderFixed+3+4 {
+		return 0, errNoVolumeHeader
+	}
+	return size, nil
+}
+
+// decodeVolumeHeader 高版本追加的字段位于crc之前, 低版本读取时忽略
+func decodeVolumeHeader(b []byte) (*VolumeHeader, error) {
+	size, err := volumeHeaderSize(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < size {
+		return nil, errVolumeHeaderCorrupt
+	}
+	b = b[:size]
+	if crc32.ChecksumIEEE(b[:size-4]) != binary.BigEndian.Uint32(b[size-4:]) {
+		// 旧格式数据恰好以magic开头
+		return nil, errNoVolumeHeader
+	}
+
+	h := &VolumeHeader{
+		Version:    binary.BigEndian.Uint16(b[4:6]),
+		CreateTime: time.UnixMicro(int64(binary.BigEndian.Uint64(b[8:16]))),
+		SaveTime:   time.UnixMicro(int64(binary.BigEndian.Uint64(b[16:24]))),
+		Profile: config.SensorProfile{
+			SampleRate:  int(binary.BigEndian.Uint32(b[24:28])),
+			Channel:     int(binary.BigEndian.Uint16(b[28:30])),
+			SampleWidth: int(binary.BigEndian.Uint16(b[30:32])),
+		},
+	}
+	off := volumeHeaderFixed
+	fields := []*string{&h.SensorID, &h.Type, &h.ServiceVersion}
+	for _, field := range fields {
+		if off >= size-4 {
+			return nil, errVolumeHeaderCorrupt
+		}
+		n := int(b[off])
+		if off+1+n > size-4 {
+			return nil, errVolumeHeaderCorrupt
+		}
+		*field = string(b[off+1 : off+1+n])
+		off += 1 + n
+	}
+	if off < size-4 {
+		h.SType = b[off]
+	}
+	return h, nil
+}
+
+// readVolumeHeader 读取已打开数据卷的文件头, 返回文件头及数据区偏移. 旧格式数据卷返回nil, 0
+func readVolumeHeader(f io.ReaderAt) (*VolumeHeader, int64) {
+	prefix := make([]byte, volumeHeaderPrefix)
+	if _, err := f.ReadAt(prefix, 0); err != nil {
+		return nil, 0
+	}
+	size, err := volumeHeaderSize(prefix)
+	if err != nil {
+		return nil, 0
+	}
+	b := make([]byte, size)
+	if _, err := f.ReadAt(b, 0); err != nil {
+		return nil, 0
+	}
+	h, err := decodeVolumeHeader(b)
+	if err != nil {
+		return nil, 0
+	}
+	return h, int64(size)
+}
+
+// ReadVolumeHeader 读取数据卷文件头, 旧格式数据卷返回错误
+func ReadVolumeHeader(filename string) (*VolumeHeader, error) {
+	f, err := OpenVolume(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h, _ := readVolumeHeader(f)
+	if h == nil {
+		return nil, errNoVolumeHeader
+	}
+	return h, nil
+}
+
+// volumeHeader 写入数据卷的文件头
+func (b *ArcVolumeCache) volumeHeader(cc *ArcVolume) []byte {
+	st, _ := SegmentTypeByName(cc.Type)
+	return encodeVolumeHeader(&VolumeHeader{
+		Version:        VolumeVersion,
+		SensorID:       cc.SensorID,
+		Type:           cc.Type,
+		SType:          st.SType,
+		CreateTime:     cc.CreateTime,
+		SaveTime:       cc.SaveTime,
+		Profile:        b.config.Sensor.GetProfile(cc.SensorID),
+		ServiceVersion: b.Version,
+	})
+}
@@ -0,0 +1,83 @@
+package arc_volume
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVolumeHeader(t *testing.T) {
+	CaseVolumeHeaderCodec(t)
+	CaseVolumeRangeWithHeader(t)
+}
+
+func CaseVolumeHeaderCodec(t *testing.T) {
+	Convey("VolumeHeaderCodec", t, func() {
+		h := &VolumeHeader{
+			Version:        VolumeVersion,
+			SensorID:       "A00000000001",
+			Type:           "Arc",
+			SType:          10,
+			CreateTime:     time.UnixMicro(1700000000000000),
+			SaveTime:       time.UnixMicro(1700000060000000),
+			Profile:        config.SensorProfile{SampleRate: 8000, Channel: 1, SampleWidth: 2},
+			ServiceVersion: "v1.0.0",
+		}
+		b := encodeVolumeHeader(h)
+		got, n := readVolumeHeader(bytes.NewReader(append(b, 1, 2, 3)))
+		So(n, ShouldEqual, len(b))
+		So(got, ShouldResemble, h)
+
+		Convey("long fields", func() {
+			long := *h
+			long.ServiceVersion = strings.Repeat("v", 300)
+			got, n := readVolumeHeader(bytes.NewReader(encodeVolumeHeader(&long)))
+			So(n, ShouldBeGreaterThan, 0)
+			So(got.ServiceVersion, ShouldEqual, strings.Repeat("v", volumeHeaderMaxString))
+			So(got.SType, ShouldEqual, h.SType)
+		})
+
+		Convey("old layout", func() {
+			got, n := readVolumeHeader(bytes.NewReader([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9}))
+			So(got, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+		Convey("corrupt", func() {
+			b[10]++
+			got, n := readVolumeHeader(bytes.NewReader(b))
+			So(got, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+	})
+}
+
+func CaseVolumeRangeWithHeader(t *testing.T) {
+	Convey("VolumeRangeWithHeader", t, func() {
+		base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		header := encodeVolumeHeader(&VolumeHeader{SensorID: "A00000000001", Type: "Arc", CreateTime: base, SaveTime: base.Add(2 * time.Second)})
+		filename := filepath.Join(t.TempDir(), "renamed.arc")
+		So(os.WriteFile(filename, append(header, make([]byte, 200)...), 0644), ShouldBeNil)
+
+		// without index the whole data section, save time from header
+		start, end, coverEnd, err := volumeRange(filename, base, base.Add(time.Hour))
+		So(err, ShouldBeNil)
+		So(start, ShouldEqual, len(header))
+		So(end, ShouldEqual, len(header)+200)
+		So(coverEnd.Equal(base.Add(2*time.Second)), ShouldBeTrue)
+
+		So(writeFrameIndex(filename+IndexFileType, []FrameIndex{
+			{Timestamp: base.UnixMicro(), Offset: 0},
+			{Timestamp: base.Add(time.Second).UnixMicro(), Offset: 100},
+		}), ShouldBeNil)
+		start, end, _, err = volumeRange(filename, base.Add(time.Second), base.Add(time.Hour))
+		So(err, ShouldBeNil)
+		So(start, ShouldEqual, len(header)+100)
+		So(end, ShouldEqual, len(header)+200)
+	})
+}
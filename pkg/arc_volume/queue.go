@@ -18,6 +18,8 @@ type Tasker interface {
 	start()
 	waitComplete() chan struct{}
 	complete()
+	waitRelease() chan struct{}
+	release()
 	setExpire()
 	isExpire() bool
 	getQueueIDSourceKey() string
@@ -35,8 +37,10 @@ type task struct {
 	ctx              context.Context // 超时控制
 	startCh          chan struct{}   // 用于等待异步任务开始执行，通过此通道变量控制任务是否开始执行
 	completeCh       chan struct{}   // 用于等待异步任务执行，通过此通道变量控制任务是否执行完毕
+	releaseCh        chan struct{}   // 队列继续执行下一个任务, 组提交的写入任务在等待fsync前释放
 	expire           atomic.Value    // 设置任务失效，已经进入队列的任务，在失效后会跳过执行
 	taskType         string          // 任务类型
+	releaseOnce      sync.Once
 }
 
 func newTask(ctx context.Context, queueIDSourceKey string, taskType string) *task {
@@ -46,6 +50,7 @@ func newTask(ctx context.Context, queueIDSourceKey string, taskType string) *tas
 		ctx:              ctx,
 		startCh:          make(chan struct{}),
 		completeCh:       make(chan struct{}),
+		releaseCh:        make(chan struct{}),
 		expire:           atomic.Value{},
 		taskType:         taskType,
 	}
@@ -64,8 +69,17 @@ func (t *task) waitComplete() chan struct{} {
 	return t.completeCh
 }
 func (t *task) complete() {
+	t.release()
 	close(t.completeCh)
 }
+func (t *task) waitRelease() chan struct{} {
+	return t.releaseCh
+}
+func (t *task) release() {
+	t.releaseOnce.Do(func() {
+		close(t.releaseCh)
+	})
+}
 func (t *task) setExpire() {
 	t.expire.Store(1)
 }
@@ -89,6 +103,7 @@ type queue struct {
 	queueID int
 	taskCh  chan Tasker
 	once    sync.Once
+	group   *groupCommit // 持久化级别为group时, 队列中写入任务的fsync合并提交
 }
 
 func newQueue(logger logging.ILogger, queueID int, l int) *queue {
@@ -127,7 +142,7 @@ func (q *queue) start() {
 		select {
 		case <-task.getCtx().Done():
 			break
-		case <-task.waitComplete():
+		case <-task.waitRelease():
 			break
 		}
 
@@ -169,6 +184,13 @@ func NewQueue(logger logging.ILogger, l int, n int) *Queue {
 	return rwq
 }
 
+// setGroupCommit 每个队列的写入任务合并fsync
+func (rwq *Queue) setGroupCommit(bytes int64, interval time.Duration) {
+	for _, q := range rwq.queueList {
+		q.group = newGroupCommit(bytes, interval)
+	}
+}
+
 func (rwq *Queue) getQueue(queueIDSourceKey string) *queue {
 	value := util.BytesToUint64([]byte(queueIDSourceKey))
 	hashKey := uint64(rwq.queueNum - 1)
@@ -3,6 +3,8 @@ package arc_volume
 import (
 	"context"
 	"net/http"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/kiga-hub/arc/utils"
@@ -11,6 +13,9 @@ import (
 // 任务种类
 const taskTypeRead = "read"
 const taskTypeWrite = "write"
+const taskTypeCompress = "compress"
+const taskTypeCompact = "compact"
+const taskTypeRetention = "retention"
 
 // 读取任务 - 该自定义类型需要继承task，并确保实现Tasker接口
 type readTask struct {
@@ -23,6 +28,7 @@ type readTask struct {
 	paramEnd      time.Time
 
 	data []byte
+	end  time.Time // 磁盘数据覆盖的结束时间
 	err  *utils.ResponseV2
 }
 
@@ -40,7 +46,7 @@ func createReadTask(ctx context.Context, queueIDSourceKey string, bfc *ArcVolume
 
 func (t *readTask) handle() {
 	s := time.Now()
-	t.data, t.err = t.handleObj.readDataLogic(t.ctx, t.paramSensorID, t.paramFileType, t.paramStart, t.paramEnd)
+	t.data, t.end, t.err = t.handleObj.readDataLogic(t.ctx, t.paramSensorID, t.paramFileType, t.paramStart, t.paramEnd)
 	if t.err != nil && t.err.Code == http.StatusGatewayTimeout { //超时
 		addTaskTimeoutTimesMetric(t.getTaskType())
 	} else {
@@ -81,10 +87,216 @@ func (t *writeTask) handle() {
 		addTaskCostMetric(t.getTaskType(), time.Since(s).Seconds())
 	}()
 
-	t.err = t.handleObj.writeDataLogic(t.ctx, t.paramData)
+	var files []*os.File
+	files, t.err = t.handleObj.writeDataLogic(t.ctx, t.paramData)
+	if t.err != nil || len(files) == 0 {
+		return
+	}
+
+	// the next task in the queue joins the same fsync batch
+	t.release()
+	group := t.handleObj.queue.getQueue(t.getQueueIDSourceKey()).group
+	if t.err = group.commit(files, int64(t.paramData.Buffer.Len())); t.err != nil {
+		t.handleObj.exportMetrics.SetDiskWriteErrorLabelValues(t.paramData.SensorID)
+		t.handleObj.logger.Errorw("groupCommit", "sensorID", t.paramData.SensorID, "err", t.err)
+	}
 }
 func (t *writeTask) timeout() {
 	if t.err == nil {
 		t.err = http.ErrHandlerTimeout
 	}
 }
+
+// 压缩任务, 与同一传感器的写入任务串行. 追加模式下被压缩的数据卷不再追加, 之后的数据写入新的数据卷
+type compressTask struct {
+	*task
+
+	handleObj      *ArcVolumeCache
+	paramFilename  string
+	paramCodec     Codec
+	paramBlockSize int
+
+	size   int64
+	stored int64
+	err    error
+}
+
+func createCompressTask(ctx context.Context, queueIDSourceKey string, bfc *ArcVolumeCache, filename string, codec Codec, blockSize int) *compressTask {
+	return &compressTask{
+		task: newTask(ctx, queueIDSourceKey, taskTypeCompress),
+
+		handleObj:      bfc,
+		paramFilename:  filename,
+		paramCodec:     codec,
+		paramBlockSize: blockSize,
+	}
+}
+
+func (t *compressTask) handle() {
+	s := time.Now()
+	t.handleObj.writer.forget(t.paramFilename)
+	t.size, t.stored, t.err = compressVolume(t.paramFilename, t.paramCodec, t.paramBlockSize)
+	addTaskCostMetric(t.getTaskType(), time.Since(s).Seconds())
+}
+func (t *compressTask) timeout() {
+	if t.err == nil {
+		t.err = context.DeadlineExceeded
+	}
+}
+
+// 合并任务, 与同一传感器的读写任务串行
+type compactTask struct {
+	*task
+
+	handleObj   *ArcVolumeCache
+	paramGroup  []*compactSource
+	paramTarget string
+
+	err error
+}
+
+func createCompactTask(ctx context.Context, queueIDSourceKey string, bfc *ArcVolumeCache, group []*compactSource, target string) *compactTask {
+	return &compactTask{
+		task: newTask(ctx, queueIDSourceKey, taskTypeCompact),
+
+		handleObj:   bfc,
+		paramGroup:  group,
+		paramTarget: target,
+	}
+}
+
+func (t *compactTask) handle() {
+	s := time.Now()
+	t.err = t.handleObj.mergeVolumes(t.paramGroup, t.paramTarget)
+	addTaskCostMetric(t.getTaskType(), time.Since(s).Seconds())
+}
+func (t *compactTask) timeout() {
+	if t.err == nil {
+		t.err = context.DeadlineExceeded
+	}
+}
+
+// 保留策略删除任务, 与同一传感器的读写任务串行
+type retentionTask struct {
+	*task
+
+	handleObj     *ArcVolumeCache
+	paramDeletion RetentionDeletion
+
+	err error
+}
+
+func createRetentionTask(ctx context.Context, queueIDSourceKey string, bfc *ArcVolumeCache, d RetentionDeletion) *retentionTask {
+	return &retentionTask{
+		task: newTask(ctx, queueIDSourceKey, taskTypeRetention),
+
+		handleObj:     bfc,
+		paramDeletion: d,
+	}
+}
+
+func (t *retentionTask) handle() {
+	s := time.Now()
+	t.err = t.handleObj.deleteRetention(t.paramDeletion)
+	addTaskCostMetric(t.getTaskType(), time.Since(s).Seconds())
+}
+func (t *retentionTask) timeout() {
+	if t.err == nil {
+		t.err = context.DeadlineExceeded
+	}
+}
+
+// 去重窗口读取任务, 与同一传感器的写入任务串行, 不会读到写入中的帧索引
+type dedupTask struct {
+	*task
+
+	handleObj     *ArcVolumeCache
+	paramSensorID string
+	paramFileType string
+	paramStart    time.Time
+	paramEnd      time.Time
+
+	keys []dedupKey
+	err  error
+}
+
+func createDedupTask(ctx context.Context, queueIDSourceKey string, bfc *ArcVolumeCache, sensorID, fileType string, t1, t2 time.Time) *dedupTask {
+	return &dedupTask{
+		task: newTask(ctx, queueIDSourceKey, taskTypeRead),
+
+		handleObj:     bfc,
+		paramSensorID: sensorID,
+		paramFileType: fileType,
+		paramStart:    t1,
+		paramEnd:      t2,
+	}
+}
+
+func (t *dedupTask) handle() {
+	s := time.Now()
+	var keys []dedupKey
+	_, err := t.handleObj.ReadFrames(t.ctx, t.paramSensorID, t.paramFileType, t.paramStart, t.paramEnd, func(ft time.Time, data []byte) error {
+		keys = append(keys, newDedupKey(ft, data))
+		return nil
+	})
+	t.keys, t.err = keys, err
+	addTaskCostMetric(t.getTaskType(), time.Since(s).Seconds())
+}
+func (t *dedupTask) timeout() {
+	if t.err == nil {
+		t.err = context.DeadlineExceeded
+	}
+}
+
+// 数据流打开任务, 与同一传感器的写入任务串行, 列出并打开数据卷后释放队列
+type streamTask struct {
+	*task
+
+	handleObj     *ArcVolumeCache
+	paramSensorID string
+	paramFileType string
+	paramStart    time.Time
+	paramEnd      time.Time
+
+	mu       sync.Mutex // 超时与执行完成互斥, 超时后打开的数据卷由handle关闭
+	finished bool
+	stream   *VolumeStream
+	err      error
+}
+
+func createStreamTask(ctx context.Context, queueIDSourceKey string, bfc *ArcVolumeCache, sensorID, fileType string, t1, t2 time.Time) *streamTask {
+	return &streamTask{
+		task: newTask(ctx, queueIDSourceKey, taskTypeRead),
+
+		handleObj:     bfc,
+		paramSensorID: sensorID,
+		paramFileType: fileType,
+		paramStart:    t1,
+		paramEnd:      t2,
+	}
+}
+
+func (t *streamTask) handle() {
+	s := time.Now()
+	stream, err := t.handleObj.openStream(t.paramSensorID, t.paramFileType, t.paramStart, t.paramEnd)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		stream.Close()
+		return
+	}
+	t.stream, t.err, t.finished = stream, err, true
+	addTaskCostMetric(t.getTaskType(), time.Since(s).Seconds())
+}
+func (t *streamTask) timeout() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.finished && t.err == nil {
+		t.err = context.DeadlineExceeded
+	}
+}
+func (t *streamTask) result() (*VolumeStream, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.stream, t.err
+}
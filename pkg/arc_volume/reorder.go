@@ -0,0 +1,87 @@
+package arc_volume
+
+import (
+	"sort"
+	"time"
+)
+
+// ReorderFrame 等待排序的帧
+type ReorderFrame struct {
+	Timestamp time.Time
+	Data      []byte
+	WALSeq    uint64 // 帧所在的预写日志段, 数据卷切换时保留
+}
+
+// ReorderBuffer 传感器的乱序窗口. 帧按时间戳排序, 早于最新帧时间戳减去窗口的帧被释放并追加到数据卷.
+// 早于已释放帧的帧无法再排序, 为迟到帧.
+type ReorderBuffer struct {
+	window   time.Duration
+	frames   []ReorderFrame // 按时间戳排序, 时间戳相同时保持到达顺序
+	newest   time.Time
+	released time.Time // 最后释放帧的时间戳
+}
+
+// NewReorderBuffer -
+func NewReorderBuffer(window time.Duration) *ReorderBuffer {
+	return &ReorderBuffer{window: window}
+}
+
+// Late 帧早于已释放的帧
+func (r *ReorderBuffer) Late(t time.Time) bool {
+	return !r.released.IsZero() && t.Before(r.released)
+}
+
+// Push 加入窗口, 返回帧是否早于已收到的最新帧
+func (r *ReorderBuffer) Push(f ReorderFrame) bool {
+	reordered := f.Timestamp.Before(r.newest)
+	i := sort.Search(len(r.frames), func(i int) bool { return r.frames[i].Timestamp.After(f.Timestamp) })
+	r.frames = append(r.frames, ReorderFrame{})
+	copy(r.frames[i+1:], r.frames[i:])
+	r.frames[i] = f
+	if f.Timestamp.After(r.newest) {
+		r.newest = f.Timestamp
+	}
+	return reordered
+}
+
+// Release 返回移出窗口的帧, 按时间戳排序
+func (r *ReorderBuffer) Release() []ReorderFrame {
+	watermark := r.newest.Add(-r.window)
+	n := sort.Search(len(r.frames), func(i int) bool { return r.frames[i].Timestamp.After(watermark) })
+	return r.take(n)
+}
+
+// Drain 释放全部帧, 数据卷超时落盘前调用
+func (r *ReorderBuffer) Drain() []ReorderFrame {
+	return r.take(len(r.frames))
+}
+
+// Len 窗口中的帧数
+func (r *ReorderBuffer) Len() int {
+	return len(r.frames)
+}
+
+// MinWALSeq 窗口中帧所在的最小预写日志段, 窗口为空时返回false
+func (r *ReorderBuffer) MinWALSeq() (uint64, bool) {
+	if len(r.frames) == 0 {
+		return 0, false
+	}
+	min := r.frames[0].WALSeq
+	for _, f := range r.frames[1:] {
+		if f.WALSeq < min {
+			min = f.WALSeq
+		}
+	}
+	return min, true
+}
+
+func (r *ReorderBuffer) take(n int) []ReorderFrame {
+	if n == 0 {
+		return nil
+	}
+	out := make([]ReorderFrame, n)
+	copy(out, r.frames[:n])
+	r.frames = append(r.frames[:0], r.frames[n:]...)
+	r.released = out[n-1].Timestamp
+	return out
+}
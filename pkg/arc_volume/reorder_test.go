@@ -0,0 +1,74 @@
+package arc_volume
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReorder(t *testing.T) {
+	CaseReorderBuffer(t)
+	CaseSortFrames(t)
+}
+
+func CaseReorderBuffer(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+	timestamps := func(frames []ReorderFrame) []int {
+		ms := []int{}
+		for _, f := range frames {
+			ms = append(ms, int(f.Timestamp.Sub(base).Milliseconds()))
+		}
+		return ms
+	}
+
+	Convey("ReorderBuffer", t, func() {
+		r := NewReorderBuffer(100 * time.Millisecond)
+		So(r.Push(ReorderFrame{Timestamp: at(0), WALSeq: 3}), ShouldBeFalse)
+		So(r.Push(ReorderFrame{Timestamp: at(50), WALSeq: 3}), ShouldBeFalse)
+		So(r.Push(ReorderFrame{Timestamp: at(20), WALSeq: 2}), ShouldBeTrue)
+		So(r.Release(), ShouldBeEmpty)
+		seq, ok := r.MinWALSeq()
+		So(ok, ShouldBeTrue)
+		So(seq, ShouldEqual, 2)
+
+		So(r.Push(ReorderFrame{Timestamp: at(130), WALSeq: 4}), ShouldBeFalse)
+		So(timestamps(r.Release()), ShouldResemble, []int{0, 20})
+		So(r.Late(at(10)), ShouldBeTrue)
+		So(r.Late(at(20)), ShouldBeFalse)
+		So(r.Late(at(40)), ShouldBeFalse)
+
+		So(r.Push(ReorderFrame{Timestamp: at(40), WALSeq: 4}), ShouldBeTrue)
+		So(timestamps(r.Drain()), ShouldResemble, []int{40, 50, 130})
+		So(r.Len(), ShouldEqual, 0)
+		_, ok = r.MinWALSeq()
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("ReorderBuffer without window", t, func() {
+		r := NewReorderBuffer(0)
+		r.Push(ReorderFrame{Timestamp: at(10)})
+		So(timestamps(r.Release()), ShouldResemble, []int{10})
+		So(r.Late(at(5)), ShouldBeTrue)
+	})
+}
+
+func CaseSortFrames(t *testing.T) {
+	Convey("SortFrames", t, func() {
+		bf := &ArcVolume{Buffer: &bytes.Buffer{}}
+		bf.Append(time.UnixMicro(30), []byte{3, 3, 3})
+		bf.Append(time.UnixMicro(10), []byte{1})
+		bf.Append(time.UnixMicro(20), []byte{2, 2})
+
+		data, index := sortFrames(bf.Buffer.Bytes(), bf.Index)
+		So(data, ShouldResemble, []byte{1, 2, 2, 3, 3, 3})
+		So(index, ShouldResemble, []FrameIndex{{10, 0}, {20, 1}, {30, 3}})
+
+		// already sorted
+		data, index = sortFrames(data, index)
+		So(data, ShouldResemble, []byte{1, 2, 2, 3, 3, 3})
+		So(index[2].Offset, ShouldEqual, 3)
+	})
+}
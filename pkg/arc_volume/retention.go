@@ -0,0 +1,357 @@
+package arc_volume
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/util"
+)
+
+// 删除单位
+const (
+	// RetentionDay 天目录内的数据卷全部被删除, 之后删除空的天目录. 未识别的文件保留
+	RetentionDay = "day"
+	// RetentionVolume 数据卷及帧索引
+	RetentionVolume = "volume"
+)
+
+// 删除原因
+const (
+	// RetentionMaxAge 保存时间早于maxAge
+	RetentionMaxAge = "max_age"
+	// RetentionMaxSensorBytes 传感器磁盘占用超过上限
+	RetentionMaxSensorBytes = "max_sensor_bytes"
+	// RetentionMaxTotalBytes dataPath磁盘占用超过上限
+	RetentionMaxTotalBytes = "max_total_bytes"
+)
+
+// RetentionDeletion 一项删除, 写入审计日志
+type RetentionDeletion struct {
+	Time     time.Time `json:"time"` // 删除时间, 预览时为零值
+	SensorID string    `json:"sensorid"`
+	Kind     string    `json:"kind"`
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	From     time.Time `json:"from"` // 最早数据卷的创建时间
+	To       time.Time `json:"to"`   // 最晚数据卷的保存时间
+	Reason   string    `json:"reason"`
+	Rule     string    `json:"rule,omitempty"`    // 生效的规则名称, 空为全局配置
+	Volumes  []string  `json:"volumes,omitempty"` // 天目录内被删除的数据卷
+}
+
+// RetentionReport 一次检查的结果, DryRun时只包含删除计划
+type RetentionReport struct {
+	DryRun     bool                `json:"dry_run"`
+	Deletions  []RetentionDeletion `json:"deletions"`
+	TotalBytes int64               `json:"total_bytes"` // 检查前dataPath中数据卷的磁盘占用
+	Bytes      int64               `json:"bytes"`       // 已删除的大小
+	Failed     int                 `json:"failed"`
+}
+
+// retentionVolume 数据卷及帧索引的磁盘占用
+type retentionVolume struct {
+	sensorID   string
+	day        string
+	path       string
+	createTime time.Time
+	saveTime   time.Time
+	size       int64
+	reason     string // 被选中删除的原因
+}
+
+// StartRetention 定期按保留策略删除数据, stop关闭后退出. SafeClose等待退出
+func (b *ArcVolumeCache) StartRetention(stop <-chan struct{}) {
+	b.runBackground(stop, time.Duration(b.config.Retention.Interval)*time.Second, func(ctx context.Context) {
+		r := b.Retention(ctx, false)
+		if len(r.Deletions) > 0 || r.Failed > 0 {
+			b.logger.Infow("Retention", "deletions", len(r.Deletions), "bytes", r.Bytes, "totalBytes", r.TotalBytes, "failed", r.Failed)
+		}
+	})
+}
+
+// RetentionPlan 保留策略的删除计划, retention.interval内返回缓存的计划, 不随请求扫描数据目录
+func (b *ArcVolumeCache) RetentionPlan() RetentionReport {
+	var interval time.Duration
+	if b.config.Retention != nil {
+		interval = time.Duration(b.config.Retention.Interval) * time.Second
+	}
+	return b.retentionPlan.get(interval, func() interface{} {
+		return b.Retention(context.Background(), true)
+	}).(RetentionReport)
+}
+
+// Retention 按retention配置删除数据, 按时间从早到晚删除. 删除任务与同一传感器的读写任务在同一个队列中执行,
+// 每项删除写入审计日志
+func (b *ArcVolumeCache) Retention(ctx context.Context, dryRun bool) RetentionReport {
+	r := RetentionReport{DryRun: dryRun, Deletions: []RetentionDeletion{}}
+	if b.config.Retention == nil {
+		return r
+	}
+	volumes, err := b.scanRetention()
+	if err != nil {
+		b.logger.Warnw("Retention", "dataPath", b.config.Work.DataPath, "err", err)
+		return r
+	}
+	for _, v := range volumes {
+		r.TotalBytes += v.size
+	}
+	plan := b.planRetention(volumes, time.Now())
+	if dryRun {
+		r.Deletions = plan
+		return r
+	}
+
+	audit, err := openRetentionAudit(b.config.Retention.AuditLog)
+	if err != nil {
+		b.logger.Errorw("openRetentionAudit", "auditLog", b.config.Retention.AuditLog, "err", err)
+		return r
+	}
+	if audit != nil {
+		defer audit.Close()
+	}
+
+	for _, d := range plan {
+		if ctx.Err() != nil {
+			break
+		}
+		// 删除不随ctx取消, 取消时等待执行中的删除完成
+		t := createRetentionTask(context.WithoutCancel(ctx), d.SensorID, b, d)
+		b.queue.DoTask(t)
+		if t.err != nil {
+			r.Failed++
+			b.logger.Errorw("Retention", "path", d.Path, "kind", d.Kind, "err", t.err)
+			continue
+		}
+		d.Time = time.Now()
+		r.Deletions = append(r.Deletions, d)
+		r.Bytes += d.Size
+		if audit != nil {
+			if err := json.NewEncoder(audit).Encode(d); err != nil {
+				b.logger.Errorw("retentionAudit", "path", d.Path, "err", err)
+			}
+		}
+	}
+	return r
+}
+
+// openRetentionAudit 追加写入审计日志, 未配置时返回nil
+func openRetentionAudit(filename string) (*os.File, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(path.Dir(filename), os.ModePerm); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+}
+
+// scanRetention 列出dataPath中的全部数据卷
+func (b *ArcVolumeCache) scanRetention() ([]*retentionVolume, error) {
+	dataPath := b.config.Work.DataPath
+	sensors, err := os.ReadDir(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var volumes []*retentionVolume
+	for _, sensor := range sensors {
+		if !sensor.IsDir() {
+			continue
+		}
+		days, err := os.ReadDir(dataPath + "/" + sensor.Name())
+		if err != nil {
+			continue
+		}
+		for _, day := range days {
+			if _, err := time.Parse("20060102", day.Name()); err != nil || !day.IsDir() {
+				continue
+			}
+			for _, st := range SegmentTypes() {
+				files := map[string]string{}
+				if err := util.GetBigFileLists(dataPath+"/"+sensor.Name()+"/"+day.Name()+"/"+st.Dir, files, st.Ext); err != nil {
+					continue
+				}
+				for _, filename := range files {
+					createTime, saveTime, _, err := util.GetTimeRangeFromFileName(filename)
+					if err != nil {
+						continue
+					}
+					fi, err := os.Stat(filename)
+					if err != nil {
+						continue
+					}
+					v := &retentionVolume{
+						sensorID:   sensor.Name(),
+						day:        day.Name(),
+						path:       filename,
+						createTime: createTime,
+						saveTime:   saveTime,
+						size:       fi.Size(),
+					}
+					if fi, err := os.Stat(filename + IndexFileType); err == nil {
+						v.size += fi.Size()
+					}
+					volumes = append(volumes, v)
+				}
+			}
+		}
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		if !volumes[i].createTime.Equal(volumes[j].createTime) {
+			return volumes[i].createTime.Before(volumes[j].createTime)
+		}
+		return volumes[i].path < volumes[j].path
+	})
+	return volumes, nil
+}
+
+// planRetention 依次按maxAge、传感器上限、总上限从早到晚选出删除的数据卷.
+// 天目录内的数据卷全部被选中时合并为删除天目录
+func (b *ArcVolumeCache) planRetention(volumes []*retentionVolume, now time.Time) []RetentionDeletion {
+	conf := b.config.Retention
+	rules := map[string]string{}
+	sensorBytes := map[string]int64{}
+	var totalBytes int64
+
+	for _, v := range volumes {
+		rule := conf.Rule(v.sensorID)
+		rules[v.sensorID] = rule.Name
+		if rule.MaxAge > 0 && v.saveTime.Before(now.Add(-time.Duration(rule.MaxAge)*time.Second)) {
+			v.reason = RetentionMaxAge
+			continue
+		}
+		sensorBytes[v.sensorID] += v.size
+		totalBytes += v.size
+	}
+
+	for _, v := range volumes {
+		if v.reason != "" {
+			continue
+		}
+		rule := conf.Rule(v.sensorID)
+		if rule.MaxBytes > 0 && sensorBytes[v.sensorID] > rule.MaxBytes {
+			v.reason = RetentionMaxSensorBytes
+			sensorBytes[v.sensorID] -= v.size
+			totalBytes -= v.size
+		}
+	}
+
+	for _, v := range volumes {
+		if conf.MaxTotalBytes <= 0 || totalBytes <= conf.MaxTotalBytes {
+			break
+		}
+		if v.reason != "" {
+			continue
+		}
+		v.reason = RetentionMaxTotalBytes
+		totalBytes -= v.size
+	}
+
+	// a day folder is removed as a whole when every volume in it is selected
+	type dayKey struct{ sensorID, day string }
+	kept := map[dayKey]bool{}
+	for _, v := range volumes {
+		if v.reason == "" {
+			kept[dayKey{v.sensorID, v.day}] = true
+		}
+	}
+
+	plan := []RetentionDeletion{}
+	days := map[dayKey]int{}
+	for _, v := range volumes {
+		if v.reason == "" {
+			continue
+		}
+		k := dayKey{v.sensorID, v.day}
+		if kept[k] {
+			plan = append(plan, RetentionDeletion{
+				SensorID: v.sensorID,
+				Kind:     RetentionVolume,
+				Path:     v.path,
+				Size:     v.size,
+				From:     v.createTime,
+				To:       v.saveTime,
+				Reason:   v.reason,
+				Rule:     rules[v.sensorID],
+			})
+			continue
+		}
+		i, ok := days[k]
+		if !ok {
+			days[k] = len(plan)
+			plan = append(plan, RetentionDeletion{
+				SensorID: v.sensorID,
+				Kind:     RetentionDay,
+				Path:     b.config.Work.DataPath + "/" + v.sensorID + "/" + v.day,
+				From:     v.createTime,
+				Rule:     rules[v.sensorID],
+			})
+			i = days[k]
+		}
+		d := &plan[i]
+		d.Volumes = append(d.Volumes, v.path)
+		d.Size += v.size
+		if v.saveTime.After(d.To) {
+			d.To = v.saveTime
+		}
+		// the reason of the newest volume removes the whole day
+		d.Reason = v.reason
+	}
+	return plan
+}
+
+// deleteRetention 删除数据卷及帧索引, 天目录在其中的数据卷删除后为空时删除
+func (b *ArcVolumeCache) deleteRetention(d RetentionDeletion) error {
+	b.writer.forget(d.Path)
+	switch d.Kind {
+	case RetentionDay:
+		if !strings.HasPrefix(d.Path, b.config.Work.DataPath+"/") {
+			return fmt.Errorf("%s is outside of %s", d.Path, b.config.Work.DataPath)
+		}
+		for _, v := range d.Volumes {
+			if !strings.HasPrefix(v, d.Path+"/") {
+				return fmt.Errorf("%s is outside of %s", v, d.Path)
+			}
+			if err := removeVolume(v); err != nil {
+				return err
+			}
+		}
+		removeEmptyDirs(d.Path)
+		return nil
+	case RetentionVolume:
+		return removeVolume(d.Path)
+	}
+	return fmt.Errorf("unknown retention kind %q", d.Kind)
+}
+
+// removeVolume 删除数据卷及帧索引
+func removeVolume(filename string) error {
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(filename + IndexFileType); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeEmptyDirs 自下而上删除空目录, 包含文件的目录保留
+func removeEmptyDirs(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			removeEmptyDirs(dir + "/" + e.Name())
+		}
+	}
+	// fails while the folder still has content
+	os.Remove(dir)
+}
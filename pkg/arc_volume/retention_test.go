@@ -0,0 +1,113 @@
+package arc_volume
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRetention(t *testing.T) {
+	CaseRetention(t)
+}
+
+func CaseRetention(t *testing.T) {
+	Convey("Retention", t, func() {
+		dataPath := t.TempDir()
+		audit := t.TempDir() + "/audit.log"
+		conf := &config.RetentionConfig{
+			MaxAge:   2 * 86400,
+			AuditLog: audit,
+			Rules:    []config.RetentionRule{{Name: "small", Pattern: "B*", MaxBytes: 100}},
+		}
+		b, err := NewArcVolumeCache(&logging.NoopLogger{}, &config.ArcConfig{
+			Work:      &config.WorkConfig{DataPath: dataPath, ArcVolumeQueueLen: 4, ArcVolumeQueueNum: 1},
+			Retention: conf,
+		}, "retention")
+		So(err, ShouldBeNil)
+		defer b.SafeClose()
+
+		// A: an expired day and today, B: only the newest volume fits, C: an expired day, each volume is 72 bytes
+		today := time.Now().UTC().Truncate(time.Hour)
+		old := today.Add(-5 * 24 * time.Hour)
+		write := func(sensorID string, t time.Time) {
+			cc := testVolume(dataPath+"/"+sensorID+"/"+t.Format("20060102")+"/"+SegmentTypeArc.Dir, t, []byte{1})
+			cc.SensorID = sensorID
+			_, _, err := b.writer.write(cc, SegmentTypeArc.Ext, testHeader)
+			So(err, ShouldBeNil)
+		}
+		write("A00000000001", old)
+		write("A00000000001", old.Add(time.Minute))
+		write("A00000000001", today)
+		write("B00000000001", today)
+		write("B00000000001", today.Add(time.Minute))
+		write("C00000000001", old)
+		// files retention did not scan are kept
+		unscanned := dataPath + "/A00000000001/" + old.Format("20060102") + "/" + SegmentTypeArc.Dir + "/volume" + tmpFileExt
+		So(os.WriteFile(unscanned, []byte{1}, 0644), ShouldBeNil)
+
+		So(conf.Rule("b00000000002").Name, ShouldEqual, "small")
+		So(conf.Rule("A00000000001").MaxAge, ShouldEqual, 2*86400)
+
+		r := b.Retention(context.Background(), true)
+		So(len(r.Deletions), ShouldEqual, 3)
+		So(r.Deletions[0].Kind, ShouldEqual, RetentionDay)
+		So(r.Deletions[0].Path, ShouldEqual, dataPath+"/A00000000001/"+old.Format("20060102"))
+		So(r.Deletions[0].Reason, ShouldEqual, RetentionMaxAge)
+		So(len(r.Deletions[0].Volumes), ShouldEqual, 2)
+		So(r.Deletions[1].Kind, ShouldEqual, RetentionDay)
+		So(r.Deletions[1].SensorID, ShouldEqual, "C00000000001")
+		So(r.Deletions[2].Kind, ShouldEqual, RetentionVolume)
+		So(r.Deletions[2].SensorID, ShouldEqual, "B00000000001")
+		So(r.Deletions[2].Reason, ShouldEqual, RetentionMaxSensorBytes)
+		So(r.Deletions[2].Rule, ShouldEqual, "small")
+		_, err = os.Stat(r.Deletions[0].Path)
+		So(err, ShouldBeNil)
+		So(b.RetentionPlan().Deletions, ShouldResemble, r.Deletions)
+
+		r = b.Retention(context.Background(), false)
+		So(r.Failed, ShouldEqual, 0)
+		So(len(r.Deletions), ShouldEqual, 3)
+		for _, v := range r.Deletions[0].Volumes {
+			_, err = os.Stat(v)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		}
+		_, err = os.Stat(unscanned)
+		So(err, ShouldBeNil)
+		_, err = os.Stat(r.Deletions[1].Path)
+		So(os.IsNotExist(err), ShouldBeTrue)
+		_, err = os.Stat(r.Deletions[2].Path + IndexFileType)
+		So(os.IsNotExist(err), ShouldBeTrue)
+		// the plan is cached within retention.interval
+		So(len(b.RetentionPlan().Deletions), ShouldEqual, 3)
+
+		f, err := os.Open(audit)
+		So(err, ShouldBeNil)
+		defer f.Close()
+		var logged []RetentionDeletion
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			var d RetentionDeletion
+			So(json.Unmarshal(s.Bytes(), &d), ShouldBeNil)
+			logged = append(logged, d)
+		}
+		So(len(logged), ShouldEqual, 3)
+		So(logged[2].Path, ShouldEqual, r.Deletions[2].Path)
+
+		Convey("total", func() {
+			// oldest first across sensors
+			conf.MaxTotalBytes = 100
+			r := b.Retention(context.Background(), true)
+			So(len(r.Deletions), ShouldEqual, 1)
+			So(r.Deletions[0].Kind, ShouldEqual, RetentionDay)
+			So(r.Deletions[0].SensorID, ShouldEqual, "A00000000001")
+			So(r.Deletions[0].Reason, ShouldEqual, RetentionMaxTotalBytes)
+		})
+	})
+}
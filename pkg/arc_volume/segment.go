@@ -0,0 +1,127 @@
+package arc_volume
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/kiga-hub/arc/protocols"
+)
+
+// lateSuffix 迟到帧数据卷的类型名及目录后缀
+const lateSuffix = "Late"
+
+var (
+	// segmentNamePattern 类型名出现在文件名中, 不能包含 "_"
+	segmentNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+	// segmentExtPattern -
+	segmentExtPattern = regexp.MustCompile(`^\.[a-z0-9]+$`)
+
+	segmentMu      sync.RWMutex
+	segmentByName  = map[string]SegmentType{}
+	segmentBySType = map[byte]SegmentType{}
+)
+
+// SegmentType DataGroup数据段类型. 每种类型写入独立的数据卷: <dataPath>/<sensor>/<day>/<Dir>/*<Ext>,
+// 并自动注册同目录后缀为Late的迟到帧类型
+type SegmentType struct {
+	SType       byte   `json:"stype"` // protocols 数据段类型
+	Name        string `json:"name"`  // 查询参数type及文件名中的类型
+	Dir         string `json:"dir"`   // 日期目录下的子目录
+	Ext         string `json:"ext"`   // 数据卷后缀
+	Description string `json:"description"`
+	Late        bool   `json:"late"` // 迟到帧数据卷
+}
+
+// SegmentTypeArc 音频数据段
+var SegmentTypeArc = SegmentType{
+	SType:       protocols.STypeArc,
+	Name:        "Arc",
+	Dir:         "TypeArc",
+	Ext:         ".arc",
+	Description: "arc audio",
+}
+
+func init() {
+	if err := RegisterSegmentType(SegmentTypeArc); err != nil {
+		panic(err)
+	}
+}
+
+// LateType 同一数据段的迟到帧类型
+func (s SegmentType) LateType() SegmentType {
+	if s.Late {
+		return s
+	}
+	late := s
+	late.Name += lateSuffix
+	late.Dir += lateSuffix
+	late.Late = true
+	return late
+}
+
+// RegisterSegmentType 注册数据段类型及其迟到帧类型, 类型码、类型名及后缀不能重复
+func RegisterSegmentType(s SegmentType) error {
+	if s.Late {
+		return fmt.Errorf("segment type %s: late type is registered with its segment type", s.Name)
+	}
+	if s.Dir == "" {
+		s.Dir = "Type" + s.Name
+	}
+	if !segmentNamePattern.MatchString(s.Name) || !segmentNamePattern.MatchString(s.Dir) {
+		return fmt.Errorf("segment type %q: invalid name or dir %q", s.Name, s.Dir)
+	}
+	if !segmentExtPattern.MatchString(s.Ext) || s.Ext == WALFileType || s.Ext == IndexFileType {
+		return fmt.Errorf("segment type %s: invalid ext %q", s.Name, s.Ext)
+	}
+
+	segmentMu.Lock()
+	defer segmentMu.Unlock()
+	if old, ok := segmentBySType[s.SType]; ok {
+		return fmt.Errorf("segment type %s: stype %d registered by %s", s.Name, s.SType, old.Name)
+	}
+	late := s.LateType()
+	for _, t := range segmentByName {
+		switch {
+		case t.Name == s.Name || t.Name == late.Name:
+			return fmt.Errorf("segment type %s: name registered", t.Name)
+		case t.Dir == s.Dir || t.Dir == late.Dir:
+			return fmt.Errorf("segment type %s: dir %s registered by %s", s.Name, t.Dir, t.Name)
+		case !t.Late && t.Ext == s.Ext:
+			return fmt.Errorf("segment type %s: ext %s registered by %s", s.Name, s.Ext, t.Name)
+		}
+	}
+	segmentBySType[s.SType] = s
+	segmentByName[s.Name] = s
+	segmentByName[late.Name] = late
+	return nil
+}
+
+// SegmentTypeByName 按类型名查找, 包括迟到帧类型
+func SegmentTypeByName(name string) (SegmentType, bool) {
+	segmentMu.RLock()
+	defer segmentMu.RUnlock()
+	s, ok := segmentByName[name]
+	return s, ok
+}
+
+// SegmentTypeBySType 按 protocols 数据段类型查找
+func SegmentTypeBySType(stype byte) (SegmentType, bool) {
+	segmentMu.RLock()
+	defer segmentMu.RUnlock()
+	s, ok := segmentBySType[stype]
+	return s, ok
+}
+
+// SegmentTypes 已注册的类型, 包括迟到帧类型, 按类型名排序
+func SegmentTypes() []SegmentType {
+	segmentMu.RLock()
+	defer segmentMu.RUnlock()
+	types := make([]SegmentType, 0, len(segmentByName))
+	for _, s := range segmentByName {
+		types = append(types, s)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Name < types[j].Name })
+	return types
+}
@@ -0,0 +1,37 @@
+package arc_volume
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSegmentType(t *testing.T) {
+	Convey("SegmentType", t, func() {
+		arc, ok := SegmentTypeBySType(SegmentTypeArc.SType)
+		So(ok, ShouldBeTrue)
+		So(arc, ShouldResemble, SegmentTypeArc)
+		late, ok := SegmentTypeByName("ArcLate")
+		So(ok, ShouldBeTrue)
+		So(late.Dir, ShouldEqual, "TypeArcLate")
+		So(late.Ext, ShouldEqual, ".arc")
+		So(late.Late, ShouldBeTrue)
+		So(late.LateType(), ShouldResemble, late)
+
+		So(RegisterSegmentType(SegmentType{SType: 200, Name: "Test", Ext: ".tst"}), ShouldBeNil)
+		s, ok := SegmentTypeByName("TestLate")
+		So(ok, ShouldBeTrue)
+		So(s.Dir, ShouldEqual, "TypeTestLate")
+		So(s.SType, ShouldEqual, 200)
+
+		// stype, name, dir and ext must be unique
+		So(RegisterSegmentType(SegmentType{SType: 200, Name: "Other", Ext: ".oth"}), ShouldNotBeNil)
+		So(RegisterSegmentType(SegmentType{SType: 201, Name: "ArcLate", Ext: ".oth"}), ShouldNotBeNil)
+		So(RegisterSegmentType(SegmentType{SType: 201, Name: "Other", Dir: "TypeArc", Ext: ".oth"}), ShouldNotBeNil)
+		So(RegisterSegmentType(SegmentType{SType: 201, Name: "Other", Ext: ".arc"}), ShouldNotBeNil)
+		So(RegisterSegmentType(SegmentType{SType: 201, Name: "Other_1", Ext: ".oth"}), ShouldNotBeNil)
+		So(RegisterSegmentType(SegmentType{SType: 201, Name: "Other", Ext: IndexFileType}), ShouldNotBeNil)
+		_, ok = SegmentTypeBySType(201)
+		So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,214 @@
+package arc_volume
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/metric"
+	"github.com/kiga-hub/arc-storage/pkg/util"
+)
+
+// VolumeStream 在传感器队列中打开的数据卷, 之后的合并、压缩及删除不影响已打开的文件. 使用后需Close
+type VolumeStream struct {
+	volumes []streamVolume
+}
+
+// streamVolume 打开的数据卷及[t1,t2)在其中的范围
+type streamVolume struct {
+	path     string
+	f        *VolumeFile
+	start    int64
+	end      int64
+	coverEnd time.Time
+}
+
+// Len 数据卷数量
+func (s *VolumeStream) Len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.volumes)
+}
+
+// Close 关闭数据卷
+func (s *VolumeStream) Close() {
+	if s == nil {
+		return
+	}
+	for _, v := range s.volumes {
+		v.f.Close()
+	}
+	s.volumes = nil
+}
+
+// OpenStreamByQueue 走队列列出并打开[t1,t2)的数据卷, 数据在队列外由StreamData写出, 不占用传感器队列
+func (b *ArcVolumeCache) OpenStreamByQueue(sensorID, fileType string, t1, t2 time.Time) (*VolumeStream, error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Duration(b.config.Work.ArcVolumeQueueReadTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	t := createStreamTask(timeoutCtx, sensorID, b, sensorID, fileType, t1, t2)
+	b.queue.DoTask(t)
+	return t.result()
+}
+
+// openStream 打开[t1,t2)内有数据的数据卷
+func (b *ArcVolumeCache) openStream(sensorID, fileType string, t1, t2 time.Time) (*VolumeStream, error) {
+	filepathlist, err := b.ListVolumes(sensorID, fileType, t1, t2)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &VolumeStream{}
+	for _, v := range filepathlist {
+		start, end, fileEnd, err := volumeRange(v, t1, t2)
+		if err != nil {
+			b.logger.Debugw("volumeRange", "err", err, "filepath", v)
+			continue
+		}
+		if end <= start {
+			continue
+		}
+		f, err := OpenVolume(v)
+		if err != nil {
+			b.logger.Errorw("OpenVolume", "filepath", v, "err", err)
+			continue
+		}
+		s.volumes = append(s.volumes, streamVolume{path: v, f: f, start: start, end: end, coverEnd: fileEnd})
+	}
+	return s, nil
+}
+
+// StreamData 按顺序遍历数据卷, 将[t1,t2)的数据分块写入w并刷新, 返回写入大小及磁盘数据覆盖的结束时间.
+// 内存占用为一个分块大小, ctx取消时立即停止.
+func (b *ArcVolumeCache) StreamData(ctx context.Context, sensorID string, s *VolumeStream, w io.Writer) (int64, time.Time, error) {
+	chunkSize := b.config.Work.StreamChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 1 << 20
+	}
+	buf := make([]byte, chunkSize)
+
+	var total int64
+	var coverEnd time.Time
+	for _, v := range s.volumes {
+		if err := ctx.Err(); err != nil {
+			b.exportMetrics.SetFileReadValues(sensorID, metric.MonitorFailed)
+			return total, coverEnd, err
+		}
+
+		n, err := copyChunked(ctx, w, io.NewSectionReader(v.f, v.start, v.end-v.start), buf)
+		total += n
+		if v.coverEnd.After(coverEnd) {
+			coverEnd = v.coverEnd
+		}
+		if err != nil {
+			b.logger.Warnw("StreamData", "sensorid", sensorID, "filepath", v.path, "written", total, "err", err)
+			b.exportMetrics.SetFileReadValues(sensorID, metric.MonitorFailed)
+			return total, coverEnd, err
+		}
+	}
+
+	b.logger.Debugw("StreamData", "sensorid", sensorID, "files", len(s.volumes), "written", total)
+	b.exportMetrics.SetFileReadValues(sensorID, metric.MonitorSuccess)
+	return total, coverEnd, nil
+}
+
+// copyChunked 分块拷贝, 每块写入后刷新
+func copyChunked(ctx context.Context, w io.Writer, r io.Reader, buf []byte) (int64, error) {
+	var written int64
+	flusher, _ := w.(http.Flusher)
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		n, rerr := r.Read(buf)
+		if n > 0 {
+			m, werr := w.Write(buf[:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+}
+
+// ReadFrames 按时间顺序遍历[t1,t2)内的帧, 没有索引的数据卷整体作为一帧, 时间戳为文件创建时间.
+// 返回磁盘数据覆盖的结束时间.
+func (b *ArcVolumeCache) ReadFrames(ctx context.Context, sensorID, fileType string, t1, t2 time.Time, fn func(t time.Time, data []byte) error) (time.Time, error) {
+	var coverEnd time.Time
+	filepathlist, err := b.ListVolumes(sensorID, fileType, t1, t2)
+	if err != nil {
+		return coverEnd, err
+	}
+
+	for _, v := range filepathlist {
+		if err := ctx.Err(); err != nil {
+			return coverEnd, err
+		}
+		fileEnd, err := readVolumeFrames(v, t1, t2, fn)
+		if err != nil {
+			b.logger.Errorw("readVolumeFrames", "filepath", v, "err", err)
+			b.exportMetrics.SetFileReadValues(sensorID, metric.MonitorFailed)
+			return coverEnd, err
+		}
+		if fileEnd.After(coverEnd) {
+			coverEnd = fileEnd
+		}
+	}
+
+	b.exportMetrics.SetFileReadValues(sensorID, metric.MonitorSuccess)
+	return coverEnd, nil
+}
+
+// readVolumeFrames 遍历单个数据卷中[t1,t2)内的帧
+func readVolumeFrames(filename string, t1, t2 time.Time, fn func(t time.Time, data []byte) error) (time.Time, error) {
+	f, err := OpenVolume(filename)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	header, offset := readVolumeHeader(f)
+	size := f.Size() - offset
+
+	index, err := readFrameIndex(filename + IndexFileType)
+	if err != nil || len(index) == 0 {
+		var begin, end time.Time
+		if header != nil {
+			begin, end = header.CreateTime, header.SaveTime
+		} else if begin, end, _, err = util.GetTimeRangeFromFileName(filename); err != nil {
+			return time.Time{}, err
+		}
+		data := make([]byte, size)
+		if _, err := f.ReadAt(data, offset); err != nil && err != io.EOF {
+			return time.Time{}, err
+		}
+		return end, fn(begin, data)
+	}
+
+	i, j := seekFrames(index, t1, t2)
+	for k := i; k < j; k++ {
+		start, end := index[k].Offset, frameEnd(index, size, k)
+		if end <= start {
+			continue
+		}
+		data := make([]byte, end-start)
+		if _, err := f.ReadAt(data, start+offset); err != nil && err != io.EOF {
+			return time.Time{}, err
+		}
+		if err := fn(time.UnixMicro(index[k].Timestamp), data); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return frameCoverEnd(index, t2), nil
+}
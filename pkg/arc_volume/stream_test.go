@@ -0,0 +1,58 @@
+package arc_volume
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc/logging"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStream(t *testing.T) {
+	CaseStreamData(t)
+}
+
+func CaseStreamData(t *testing.T) {
+	Convey("StreamData", t, func() {
+		dataPath := t.TempDir()
+		b, err := NewArcVolumeCache(&logging.NoopLogger{}, &config.ArcConfig{
+			Work: &config.WorkConfig{DataPath: dataPath, ArcVolumeQueueLen: 4, ArcVolumeQueueNum: 1, ArcVolumeQueueReadTimeoutSeconds: 5},
+		}, "stream")
+		So(err, ShouldBeNil)
+		defer b.SafeClose()
+
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		dir := dataPath + "/A00000000001/20240102/" + SegmentTypeArc.Dir
+		var files []string
+		for _, v := range []*ArcVolume{
+			testVolume(dir, start, []byte{1, 2}, []byte{3}),
+			testVolume(dir, start.Add(time.Minute), []byte{4}),
+		} {
+			filename, _, err := b.writer.write(v, SegmentTypeArc.Ext, testHeader)
+			So(err, ShouldBeNil)
+			files = append(files, filename)
+		}
+
+		stream, err := b.OpenStreamByQueue("A00000000001", SegmentTypeArc.Name, start, start.Add(time.Hour))
+		So(err, ShouldBeNil)
+		defer stream.Close()
+		So(stream.Len(), ShouldEqual, 2)
+
+		// volumes removed after opening are still streamed
+		for _, f := range files {
+			So(os.Remove(f), ShouldBeNil)
+			So(os.Remove(f+IndexFileType), ShouldBeNil)
+		}
+
+		var w bytes.Buffer
+		n, end, err := b.StreamData(context.Background(), "A00000000001", stream, &w)
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+		So(w.Bytes(), ShouldResemble, []byte{1, 2, 3, 4})
+		So(end.Equal(start.Add(time.Minute)), ShouldBeTrue)
+	})
+}
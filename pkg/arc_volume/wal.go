@@ -0,0 +1,412 @@
+package arc_volume
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// WALFileType -
+	WALFileType = ".wal"
+	// walHeaderSize 记录头: 长度(4) crc32(4)
+	walHeaderSize = 8
+	// walMaxRecordSize 单条记录上限, 超过视为损坏
+	walMaxRecordSize = 1 << 30
+)
+
+var (
+	// ErrWALClosed -
+	ErrWALClosed = errors.New("wal: closed")
+	// errWALCorrupt 记录损坏, 回放时忽略该段后续内容
+	errWALCorrupt = errors.New("wal: corrupt record")
+)
+
+// WALRecord 预写日志记录, 对应一帧arc数据
+type WALRecord struct {
+	Key       uint64 // DataCache key
+	SensorID  string
+	Type      string
+	Timestamp time.Time
+	Data      []byte
+}
+
+// encodeWALRecord len(4) crc(4) | key(8) timestamp(8) len(1) sensorid len(1) type data
+func encodeWALRecord(r *WALRecord) []byte {
+	size := 8 + 8 + 1 + len(r.SensorID) + 1 + len(r.Type) + len(r.Data)
+	b := make([]byte, walHeaderSize+size)
+	p := b[walHeaderSize:]
+	binary.BigEndian.PutUint64(p[0:8], r.Key)
+	binary.BigEndian.PutUint64(p[8:16], uint64(r.Timestamp.UnixMicro()))
+	off := 16
+	p[off] = byte(len(r.SensorID))
+	off += 1 + copy(p[off+1:], r.SensorID)
+	p[off] = byte(len(r.Type))
+	off += 1 + copy(p[off+1:], r.Type)
+	copy(p[off:], r.Data)
+
+	binary.BigEndian.PutUint32(b[0:4], uint32(size))
+	binary.BigEndian.PutUint32(b[4:8], crc32.ChecksumIEEE(p))
+	return b
+}
+
+// decodeWALRecord 解析记录内容(不含记录头)
+func decodeWALRecord(p []byte) (*WALRecord, error) {
+	if len(p) < 18 {
+		return nil, errWALCorrupt
+	}
+	r := &WALRecord{
+		Key:       binary.BigEndian.Uint64(p[0:8]),
+		Timestamp: time.UnixMicro(int64(binary.BigEndian.Uint64(p[8:16]))),
+	}
+	off := 16
+	n := int(p[off])
+	if off+1+n+1 > len(p) {
+		return nil, errWALCorrupt
+	}
+	r.SensorID = string(p[off+1 : off+1+n])
+	off += 1 + n
+	n = int(p[off])
+	if off+1+n > len(p) {
+		return nil, errWALCorrupt
+	}
+	r.Type = string(p[off+1 : off+1+n])
+	off += 1 + n
+	r.Data = p[off:]
+	return r, nil
+}
+
+// walSegmentName arc_<seq>_<worker>.wal, 按文件名排序即为写入顺序
+func walSegmentName(seq uint64, worker int) string {
+	return fmt.Sprintf("arc_%020d_%d%s", seq, worker, WALFileType)
+}
+
+// walSegmentSeq 从段文件名中解析序号
+func walSegmentSeq(name string) (uint64, bool) {
+	parts := strings.Split(strings.TrimSuffix(filepath.Base(name), WALFileType), "_")
+	if len(parts) != 3 || parts[0] != "arc" {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	return seq, err == nil
+}
+
+// ListWALSegments 目录中的全部段文件, 按写入顺序排序
+func ListWALSegments(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var segments []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, ok := walSegmentSeq(e.Name()); ok && strings.HasSuffix(e.Name(), WALFileType) {
+			segments = append(segments, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(segments)
+	return segments, nil
+}
+
+// ReplayWAL 按顺序回放段文件中的记录. 进程崩溃时末尾可能写了半条记录, 遇到不完整或损坏的记录时跳过该段剩余内容
+func ReplayWAL(segments []string, fn func(r *WALRecord) error) error {
+	for _, segment := range segments {
+		if err := replayWALSegment(segment, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replayWALSegment(segment string, fn func(r *WALRecord) error) error {
+	f, err := os.Open(segment)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return nil
+		}
+		size := binary.BigEndian.Uint32(header[0:4])
+		if size > walMaxRecordSize {
+			return nil
+		}
+		p := make([]byte, size)
+		if _, err := io.ReadFull(reader, p); err != nil {
+			return nil
+		}
+		if crc32.ChecksumIEEE(p) != binary.BigEndian.Uint32(header[4:8]) {
+			return nil
+		}
+		r, err := decodeWALRecord(p)
+		if err != nil {
+			return nil
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+}
+
+// RemoveWALSegments 删除回放完成的段文件
+func RemoveWALSegments(segments []string) error {
+	for _, segment := range segments {
+		if err := os.Remove(segment); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// walMark 传感器未落盘数据所在的段
+type walMark struct {
+	first uint64
+	last  uint64
+}
+
+// WAL 工作协程的预写日志, 数据追加到当前段文件, 段文件写满后滚动.
+// 数据卷落盘后调用Checkpoint, 不再包含未落盘数据的段被删除, 没有未落盘数据时当前段被截断.
+type WAL struct {
+	mu           sync.Mutex
+	dir          string
+	worker       int
+	segmentSize  int64
+	syncInterval time.Duration
+	file         *os.File
+	seq          uint64   // 当前段序号
+	size         int64    // 当前段大小
+	segments     []uint64 // 未删除的段, 含当前段
+	pending      map[uint64]walMark
+	dirty        bool
+	done         chan struct{}
+}
+
+// OpenWAL 创建工作协程的预写日志. 段序号从目录中已有段文件的最大序号之后开始, 不会覆盖待回放的段
+func OpenWAL(dir string, worker int, segmentSize int64, syncInterval time.Duration) (*WAL, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("MKdirAll: %v", err)
+	}
+	segments, err := ListWALSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seq uint64
+	for _, segment := range segments {
+		if s, ok := walSegmentSeq(segment); ok && s > seq {
+			seq = s
+		}
+	}
+
+	w := &WAL{
+		dir:          dir,
+		worker:       worker,
+		segmentSize:  segmentSize,
+		syncInterval: syncInterval,
+		pending:      map[uint64]walMark{},
+		done:         make(chan struct{}),
+	}
+	if err := w.openSegment(seq + 1); err != nil {
+		return nil, err
+	}
+	if syncInterval > 0 {
+		go w.syncLoop()
+	}
+	return w, nil
+}
+
+// openSegment 创建新的段文件
+func (w *WAL) openSegment(seq uint64) error {
+	f, err := os.OpenFile(filepath.Join(w.dir, walSegmentName(seq, w.worker)), os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("OpenFile: %v", err)
+	}
+	w.file = f
+	w.seq = seq
+	w.size = 0
+	w.segments = append(w.segments, seq)
+	return nil
+}
+
+// Append 追加一条记录
+func (w *WAL) Append(r *WALRecord) error {
+	b := encodeWALRecord(r)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return ErrWALClosed
+	}
+	if w.segmentSize > 0 && w.size > 0 && w.size+int64(len(b)) > w.segmentSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := w.file.Write(b)
+	w.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("wal write error: %v", err)
+	}
+
+	m, ok := w.pending[r.Key]
+	if !ok {
+		m.first = w.seq
+	}
+	m.last = w.seq
+	w.pending[r.Key] = m
+
+	if w.syncInterval <= 0 {
+		return w.file.Sync()
+	}
+	w.dirty = true
+	return nil
+}
+
+// rotate 当前段刷盘后切换到新段
+func (w *WAL) rotate() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.dirty = false
+	return w.openSegment(w.seq + 1)
+}
+
+// Checkpoint 传感器的数据卷已落盘. keepLast为true时最后一条记录仍在内存数据卷中, 保留其所在的段
+func (w *WAL) Checkpoint(key uint64, keepLast bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return ErrWALClosed
+	}
+
+	if m, ok := w.pending[key]; ok && keepLast {
+		w.pending[key] = walMark{first: m.last, last: m.last}
+	} else {
+		delete(w.pending, key)
+	}
+	return w.removeSegments()
+}
+
+// CheckpointAt 传感器的数据卷已落盘, 未落盘的数据都在seq及之后的段中
+func (w *WAL) CheckpointAt(key, seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return ErrWALClosed
+	}
+
+	if m, ok := w.pending[key]; ok && seq > m.first {
+		m.first = seq
+		if m.last < seq {
+			m.last = seq
+		}
+		w.pending[key] = m
+	}
+	return w.removeSegments()
+}
+
+// Seq 当前段序号, 即最后一条记录所在的段
+func (w *WAL) Seq() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.seq
+}
+
+// removeSegments 删除不包含未落盘数据的段
+func (w *WAL) removeSegments() error {
+	// 没有未落盘数据, 截断当前段
+	if len(w.pending) == 0 && w.size > 0 {
+		if err := w.file.Truncate(0); err != nil {
+			return fmt.Errorf("wal truncate error: %v", err)
+		}
+		w.size = 0
+	}
+
+	min := w.seq
+	for _, m := range w.pending {
+		if m.first < min {
+			min = m.first
+		}
+	}
+
+	segments := w.segments[:0]
+	for _, seq := range w.segments {
+		if seq < min {
+			if err := os.Remove(filepath.Join(w.dir, walSegmentName(seq, w.worker))); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		segments = append(segments, seq)
+	}
+	w.segments = segments
+	return nil
+}
+
+// Sync 刷盘
+func (w *WAL) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil || !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+func (w *WAL) syncLoop() {
+	ticker := time.NewTicker(w.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			_ = w.Sync()
+		}
+	}
+}
+
+// Close 刷盘并关闭, 未落盘数据所在的段保留到下次启动时回放
+func (w *WAL) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	close(w.done)
+	err := w.file.Sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	w.file = nil
+	return err
+}
@@ -0,0 +1,129 @@
+package arc_volume
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWAL(t *testing.T) {
+	CaseWALRecordCodec(t)
+	CaseWALReplay(t)
+	CaseWALCheckpoint(t)
+	CaseWALCheckpointAt(t)
+}
+
+func CaseWALRecordCodec(t *testing.T) {
+	Convey("WALRecordCodec", t, func() {
+		r := &WALRecord{
+			Key:       0xA00000000001,
+			SensorID:  "A00000000001",
+			Type:      "Arc",
+			Timestamp: time.UnixMicro(1700000000123456),
+			Data:      []byte{1, 2, 3},
+		}
+		b := encodeWALRecord(r)
+		got, err := decodeWALRecord(b[walHeaderSize:])
+		So(err, ShouldBeNil)
+		So(got.Key, ShouldEqual, r.Key)
+		So(got.SensorID, ShouldEqual, r.SensorID)
+		So(got.Type, ShouldEqual, r.Type)
+		So(got.Timestamp.Equal(r.Timestamp), ShouldBeTrue)
+		So(got.Data, ShouldResemble, r.Data)
+
+		_, err = decodeWALRecord([]byte{1, 2})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func CaseWALReplay(t *testing.T) {
+	Convey("WALReplay", t, func() {
+		dir := t.TempDir()
+		w, err := OpenWAL(dir, 0, 0, 0)
+		So(err, ShouldBeNil)
+		for i := 0; i < 3; i++ {
+			So(w.Append(&WALRecord{Key: 1, SensorID: "A1", Type: "Arc", Timestamp: time.UnixMicro(int64(i)), Data: []byte{byte(i)}}), ShouldBeNil)
+		}
+		So(w.Close(), ShouldBeNil)
+
+		segments, err := ListWALSegments(dir)
+		So(err, ShouldBeNil)
+		So(len(segments), ShouldEqual, 1)
+
+		// half written record at the tail is ignored
+		f, err := os.OpenFile(segments[0], os.O_WRONLY|os.O_APPEND, 0644)
+		So(err, ShouldBeNil)
+		_, err = f.Write([]byte{0, 0, 0, 100, 1})
+		So(err, ShouldBeNil)
+		f.Close()
+
+		var data []byte
+		So(ReplayWAL(segments, func(r *WALRecord) error {
+			data = append(data, r.Data...)
+			return nil
+		}), ShouldBeNil)
+		So(data, ShouldResemble, []byte{0, 1, 2})
+
+		// new segments never reuse a sequence waiting for replay
+		w, err = OpenWAL(dir, 0, 0, 0)
+		So(err, ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+		segments, _ = ListWALSegments(dir)
+		So(len(segments), ShouldEqual, 2)
+	})
+}
+
+func CaseWALCheckpoint(t *testing.T) {
+	Convey("WALCheckpoint", t, func() {
+		dir := t.TempDir()
+		// one record per segment
+		w, err := OpenWAL(dir, 0, 1, 0)
+		So(err, ShouldBeNil)
+		defer w.Close()
+
+		So(w.Append(&WALRecord{Key: 1, Data: []byte{1}}), ShouldBeNil)
+		So(w.Append(&WALRecord{Key: 2, Data: []byte{2}}), ShouldBeNil)
+		So(w.Append(&WALRecord{Key: 1, Data: []byte{3}}), ShouldBeNil)
+		segments, _ := ListWALSegments(dir)
+		So(len(segments), ShouldEqual, 3)
+
+		// key 2 still pending in the second segment
+		So(w.Checkpoint(1, false), ShouldBeNil)
+		segments, _ = ListWALSegments(dir)
+		So(len(segments), ShouldEqual, 2)
+
+		So(w.Checkpoint(2, false), ShouldBeNil)
+		segments, _ = ListWALSegments(dir)
+		So(len(segments), ShouldEqual, 1)
+		fi, err := os.Stat(segments[0])
+		So(err, ShouldBeNil)
+		So(fi.Size(), ShouldEqual, 0)
+	})
+}
+
+func CaseWALCheckpointAt(t *testing.T) {
+	Convey("WALCheckpointAt", t, func() {
+		dir := t.TempDir()
+		w, err := OpenWAL(dir, 0, 1, 0)
+		So(err, ShouldBeNil)
+		defer w.Close()
+
+		seqs := make([]uint64, 3)
+		for i := range seqs {
+			So(w.Append(&WALRecord{Key: 1, Data: []byte{byte(i)}}), ShouldBeNil)
+			seqs[i] = w.Seq()
+		}
+
+		// frames of the second and third segment are still buffered
+		So(w.CheckpointAt(1, seqs[1]), ShouldBeNil)
+		segments, _ := ListWALSegments(dir)
+		So(len(segments), ShouldEqual, 2)
+
+		// an older seq never brings segments back
+		So(w.CheckpointAt(1, seqs[0]), ShouldBeNil)
+		segments, _ = ListWALSegments(dir)
+		So(len(segments), ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,274 @@
+package arc_volume
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc-storage/pkg/util"
+)
+
+// 数据卷写入方式
+const (
+	// WriteModeRename 写入临时文件, 刷盘后重命名为数据卷. 每次落盘生成一个数据卷
+	WriteModeRename = "rename"
+	// WriteModeAppend 追加到同一切换周期内已写入的数据卷, 文件名及文件头更新为新的保存时间
+	WriteModeAppend = "append"
+)
+
+// tmpFileExt 写入中的临时文件后缀, 数据卷列表不包含临时文件
+const tmpFileExt = ".tmp"
+
+// openVolume 追加模式下当前切换周期的数据卷
+type openVolume struct {
+	path       string
+	createTime time.Time
+	saveTime   time.Time
+	boundary   time.Time // 切换周期的结束边界
+	size       int64     // 数据区大小, 不含文件头
+	frames     int
+	headerSize int64
+}
+
+// volumeWriter 数据卷写入, 同一传感器的写入任务由同一个队列串行执行
+type volumeWriter struct {
+	mode       string
+	durability string
+	work       *config.WorkConfig
+	mu         sync.Mutex
+	open       map[string]*openVolume // key: 传感器ID/数据类型
+}
+
+// newVolumeWriter -
+func newVolumeWriter(work *config.WorkConfig) (*volumeWriter, error) {
+	mode := work.WriteMode
+	if mode == "" {
+		mode = WriteModeRename
+	}
+	if mode != WriteModeRename && mode != WriteModeAppend {
+		return nil, fmt.Errorf("unknown write mode %q, available: %s, %s", mode, WriteModeRename, WriteModeAppend)
+	}
+	durability := work.Durability
+	if durability == "" {
+		durability = DurabilityVolume
+	}
+	if err := checkDurability(durability); err != nil {
+		return nil, err
+	}
+	if durability == DurabilityGroup && mode != WriteModeAppend {
+		// 重命名前必须fsync, 没有可以合并的fsync
+		return nil, fmt.Errorf("durability %s requires write mode %s", DurabilityGroup, WriteModeAppend)
+	}
+	return &volumeWriter{
+		mode:       mode,
+		durability: durability,
+		work:       work,
+		open:       map[string]*openVolume{},
+	}, nil
+}
+
+// volumePath <dir>/<sensorid>_<type>_<start>_<end><ext>
+func volumePath(dir, sensorID, fileType string, createTime, saveTime time.Time, ext string) string {
+	return dir + "/" + sensorID + "_" + fileType + "_" + util.TimeStringReplace(createTime) + "_" + util.TimeStringReplace(saveTime) + ext
+}
+
+// write 写入数据卷及帧索引, 返回数据卷路径. header生成数据卷的文件头.
+// 组提交时返回写入的文件, 由调用方在批次fsync后关闭
+func (w *volumeWriter) write(cc *ArcVolume, ext string, header func(*ArcVolume) []byte) (string, []*os.File, error) {
+	s := &fileSyncer{durability: w.durability}
+	filename, err := w.writeVolume(s, cc, ext, header)
+	if err != nil {
+		s.close()
+		return "", nil, err
+	}
+	return filename, s.pending, nil
+}
+
+// writeVolume -
+func (w *volumeWriter) writeVolume(s *fileSyncer, cc *ArcVolume, ext string, header func(*ArcVolume) []byte) (string, error) {
+	if w.mode == WriteModeRename {
+		return w.writeRename(s, cc, ext, header)
+	}
+
+	key := cc.SensorID + "/" + cc.Type
+	w.mu.Lock()
+	ov := w.open[key]
+	w.mu.Unlock()
+
+	if ov != nil && w.appendable(ov, cc) {
+		filename, err := w.writeAppend(s, ov, cc, ext, header)
+		if err == nil {
+			return filename, nil
+		}
+		// the volume is left as it was, the retry starts a new one
+		w.mu.Lock()
+		delete(w.open, key)
+		w.mu.Unlock()
+		return "", err
+	}
+
+	h := header(cc)
+	filename := volumePath(cc.Dir, cc.SensorID, cc.Type, cc.CreateTime, cc.SaveTime, ext)
+	if err := s.create(filename, h, cc.Buffer.Bytes()); err != nil {
+		return "", err
+	}
+	if err := s.create(filename+IndexFileType, encodeFrameIndex(cc.Index)); err != nil {
+		return "", err
+	}
+	if err := s.dir(filename); err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	w.open[key] = &openVolume{
+		path:       filename,
+		createTime: cc.CreateTime,
+		saveTime:   cc.SaveTime,
+		boundary:   w.boundary(cc.CreateTime),
+		size:       int64(cc.Buffer.Len()),
+		frames:     len(cc.Index),
+		headerSize: int64(len(h)),
+	}
+	w.mu.Unlock()
+	return filename, nil
+}
+
+// forget 数据卷或所在目录被合并、删除后, 不再向其追加
+func (w *volumeWriter) forget(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for key, ov := range w.open {
+		if ov.path == name || strings.HasPrefix(ov.path, name+"/") {
+			delete(w.open, key)
+		}
+	}
+}
+
+// boundary 切换周期的结束边界, 未配置saveDuration时不按时间切换
+func (w *volumeWriter) boundary(t time.Time) time.Time {
+	if w.work.SaveDuration == "" {
+		return time.Time{}
+	}
+	return util.NextBoundary(t, w.work.SaveDuration, w.work.SaveNum)
+}
+
+// appendable 数据属于同一切换周期, 时间不早于已写入的帧, 且追加后不超过数据卷上限
+func (w *volumeWriter) appendable(ov *openVolume, cc *ArcVolume) bool {
+	if !w.boundary(cc.CreateTime).Equal(ov.boundary) {
+		return false
+	}
+	if len(cc.Index) > 0 && time.UnixMicro(cc.Index[0].Timestamp).Before(ov.saveTime) {
+		return false
+	}
+	if w.work.MaxVolumeSize > 0 && ov.size+int64(cc.Buffer.Len()) > w.work.MaxVolumeSize {
+		return false
+	}
+	if w.work.MaxVolumeFrames > 0 && ov.frames+len(cc.Index) > w.work.MaxVolumeFrames {
+		return false
+	}
+	return true
+}
+
+// writeAppend 追加数据, 更新文件头的保存时间, 合并帧索引后按新的保存时间重命名
+func (w *volumeWriter) writeAppend(s *fileSyncer, ov *openVolume, cc *ArcVolume, ext string, header func(*ArcVolume) []byte) (string, error) {
+	f, err := os.OpenFile(ov.path, os.O_RDWR, 0644)
+	if err != nil {
+		return "", fmt.Errorf("OpenFile: %v", err)
+	}
+	handed := false
+	defer func() {
+		if !handed {
+			f.Close()
+		}
+	}()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	// modified outside of the writer
+	if fi.Size() != ov.headerSize+ov.size {
+		return "", fmt.Errorf("volume %s size %d, expect %d", ov.path, fi.Size(), ov.headerSize+ov.size)
+	}
+
+	index, err := readFrameIndex(ov.path + IndexFileType)
+	if err != nil {
+		return "", err
+	}
+
+	saveTime := cc.SaveTime
+	if saveTime.Before(ov.saveTime) {
+		saveTime = ov.saveTime
+	}
+	h := header(&ArcVolume{SensorID: cc.SensorID, Type: cc.Type, CreateTime: ov.createTime, SaveTime: saveTime})
+	if int64(len(h)) != ov.headerSize {
+		return "", fmt.Errorf("volume %s header size changed %d != %d", ov.path, len(h), ov.headerSize)
+	}
+
+	filename := volumePath(path.Dir(ov.path), cc.SensorID, cc.Type, ov.createTime, saveTime, ext)
+	// restore the volume, the data is retried by the caller
+	rollback := func() {
+		f.Truncate(fi.Size())
+		f.WriteAt(header(&ArcVolume{SensorID: cc.SensorID, Type: cc.Type, CreateTime: ov.createTime, SaveTime: ov.saveTime}), 0)
+		if filename != ov.path {
+			os.Remove(filename + IndexFileType)
+		}
+	}
+
+	if _, err := f.WriteAt(cc.Buffer.Bytes(), fi.Size()); err != nil {
+		rollback()
+		return "", fmt.Errorf("file write error: %v", err)
+	}
+	if _, err := f.WriteAt(h, 0); err != nil {
+		rollback()
+		return "", fmt.Errorf("file write error: %v", err)
+	}
+
+	for _, e := range cc.Index {
+		index = append(index, FrameIndex{Timestamp: e.Timestamp, Offset: e.Offset + ov.size})
+	}
+	if err := s.create(filename+IndexFileType, encodeFrameIndex(index)); err != nil {
+		rollback()
+		return "", err
+	}
+	if filename != ov.path {
+		if err := os.Rename(ov.path, filename); err != nil {
+			rollback()
+			return "", fmt.Errorf("rename volume: %v", err)
+		}
+		// the volume is complete, the index of the old name is no longer used
+		os.Remove(ov.path + IndexFileType)
+	}
+	handed = true
+	if err := s.done(f); err != nil {
+		return "", err
+	}
+	if err := s.dir(filename); err != nil {
+		return "", err
+	}
+
+	ov.path = filename
+	ov.saveTime = saveTime
+	ov.size += int64(cc.Buffer.Len())
+	ov.frames += len(cc.Index)
+	return filename, nil
+}
+
+// writeRename 数据卷及帧索引先写入临时文件并刷盘, 再重命名
+func (w *volumeWriter) writeRename(s *fileSyncer, cc *ArcVolume, ext string, header func(*ArcVolume) []byte) (string, error) {
+	filename := volumePath(cc.Dir, cc.SensorID, cc.Type, cc.CreateTime, cc.SaveTime, ext)
+	if err := s.createAtomic(filename, header(cc), cc.Buffer.Bytes()); err != nil {
+		return "", err
+	}
+	if err := s.createAtomic(filename+IndexFileType, encodeFrameIndex(cc.Index)); err != nil {
+		return "", err
+	}
+	if err := s.dir(filename); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
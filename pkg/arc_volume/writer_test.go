@@ -0,0 +1,104 @@
+package arc_volume
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVolumeWriter(t *testing.T) {
+	CaseWriteRename(t)
+	CaseWriteAppend(t)
+}
+
+// testVolume 每帧间隔1s的数据卷
+func testVolume(dir string, start time.Time, frames ...[]byte) *ArcVolume {
+	cc := &ArcVolume{SensorID: "A00000000001", Type: "Arc", Dir: dir, CreateTime: start, Buffer: &bytes.Buffer{}}
+	for i, f := range frames {
+		cc.Append(start.Add(time.Duration(i)*time.Second), f)
+		cc.SaveTime = start.Add(time.Duration(i) * time.Second)
+	}
+	return cc
+}
+
+func testHeader(cc *ArcVolume) []byte {
+	return encodeVolumeHeader(&VolumeHeader{SensorID: cc.SensorID, Type: cc.Type, CreateTime: cc.CreateTime, SaveTime: cc.SaveTime})
+}
+
+func CaseWriteRename(t *testing.T) {
+	Convey("WriteRename", t, func() {
+		dir := t.TempDir()
+		w, err := newVolumeWriter(&config.WorkConfig{WriteMode: WriteModeRename, SaveDuration: "hour", SaveNum: 1})
+		So(err, ShouldBeNil)
+
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		name1, _, err := w.write(testVolume(dir, start, []byte{1, 2}), ".arc", testHeader)
+		So(err, ShouldBeNil)
+		name2, _, err := w.write(testVolume(dir, start.Add(time.Minute), []byte{3}), ".arc", testHeader)
+		So(err, ShouldBeNil)
+		So(name2, ShouldNotEqual, name1)
+
+		h, err := ReadVolumeHeader(name1)
+		So(err, ShouldBeNil)
+		So(h.SaveTime.Equal(start), ShouldBeTrue)
+		tmp, _ := filepath.Glob(dir + "/*" + tmpFileExt)
+		So(tmp, ShouldBeEmpty)
+		idx, _ := filepath.Glob(dir + "/*" + IndexFileType)
+		So(len(idx), ShouldEqual, 2)
+	})
+
+	Convey("WriteMode", t, func() {
+		_, err := newVolumeWriter(&config.WorkConfig{WriteMode: "mmap"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func CaseWriteAppend(t *testing.T) {
+	Convey("WriteAppend", t, func() {
+		dir := t.TempDir()
+		w, err := newVolumeWriter(&config.WorkConfig{WriteMode: WriteModeAppend, SaveDuration: "hour", SaveNum: 1})
+		So(err, ShouldBeNil)
+
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		name1, _, err := w.write(testVolume(dir, start, []byte{1, 2}, []byte{3}), ".arc", testHeader)
+		So(err, ShouldBeNil)
+
+		// same window, renamed to the new save time
+		second := testVolume(dir, start.Add(time.Minute), []byte{4, 5})
+		name2, _, err := w.write(second, ".arc", testHeader)
+		So(err, ShouldBeNil)
+		So(name2, ShouldEqual, volumePath(dir, "A00000000001", "Arc", start, second.SaveTime, ".arc"))
+		_, err = os.Stat(name1)
+		So(os.IsNotExist(err), ShouldBeTrue)
+		_, err = os.Stat(name1 + IndexFileType)
+		So(os.IsNotExist(err), ShouldBeTrue)
+
+		h, err := ReadVolumeHeader(name2)
+		So(err, ShouldBeNil)
+		So(h.CreateTime.Equal(start), ShouldBeTrue)
+		So(h.SaveTime.Equal(second.SaveTime), ShouldBeTrue)
+
+		data, err := os.ReadFile(name2)
+		So(err, ShouldBeNil)
+		So(data[len(testHeader(second)):], ShouldResemble, []byte{1, 2, 3, 4, 5})
+		index, err := readFrameIndex(name2 + IndexFileType)
+		So(err, ShouldBeNil)
+		So(index, ShouldResemble, []FrameIndex{
+			{Timestamp: start.UnixMicro(), Offset: 0},
+			{Timestamp: start.Add(time.Second).UnixMicro(), Offset: 2},
+			{Timestamp: start.Add(time.Minute).UnixMicro(), Offset: 3},
+		})
+
+		// next window starts a new volume
+		name3, _, err := w.write(testVolume(dir, start.Add(time.Hour), []byte{6}), ".arc", testHeader)
+		So(err, ShouldBeNil)
+		So(name3, ShouldNotEqual, name2)
+		_, err = os.Stat(name2)
+		So(err, ShouldBeNil)
+	})
+}
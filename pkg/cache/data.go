@@ -8,7 +8,6 @@ import (
 	"github.com/kiga-hub/arc-storage/pkg/config"
 	"github.com/kiga-hub/arc/cache"
 	"github.com/kiga-hub/arc/logging"
-	"github.com/spf13/cast"
 )
 
 // DataCacheRepo DataCacheRepo
@@ -50,11 +49,11 @@ func (d *DataCacheRepo) Input(data *cache.DataPoint) {
 	d.Container.Input(data)
 }
 
-// Search -
-func (d *DataCacheRepo) Search(logger logging.ILogger, id uint64, from, to time.Time, frameOffset int) ([]byte, int, error) {
+// Search - 按传感器采样参数切分查询到的数据
+func (d *DataCacheRepo) Search(logger logging.ILogger, id uint64, from, to time.Time, frameOffset int, profile config.SensorProfile) ([]byte, int, error) {
 
-	sampleRate := float64(8000) 
-	channel := 1
+	sampleRate := float64(profile.SampleRate)
+	blockAlign := int64(profile.BlockAlign())
 	// 两帧时间差,单位ms
 	gap := (float64(frameOffset) / sampleRate) * 1e6
 	// previousTimestamp开始时间多取1帧时间, previousTimestamp < t1 < 缓存中的开始时间戳
@@ -83,12 +82,11 @@ func (d *DataCacheRepo) Search(logger logging.ILogger, id uint64, from, to time.
 
 	data := dataPoint[0].(*cache.DataPoint)
 
-	// 切分查询到的数据
-	sub := int64(math.Abs(float64(from.Sub(previousTimestamp).Seconds()) * sampleRate * 2 * float64(channel)))
-	sub = sub - sub%2
+	// 切分查询到的数据, 按采样点对齐
+	sub := int64(math.Abs(from.Sub(previousTimestamp).Seconds()*sampleRate)) * blockAlign
 
 	// 获取数据预期大小
-	expectSize := cast.ToInt64(to.Sub(from).Seconds()) * 2 * int64(channel)
+	expectSize := profile.BytesOfDuration(to.Sub(from))
 
 	if len(data.Data) < 1 {
 		stat := d.Container.GetStat()
@@ -112,7 +110,7 @@ func (d *DataCacheRepo) Search(logger logging.ILogger, id uint64, from, to time.
 				"to", to.UTC(),
 			)
 		}
-		return nil, 0, fmt.Errorf("not found data %s", err.Error())
+		return nil, 0, fmt.Errorf("not found data")
 	}
 
 	var wavData []byte
@@ -41,6 +41,13 @@ func (c *ArcStorageComponent) Name() string {
 func (c *ArcStorageComponent) PreInit(ctx context.Context) error {
 	// load config
 	config.SetDefaultWorkConfig()
+	config.SetDefaultSensorConfig()
+	config.SetDefaultWALConfig()
+	config.SetDefaultDeadLetterConfig()
+	config.SetDefaultDecoderConfig()
+	config.SetDefaultCompressConfig()
+	config.SetDefaultCompactConfig()
+	config.SetDefaultRetentionConfig()
 	return nil
 }
 
@@ -111,12 +118,13 @@ func (c *ArcStorageComponent) Start(ctx context.Context) error {
 
 // Stop the component
 func (c *ArcStorageComponent) Stop(ctx context.Context) error {
+	// stop, Start may have already returned on a signal
+	close(c.stopChan)
+	// wait for the drain, frames may still be forwarded to kafka
+	c.handler.Close()
 
 	if c.kafka != nil {
 		c.kafka.Stop()
 	}
-
-	// stop
-	c.stopChan <- struct{}{}
 	return nil
 }
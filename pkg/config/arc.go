@@ -10,14 +10,22 @@ import (
 type ArcConfig struct {
 	Basic *basic.BasicConfig
 
-	Work  *WorkConfig          `toml:"-"`
-	Log   *logging.LogConfig   `toml:"-"`
-	Trace *tracing.TraceConfig `toml:"-"`
-	Cache *CacheConfig         `toml:"-"`
-	Kafka *KafkaConfig         `toml:"-"`
-	Taos  *TaosConfig          `toml:"-"`
-	Grpc  *GRPCConfig          `toml:"-"`
-	Pprof *PprofConfig         `toml:"-"`
+	Work       *WorkConfig          `toml:"-"`
+	Log        *logging.LogConfig   `toml:"-"`
+	Trace      *tracing.TraceConfig `toml:"-"`
+	Cache      *CacheConfig         `toml:"-"`
+	Kafka      *KafkaConfig         `toml:"-"`
+	Taos       *TaosConfig          `toml:"-"`
+	Grpc       *GRPCConfig          `toml:"-"`
+	Pprof      *PprofConfig         `toml:"-"`
+	Sensor     *SensorConfig        `toml:"-"`
+	WAL        *WALConfig           `toml:"-"`
+	DeadLetter *DeadLetterConfig    `toml:"-"`
+	Segment    *SegmentConfig       `toml:"-"`
+	Decoder    *DecoderConfig       `toml:"-"`
+	Compress   *CompressConfig      `toml:"-"`
+	Compact    *CompactConfig       `toml:"-"`
+	Retention  *RetentionConfig     `toml:"-"`
 }
 
 // SetDefaultArcConfig -
@@ -29,6 +37,13 @@ func SetDefaultArcConfig() {
 	SetDefaultTaosConfig()
 	SetDefaultGRPCConfig()
 	SetDefaultPprofConfig()
+	SetDefaultSensorConfig()
+	SetDefaultWALConfig()
+	SetDefaultDeadLetterConfig()
+	SetDefaultDecoderConfig()
+	SetDefaultCompressConfig()
+	SetDefaultCompactConfig()
+	SetDefaultRetentionConfig()
 }
 
 // GetConfig Get默认配置参数
@@ -36,12 +51,21 @@ func GetConfig() *ArcConfig {
 	return &ArcConfig{
 		Basic: basic.GetBasicConfig(),
 
-		Work:  GetWorkConfig(),
-		Cache: GetCacheConfig(),
-		Kafka: GetKafkaConfig(),
-		Taos:  GetTaosConfig(),
-		Grpc:  GetGRPCConfig(),
-		Pprof: GetPprofConfig(),
+		Work:   GetWorkConfig(),
+		Cache:  GetCacheConfig(),
+		Kafka:  GetKafkaConfig(),
+		Taos:   GetTaosConfig(),
+		Grpc:   GetGRPCConfig(),
+		Pprof:  GetPprofConfig(),
+		Sensor: GetSensorConfig(),
+		WAL:    GetWALConfig(),
+
+		DeadLetter: GetDeadLetterConfig(),
+		Segment:    GetSegmentConfig(),
+		Decoder:    GetDecoderConfig(),
+		Compress:   GetCompressConfig(),
+		Compact:    GetCompactConfig(),
+		Retention:  GetRetentionConfig(),
 
 		Log:   logging.GetLogConfig(),
 		Trace: tracing.GetTraceConfig(),
@@ -0,0 +1,53 @@
+package config
+
+import "github.com/spf13/viper"
+
+const (
+	configCompactEnable         = "compact.enable"
+	configCompactSpan           = "compact.span"
+	configCompactAge            = "compact.age"
+	configCompactInterval       = "compact.interval"
+	configCompactBytesPerSecond = "compact.bytesPerSecond"
+	configCompactDryRun         = "compact.dryRun"
+)
+
+var defaultCompactConfig = CompactConfig{
+	Enable:         false,
+	Span:           3600,
+	Age:            3600,
+	Interval:       3600,
+	BytesPerSecond: 8 << 20,
+	DryRun:         false,
+}
+
+// CompactConfig 合并天目录内的小数据卷
+type CompactConfig struct {
+	Enable         bool  `toml:"enable"`
+	Span           int   `toml:"span"`           // 合并后数据卷覆盖的时间跨度，按UTC对齐，单位:s
+	Age            int   `toml:"age"`            // 保存时间早于该时长的数据卷参与合并, 需大于数据卷切换周期，单位:s
+	Interval       int   `toml:"interval"`       // 扫描间隔，单位:s
+	BytesPerSecond int64 `toml:"bytesPerSecond"` // 合并写入限速, 0不限速，单位:byte
+	DryRun         bool  `toml:"dryRun"`         // 只输出合并计划, 不修改文件
+}
+
+// SetDefaultCompactConfig -
+func SetDefaultCompactConfig() {
+	viper.SetDefault(configCompactEnable, defaultCompactConfig.Enable)
+	viper.SetDefault(configCompactSpan, defaultCompactConfig.Span)
+	viper.SetDefault(configCompactAge, defaultCompactConfig.Age)
+	viper.SetDefault(configCompactInterval, defaultCompactConfig.Interval)
+	viper.SetDefault(configCompactBytesPerSecond, defaultCompactConfig.BytesPerSecond)
+	viper.SetDefault(configCompactDryRun, defaultCompactConfig.DryRun)
+}
+
+// GetCompactConfig -
+func GetCompactConfig() *CompactConfig {
+	return &CompactConfig{
+		Enable:         viper.GetBool(configCompactEnable),
+		Span:           viper.GetInt(configCompactSpan),
+		Age:            viper.GetInt(configCompactAge),
+		Interval:       viper.GetInt(configCompactInterval),
+		BytesPerSecond: viper.GetInt64(configCompactBytesPerSecond),
+		DryRun:         viper.GetBool(configCompactDryRun),
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import "github.com/spf13/viper"
+
+const (
+	configCompressEnable    = "compress.enable"
+	configCompressCodec     = "compress.codec"
+	configCompressAge       = "compress.age"
+	configCompressInterval  = "compress.interval"
+	configCompressBlockSize = "compress.blockSize"
+)
+
+var defaultCompressConfig = CompressConfig{
+	Enable:    false,
+	Codec:     "gzip",
+	Age:       86400,
+	Interval:  600,
+	BlockSize: 1 << 20,
+}
+
+// CompressConfig 已关闭数据卷的后台压缩
+type CompressConfig struct {
+	Enable    bool   `toml:"enable"`
+	Codec     string `toml:"codec"`     // 压缩算法, gzip, zstd
+	Age       int    `toml:"age"`       // 保存时间早于该时长的数据卷被压缩，单位:s
+	Interval  int    `toml:"interval"`  // 扫描间隔，单位:s
+	BlockSize int    `toml:"blockSize"` // 压缩块大小，单位:byte, 读取时按块解压
+}
+
+// SetDefaultCompressConfig -
+func SetDefaultCompressConfig() {
+	viper.SetDefault(configCompressEnable, defaultCompressConfig.Enable)
+	viper.SetDefault(configCompressCodec, defaultCompressConfig.Codec)
+	viper.SetDefault(configCompressAge, defaultCompressConfig.Age)
+	viper.SetDefault(configCompressInterval, defaultCompressConfig.Interval)
+	viper.SetDefault(configCompressBlockSize, defaultCompressConfig.BlockSize)
+}
+
+// GetCompressConfig -
+func GetCompressConfig() *CompressConfig {
+	return &CompressConfig{
+		Enable:    viper.GetBool(configCompressEnable),
+		Codec:     viper.GetString(configCompressCodec),
+		Age:       viper.GetInt(configCompressAge),
+		Interval:  viper.GetInt(configCompressInterval),
+		BlockSize: viper.GetInt(configCompressBlockSize),
+	}
+}
@@ -0,0 +1,33 @@
+package config
+
+import "github.com/spf13/viper"
+
+const (
+	configDeadLetterEnable = "deadletter.enable"
+	configDeadLetterDir    = "deadletter.dir"
+)
+
+var defaultDeadLetterConfig = DeadLetterConfig{
+	Enable: true,
+	Dir:    "/deadletter",
+}
+
+// DeadLetterConfig 解码失败数据的保存目录
+type DeadLetterConfig struct {
+	Enable bool   `toml:"enable"`
+	Dir    string `toml:"dir"` // 不要放在dataPath下
+}
+
+// SetDefaultDeadLetterConfig -
+func SetDefaultDeadLetterConfig() {
+	viper.SetDefault(configDeadLetterEnable, defaultDeadLetterConfig.Enable)
+	viper.SetDefault(configDeadLetterDir, defaultDeadLetterConfig.Dir)
+}
+
+// GetDeadLetterConfig -
+func GetDeadLetterConfig() *DeadLetterConfig {
+	return &DeadLetterConfig{
+		Enable: viper.GetBool(configDeadLetterEnable),
+		Dir:    viper.GetString(configDeadLetterDir),
+	}
+}
@@ -0,0 +1,38 @@
+package config
+
+import "github.com/spf13/viper"
+
+const (
+	configDecoderGRPC  = "decoder.grpc"
+	configDecoderKafka = "decoder.kafka"
+	configDecoderHTTP  = "decoder.http"
+)
+
+var defaultDecoderConfig = DecoderConfig{
+	GRPC:  "arc",
+	Kafka: "arc",
+	HTTP:  "arc",
+}
+
+// DecoderConfig 各接入来源的帧解码器: arc, protobuf
+type DecoderConfig struct {
+	GRPC  string `toml:"grpc"`
+	Kafka string `toml:"kafka"`
+	HTTP  string `toml:"http"` // 批量上传
+}
+
+// SetDefaultDecoderConfig -
+func SetDefaultDecoderConfig() {
+	viper.SetDefault(configDecoderGRPC, defaultDecoderConfig.GRPC)
+	viper.SetDefault(configDecoderKafka, defaultDecoderConfig.Kafka)
+	viper.SetDefault(configDecoderHTTP, defaultDecoderConfig.HTTP)
+}
+
+// GetDecoderConfig -
+func GetDecoderConfig() *DecoderConfig {
+	return &DecoderConfig{
+		GRPC:  viper.GetString(configDecoderGRPC),
+		Kafka: viper.GetString(configDecoderKafka),
+		HTTP:  viper.GetString(configDecoderHTTP),
+	}
+}
@@ -5,29 +5,70 @@ import "github.com/spf13/viper"
 const (
 	configGRPCEnable = "grpc.enable"
 	configGRPCServer = "grpc.server"
+	configGRPCRetry  = "grpc.retryAfterMs"
+
+	configGRPCTLS            = "grpc.tls"
+	configGRPCCertFile       = "grpc.certFile"
+	configGRPCKeyFile        = "grpc.keyFile"
+	configGRPCCAFile         = "grpc.caFile"
+	configGRPCClientAuth     = "grpc.clientAuth"
+	configGRPCServerName     = "grpc.serverName"
+	configGRPCReloadInterval = "grpc.reloadInterval"
 )
 
 var defaultGRPCConfig = GRPCConfig{
-	Enable: false,
-	Server: ":8080",
+	Enable:       false,
+	Server:       ":8080",
+	RetryAfterMs: 100,
+
+	TLS:            false,
+	ClientAuth:     false,
+	ReloadInterval: 10,
 }
 
 // GRPCConfig -
 type GRPCConfig struct {
-	Enable bool   `toml:"enable"`
-	Server string `toml:"server"`
+	Enable       bool   `toml:"enable"`
+	Server       string `toml:"server"`
+	RetryAfterMs int    `toml:"retryAfterMs"` // 解码队列已满时建议客户端的重发间隔，单位:ms
+
+	TLS            bool   `toml:"tls"`
+	CertFile       string `toml:"certFile"` // 服务端证书, 客户端(arc_grpc.NewOptions)作为mTLS的客户端证书
+	KeyFile        string `toml:"keyFile"`
+	CAFile         string `toml:"caFile"`         // 服务端校验客户端证书, 客户端校验服务端证书
+	ClientAuth     bool   `toml:"clientAuth"`     // mTLS, 要求并校验客户端证书
+	ServerName     string `toml:"serverName"`     // 客户端校验服务端证书使用的名称
+	ReloadInterval int    `toml:"reloadInterval"` // 证书文件变化检查间隔，单位:s
 }
 
 // SetDefaultGRPCConfig -
 func SetDefaultGRPCConfig() {
 	viper.SetDefault(configGRPCEnable, defaultGRPCConfig.Enable)
 	viper.SetDefault(configGRPCServer, defaultGRPCConfig.Server)
+	viper.SetDefault(configGRPCRetry, defaultGRPCConfig.RetryAfterMs)
+
+	viper.SetDefault(configGRPCTLS, defaultGRPCConfig.TLS)
+	viper.SetDefault(configGRPCCertFile, defaultGRPCConfig.CertFile)
+	viper.SetDefault(configGRPCKeyFile, defaultGRPCConfig.KeyFile)
+	viper.SetDefault(configGRPCCAFile, defaultGRPCConfig.CAFile)
+	viper.SetDefault(configGRPCClientAuth, defaultGRPCConfig.ClientAuth)
+	viper.SetDefault(configGRPCServerName, defaultGRPCConfig.ServerName)
+	viper.SetDefault(configGRPCReloadInterval, defaultGRPCConfig.ReloadInterval)
 }
 
 // GetGRPCConfig -
 func GetGRPCConfig() *GRPCConfig {
 	return &GRPCConfig{
-		Enable: viper.GetBool(configGRPCEnable),
-		Server: viper.GetString(configGRPCServer),
+		Enable:       viper.GetBool(configGRPCEnable),
+		Server:       viper.GetString(configGRPCServer),
+		RetryAfterMs: viper.GetInt(configGRPCRetry),
+
+		TLS:            viper.GetBool(configGRPCTLS),
+		CertFile:       viper.GetString(configGRPCCertFile),
+		KeyFile:        viper.GetString(configGRPCKeyFile),
+		CAFile:         viper.GetString(configGRPCCAFile),
+		ClientAuth:     viper.GetBool(configGRPCClientAuth),
+		ServerName:     viper.GetString(configGRPCServerName),
+		ReloadInterval: viper.GetInt(configGRPCReloadInterval),
 	}
 }
@@ -9,6 +9,10 @@ const (
 	configKafkaMessageMaxBytes = "kafka.messageMaxBytes"
 	configKafkaTopic           = "kafka.topic"
 	configKafkaInterval        = "kafka.interval"
+	configKafkaConsume         = "kafka.consume"
+	configKafkaConsumeTopic    = "kafka.consumeTopic"
+	configKafkaConsumeGroupID  = "kafka.consumeGroupID"
+	configKafkaCommitInterval  = "kafka.commitInterval"
 )
 
 var defaultKafkaConfig = KafkaConfig{
@@ -18,6 +22,10 @@ var defaultKafkaConfig = KafkaConfig{
 	MessageMaxBytes: 67108864,
 	Topic:           "arc",
 	Interval:        2,
+	Consume:         false,
+	ConsumeTopic:    "arc.frame",
+	ConsumeGroupID:  "arc-storage",
+	CommitInterval:  1000,
 }
 
 // KafkaConfig -
@@ -28,6 +36,10 @@ type KafkaConfig struct {
 	MessageMaxBytes int    `toml:"messageMaxBytes"`
 	Topic           string `toml:"topic"`
 	Interval        int    `toml:"interval"`
+	Consume         bool   `toml:"consume"`        // 从ConsumeTopic读取原始协议帧, 需要开启wal
+	ConsumeTopic    string `toml:"consumeTopic"`   // 原始帧topic, 消息key为传感器ID
+	ConsumeGroupID  string `toml:"consumeGroupID"` // 消费组
+	CommitInterval  int    `toml:"commitInterval"` // 偏移提交间隔，单位:ms
 }
 
 // SetDefaultKafkaConfig -
@@ -38,6 +50,10 @@ func SetDefaultKafkaConfig() {
 	viper.SetDefault(configKafkaMessageMaxBytes, defaultKafkaConfig.MessageMaxBytes)
 	viper.SetDefault(configKafkaTopic, defaultKafkaConfig.Topic)
 	viper.SetDefault(configKafkaInterval, defaultKafkaConfig.Interval)
+	viper.SetDefault(configKafkaConsume, defaultKafkaConfig.Consume)
+	viper.SetDefault(configKafkaConsumeTopic, defaultKafkaConfig.ConsumeTopic)
+	viper.SetDefault(configKafkaConsumeGroupID, defaultKafkaConfig.ConsumeGroupID)
+	viper.SetDefault(configKafkaCommitInterval, defaultKafkaConfig.CommitInterval)
 }
 
 // GetKafkaConfig -
@@ -49,5 +65,9 @@ func GetKafkaConfig() *KafkaConfig {
 		MessageMaxBytes: viper.GetInt(configKafkaMessageMaxBytes),
 		Topic:           viper.GetString(configKafkaTopic),
 		Interval:        viper.GetInt(configKafkaInterval),
+		Consume:         viper.GetBool(configKafkaConsume),
+		ConsumeTopic:    viper.GetString(configKafkaConsumeTopic),
+		ConsumeGroupID:  viper.GetString(configKafkaConsumeGroupID),
+		CommitInterval:  viper.GetInt(configKafkaCommitInterval),
 	}
 }
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	configRetentionEnable         = "retention.enable"
+	configRetentionInterval       = "retention.interval"
+	configRetentionMaxAge         = "retention.maxAge"
+	configRetentionMaxSensorBytes = "retention.maxSensorBytes"
+	configRetentionMaxTotalBytes  = "retention.maxTotalBytes"
+	configRetentionAuditLog       = "retention.auditLog"
+	configRetentionRules          = "retention.rules"
+)
+
+var defaultRetentionConfig = RetentionConfig{
+	Enable:         false,
+	Interval:       3600,
+	MaxAge:         0,
+	MaxSensorBytes: 0,
+	MaxTotalBytes:  0,
+	AuditLog:       "/retention/audit.log",
+}
+
+// RetentionRule 匹配传感器ID的保留规则, 未配置的项使用全局配置
+type RetentionRule struct {
+	Name     string `toml:"-"`        // 配置项名称
+	Pattern  string `toml:"pattern"`  // 传感器ID通配符, 如 A0000000*
+	MaxAge   int    `toml:"maxAge"`   // 保存时间早于该时长的数据被删除, 0使用全局配置，单位:s
+	MaxBytes int64  `toml:"maxBytes"` // 每个传感器的磁盘占用上限, 0使用全局配置，单位:byte
+}
+
+// RetentionConfig 数据保留策略, 超出上限时按时间从早到晚删除天目录或数据卷
+type RetentionConfig struct {
+	Enable         bool            `toml:"enable"`
+	Interval       int             `toml:"interval"`       // 检查间隔，单位:s
+	MaxAge         int             `toml:"maxAge"`         // 保存时间早于该时长的数据被删除, 0不限制，单位:s
+	MaxSensorBytes int64           `toml:"maxSensorBytes"` // 每个传感器的磁盘占用上限, 0不限制，单位:byte
+	MaxTotalBytes  int64           `toml:"maxTotalBytes"`  // dataPath的磁盘占用上限, 0不限制，单位:byte
+	AuditLog       string          `toml:"auditLog"`       // 删除记录, 每行一条JSON, 不要放在dataPath下
+	Rules          []RetentionRule `toml:"-"`              // [retention.rules.<name>], 按名称排序, 第一个匹配的规则生效
+}
+
+// SetDefaultRetentionConfig -
+func SetDefaultRetentionConfig() {
+	viper.SetDefault(configRetentionEnable, defaultRetentionConfig.Enable)
+	viper.SetDefault(configRetentionInterval, defaultRetentionConfig.Interval)
+	viper.SetDefault(configRetentionMaxAge, defaultRetentionConfig.MaxAge)
+	viper.SetDefault(configRetentionMaxSensorBytes, defaultRetentionConfig.MaxSensorBytes)
+	viper.SetDefault(configRetentionMaxTotalBytes, defaultRetentionConfig.MaxTotalBytes)
+	viper.SetDefault(configRetentionAuditLog, defaultRetentionConfig.AuditLog)
+}
+
+// GetRetentionConfig -
+func GetRetentionConfig() *RetentionConfig {
+	c := &RetentionConfig{
+		Enable:         viper.GetBool(configRetentionEnable),
+		Interval:       viper.GetInt(configRetentionInterval),
+		MaxAge:         viper.GetInt(configRetentionMaxAge),
+		MaxSensorBytes: viper.GetInt64(configRetentionMaxSensorBytes),
+		MaxTotalBytes:  viper.GetInt64(configRetentionMaxTotalBytes),
+		AuditLog:       viper.GetString(configRetentionAuditLog),
+	}
+
+	settings := viper.GetStringMap(configRetentionRules)
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		r := RetentionRule{Name: name}
+		// viper key 不区分大小写
+		for key, value := range cast.ToStringMap(settings[name]) {
+			switch strings.ToLower(key) {
+			case "pattern":
+				r.Pattern = cast.ToString(value)
+			case "maxage":
+				r.MaxAge = cast.ToInt(value)
+			case "maxbytes":
+				r.MaxBytes = cast.ToInt64(value)
+			}
+		}
+		if r.Pattern == "" {
+			continue
+		}
+		c.Rules = append(c.Rules, r)
+	}
+	return c
+}
+
+// Rule 传感器生效的保留规则, 没有匹配的规则时Name为空
+func (c *RetentionConfig) Rule(sensorID string) RetentionRule {
+	r := RetentionRule{MaxAge: c.MaxAge, MaxBytes: c.MaxSensorBytes}
+	for _, rule := range c.Rules {
+		if ok, _ := path.Match(strings.ToUpper(rule.Pattern), strings.ToUpper(sensorID)); !ok {
+			continue
+		}
+		r.Name, r.Pattern = rule.Name, rule.Pattern
+		if rule.MaxAge > 0 {
+			r.MaxAge = rule.MaxAge
+		}
+		if rule.MaxBytes > 0 {
+			r.MaxBytes = rule.MaxBytes
+		}
+		break
+	}
+	return r
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	configSegmentTypes = "segment.types"
+)
+
+// SegmentTypeConfig 额外的DataGroup数据段类型, Arc类型已内置
+type SegmentTypeConfig struct {
+	SType       int    `toml:"sType"`       // protocols 数据段类型
+	Name        string `toml:"name"`        // 查询参数type, 缺省使用配置项名称
+	Dir         string `toml:"dir"`         // 日期目录下的子目录, 缺省为Type<name>
+	Ext         string `toml:"ext"`         // 数据卷后缀, 如 .vib
+	Description string `toml:"description"` // -
+}
+
+// SegmentConfig 数据段类型配置, [segment.types.<name>]
+type SegmentConfig struct {
+	Types []SegmentTypeConfig `toml:"-"`
+}
+
+// GetSegmentConfig -
+func GetSegmentConfig() *SegmentConfig {
+	c := &SegmentConfig{}
+	settings := viper.GetStringMap(configSegmentTypes)
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t := SegmentTypeConfig{Name: name}
+		// viper key 不区分大小写
+		for key, value := range cast.ToStringMap(settings[name]) {
+			switch strings.ToLower(key) {
+			case "stype":
+				t.SType = cast.ToInt(value)
+			case "name":
+				t.Name = cast.ToString(value)
+			case "dir":
+				t.Dir = cast.ToString(value)
+			case "ext":
+				t.Ext = cast.ToString(value)
+			case "description":
+				t.Description = cast.ToString(value)
+			}
+		}
+		c.Types = append(c.Types, t)
+	}
+	return c
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	configSensorSampleRate  = "sensor.sampleRate"
+	configSensorChannel     = "sensor.channel"
+	configSensorSampleWidth = "sensor.sampleWidth"
+	configSensorProfileFile = "sensor.profileFile"
+	configSensorProfiles    = "sensor.profiles"
+)
+
+var defaultSensorConfig = SensorConfig{
+	SensorProfile: SensorProfile{
+		SampleRate:  8000,
+		Channel:     1,
+		SampleWidth: 2,
+	},
+	ProfileFile: "",
+}
+
+// SensorProfile 传感器采样参数
+type SensorProfile struct {
+	SampleRate  int `toml:"sampleRate"`  // 采样率，单位:Hz
+	Channel     int `toml:"channel"`     // 通道数
+	SampleWidth int `toml:"sampleWidth"` // 采样位宽，单位:byte
+}
+
+// BlockAlign 每个采样点所有通道的字节数
+func (p SensorProfile) BlockAlign() int {
+	return p.Channel * p.SampleWidth
+}
+
+// BytesPerSecond 每秒数据字节数
+func (p SensorProfile) BytesPerSecond() int {
+	return p.SampleRate * p.BlockAlign()
+}
+
+// BytesOfDuration 时长对应的字节数, 按采样点对齐
+func (p SensorProfile) BytesOfDuration(d time.Duration) int64 {
+	samples := int64(d.Seconds() * float64(p.SampleRate))
+	return samples * int64(p.BlockAlign())
+}
+
+// DurationOfBytes 字节数对应的时长
+func (p SensorProfile) DurationOfBytes(size int64) time.Duration {
+	return time.Duration(float64(size) / float64(p.BytesPerSecond()) * float64(time.Second))
+}
+
+// valid -
+func (p SensorProfile) valid() bool {
+	return p.SampleRate > 0 && p.Channel > 0 && p.SampleWidth > 0
+}
+
+// SensorConfig 传感器配置, 未配置的传感器使用默认采样参数
+type SensorConfig struct {
+	SensorProfile
+	ProfileFile string                   `toml:"profileFile"` // 传感器注册文件, [<sensorid>] sampleRate/channel/sampleWidth
+	Profiles    map[string]SensorProfile `toml:"profiles"`    // 按传感器ID配置
+}
+
+// SetDefaultSensorConfig -
+func SetDefaultSensorConfig() {
+	viper.SetDefault(configSensorSampleRate, defaultSensorConfig.SampleRate)
+	viper.SetDefault(configSensorChannel, defaultSensorConfig.Channel)
+	viper.SetDefault(configSensorSampleWidth, defaultSensorConfig.SampleWidth)
+	viper.SetDefault(configSensorProfileFile, defaultSensorConfig.ProfileFile)
+}
+
+// GetSensorConfig -
+func GetSensorConfig() *SensorConfig {
+	c := &SensorConfig{
+		SensorProfile: SensorProfile{
+			SampleRate:  viper.GetInt(configSensorSampleRate),
+			Channel:     viper.GetInt(configSensorChannel),
+			SampleWidth: viper.GetInt(configSensorSampleWidth),
+		},
+		ProfileFile: viper.GetString(configSensorProfileFile),
+		Profiles:    map[string]SensorProfile{},
+	}
+	c.setProfiles(viper.GetStringMap(configSensorProfiles))
+	return c
+}
+
+// LoadProfileFile 校验默认采样参数, 加载传感器注册文件, 文件中的配置覆盖配置文件中的profiles
+func (c *SensorConfig) LoadProfileFile() error {
+	if !c.SensorProfile.valid() {
+		return fmt.Errorf("invalid default sensor profile, sampleRate:%d channel:%d sampleWidth:%d", c.SampleRate, c.Channel, c.SampleWidth)
+	}
+	if c.ProfileFile == "" {
+		return nil
+	}
+	v := viper.New()
+	v.SetConfigFile(c.ProfileFile)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("read sensor profile file %s: %v", c.ProfileFile, err)
+	}
+	c.setProfiles(v.AllSettings())
+	return nil
+}
+
+// GetProfile 获取传感器采样参数
+func (c *SensorConfig) GetProfile(sensorID string) SensorProfile {
+	if p, ok := c.Profiles[strings.ToUpper(sensorID)]; ok {
+		return p
+	}
+	return c.SensorProfile
+}
+
+// setProfiles 解析 map[sensorid]{sampleRate,channel,sampleWidth}, 缺省项使用默认值
+func (c *SensorConfig) setProfiles(settings map[string]interface{}) {
+	for id, v := range settings {
+		m := cast.ToStringMap(v)
+		p := c.SensorProfile
+		// viper key 不区分大小写
+		for key, value := range m {
+			switch strings.ToLower(key) {
+			case "samplerate":
+				p.SampleRate = cast.ToInt(value)
+			case "channel":
+				p.Channel = cast.ToInt(value)
+			case "samplewidth":
+				p.SampleWidth = cast.ToInt(value)
+			}
+		}
+		if !p.valid() {
+			continue
+		}
+		c.Profiles[strings.ToUpper(id)] = p
+	}
+}
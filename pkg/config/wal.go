@@ -0,0 +1,43 @@
+package config
+
+import "github.com/spf13/viper"
+
+const (
+	configWALEnable       = "wal.enable"
+	configWALDir          = "wal.dir"
+	configWALSegmentSize  = "wal.segmentSize"
+	configWALSyncInterval = "wal.syncInterval"
+)
+
+var defaultWALConfig = WALConfig{
+	Enable:       false,
+	Dir:          "/wal",
+	SegmentSize:  64 << 20,
+	SyncInterval: 1000,
+}
+
+// WALConfig 预写日志配置, 每个工作协程一个日志. 默认关闭, 开启前确认dir所在磁盘的容量
+type WALConfig struct {
+	Enable       bool   `toml:"enable"`
+	Dir          string `toml:"dir"`          // 日志目录, 不要放在dataPath下
+	SegmentSize  int64  `toml:"segmentSize"`  // 段文件大小，单位:byte
+	SyncInterval int    `toml:"syncInterval"` // 刷盘间隔，单位:ms, 0表示每次追加都刷盘
+}
+
+// SetDefaultWALConfig -
+func SetDefaultWALConfig() {
+	viper.SetDefault(configWALEnable, defaultWALConfig.Enable)
+	viper.SetDefault(configWALDir, defaultWALConfig.Dir)
+	viper.SetDefault(configWALSegmentSize, defaultWALConfig.SegmentSize)
+	viper.SetDefault(configWALSyncInterval, defaultWALConfig.SyncInterval)
+}
+
+// GetWALConfig -
+func GetWALConfig() *WALConfig {
+	return &WALConfig{
+		Enable:       viper.GetBool(configWALEnable),
+		Dir:          viper.GetString(configWALDir),
+		SegmentSize:  viper.GetInt64(configWALSegmentSize),
+		SyncInterval: viper.GetInt(configWALSyncInterval),
+	}
+}
@@ -18,6 +18,16 @@ const (
 	configAutomaticallySaveFile       = "arc.allowAutomaticallySaveFile"
 	configFrameOffset                 = "arc.frameOffset"
 	configTimeOut                     = "arc.timeout"
+	configStreamChunkSize             = "arc.streamChunkSize"
+	configMaxVolumeSize               = "arc.maxVolumeSize"
+	configMaxVolumeFrames             = "arc.maxVolumeFrames"
+	configReorderWindow               = "arc.reorderWindow"
+	configDedupHorizon                = "arc.dedupHorizon"
+	configShutdownTimeout             = "arc.shutdownTimeout"
+	configWriteMode                   = "arc.writeMode"
+	configDurability                  = "arc.durability"
+	configDurabilityGroupBytes        = "arc.durabilityGroupBytes"
+	configDurabilityGroupInterval     = "arc.durabilityGroupInterval"
 )
 
 var defaultWorkConfig = WorkConfig{
@@ -34,6 +44,16 @@ var defaultWorkConfig = WorkConfig{
 	AllowAutomaticallySaveFile:       true,
 	FrameOffset:                      5,
 	TimeOut:                          300,
+	StreamChunkSize:                  1 << 20,
+	MaxVolumeSize:                    256 << 20,
+	MaxVolumeFrames:                  0,
+	ReorderWindow:                    1000,
+	DedupHorizon:                     60,
+	ShutdownTimeout:                  60,
+	WriteMode:                        "rename",
+	Durability:                       "volume",
+	DurabilityGroupBytes:             8 << 20,
+	DurabilityGroupInterval:          100,
 }
 
 // WorkConfig 配置
@@ -51,6 +71,16 @@ type WorkConfig struct {
 	AllowAutomaticallySaveFile       bool   `toml:"allowAutomaticallySaveFile"` // 允许每分钟自动保存到文件
 	FrameOffset                      int    `toml:"frameOffset"`                // 从缓存查询数据, 多查询的帧数
 	TimeOut                          int    `toml:"timeOut"`                    // 超时落盘，单位:s
+	StreamChunkSize                  int    `toml:"streamChunkSize"`            // 流式下载分块大小，单位:byte
+	MaxVolumeSize                    int64  `toml:"maxVolumeSize"`              // 数据卷大小上限，单位:byte, 0不限制
+	MaxVolumeFrames                  int    `toml:"maxVolumeFrames"`            // 数据卷帧数上限, 0不限制
+	ReorderWindow                    int    `toml:"reorderWindow"`              // 乱序窗口，单位:ms, 早于窗口的帧写入迟到帧数据卷
+	DedupHorizon                     int    `toml:"dedupHorizon"`               // 重复帧检测范围，单位:s, 0不检测
+	ShutdownTimeout                  int    `toml:"shutdownTimeout"`            // 停机排空期限，单位:s, 超过期限未落盘的数据保留在预写日志中
+	WriteMode                        string `toml:"writeMode"`                  // 数据卷写入方式, rename: 临时文件刷盘后重命名, append: 追加到同一切换周期的数据卷
+	Durability                       string `toml:"durability"`                 // 持久化级别, none: 不fsync, volume: 每个数据卷fsync, group: 队列内合并fsync(仅writeMode为append), dir: fsync数据卷及所在目录
+	DurabilityGroupBytes             int64  `toml:"durabilityGroupBytes"`       // group级别批次数据达到该大小时fsync，单位:byte
+	DurabilityGroupInterval          int    `toml:"durabilityGroupInterval"`    // group级别批次最长等待时间，单位:ms
 }
 
 // SetDefaultWorkConfig -
@@ -70,6 +100,16 @@ func SetDefaultWorkConfig() {
 	viper.SetDefault(configAutomaticallySaveFile, defaultWorkConfig.AllowAutomaticallySaveFile)
 	viper.SetDefault(configFrameOffset, defaultWorkConfig.FrameOffset)
 	viper.SetDefault(configTimeOut, defaultWorkConfig.TimeOut)
+	viper.SetDefault(configStreamChunkSize, defaultWorkConfig.StreamChunkSize)
+	viper.SetDefault(configMaxVolumeSize, defaultWorkConfig.MaxVolumeSize)
+	viper.SetDefault(configMaxVolumeFrames, defaultWorkConfig.MaxVolumeFrames)
+	viper.SetDefault(configReorderWindow, defaultWorkConfig.ReorderWindow)
+	viper.SetDefault(configDedupHorizon, defaultWorkConfig.DedupHorizon)
+	viper.SetDefault(configShutdownTimeout, defaultWorkConfig.ShutdownTimeout)
+	viper.SetDefault(configWriteMode, defaultWorkConfig.WriteMode)
+	viper.SetDefault(configDurability, defaultWorkConfig.Durability)
+	viper.SetDefault(configDurabilityGroupBytes, defaultWorkConfig.DurabilityGroupBytes)
+	viper.SetDefault(configDurabilityGroupInterval, defaultWorkConfig.DurabilityGroupInterval)
 }
 
 // GetWorkConfig Get默认配置参数
@@ -88,5 +128,15 @@ func GetWorkConfig() *WorkConfig {
 		AllowAutomaticallySaveFile:       viper.GetBool(configAutomaticallySaveFile),
 		FrameOffset:                      viper.GetInt(configFrameOffset),
 		TimeOut:                          viper.GetInt(configTimeOut),
+		StreamChunkSize:                  viper.GetInt(configStreamChunkSize),
+		MaxVolumeSize:                    viper.GetInt64(configMaxVolumeSize),
+		MaxVolumeFrames:                  viper.GetInt(configMaxVolumeFrames),
+		ReorderWindow:                    viper.GetInt(configReorderWindow),
+		DedupHorizon:                     viper.GetInt(configDedupHorizon),
+		ShutdownTimeout:                  viper.GetInt(configShutdownTimeout),
+		WriteMode:                        viper.GetString(configWriteMode),
+		Durability:                       viper.GetString(configDurability),
+		DurabilityGroupBytes:             viper.GetInt64(configDurabilityGroupBytes),
+		DurabilityGroupInterval:          viper.GetInt(configDurabilityGroupInterval),
 	}
 }
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/kiga-hub/arc-storage/pkg/deadletter"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+	"github.com/kiga-hub/arc/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// getDeadLetters list bytes rejected by decode
+func (arc *ArcStorage) getDeadLetters(c echo.Context) error {
+	if arc.deadLetters == nil {
+		return c.JSON(http.StatusNotFound, utils.ResponseV2{
+			Code: http.StatusNotFound,
+			Msg:  "dead letter disabled"},
+		)
+	}
+
+	entries, err := arc.deadLetters.List()
+	if err != nil {
+		arc.logger.Errorw("deadLetters.List", "err", err)
+		return c.JSON(http.StatusInternalServerError, utils.ResponseV2{
+			Code: http.StatusInternalServerError,
+			Msg:  err.Error()},
+		)
+	}
+
+	return c.JSON(http.StatusOK, utils.ResponseV2{
+		Code: Success,
+		Msg:  "OK",
+		Data: entries},
+	)
+}
+
+// getDeadLetter download the raw bytes of a dead letter
+func (arc *ArcStorage) getDeadLetter(c echo.Context) error {
+	e, data, resp := arc.loadDeadLetter(c.Param("id"))
+	if resp != nil {
+		return c.JSON(resp.Code, resp)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", e.ID+".bin"))
+	c.Response().Header().Set("X-Reject-Reason", e.Reason)
+	return c.Blob(http.StatusOK, echo.MIMEOctetStream, data)
+}
+
+// reinjectDeadLetter push the raw bytes back into the decode pipeline and remove the dead letter.
+// bytes still invalid are rejected again as a new dead letter.
+func (arc *ArcStorage) reinjectDeadLetter(c echo.Context) error {
+	e, data, resp := arc.loadDeadLetter(c.Param("id"))
+	if resp != nil {
+		return c.JSON(resp.Code, resp)
+	}
+
+	dec, err := decoder.Get(e.Decoder)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  err.Error()},
+		)
+	}
+
+	if !arc.acquire() {
+		return c.JSON(http.StatusServiceUnavailable, utils.ResponseV2{
+			Code: http.StatusServiceUnavailable,
+			Msg:  http.StatusText(http.StatusServiceUnavailable)},
+		)
+	}
+	defer arc.inflight.Done()
+
+	var key uint64
+	if id, err := SensorIDToUInt64(e.SensorID); err == nil {
+		key = id
+	}
+
+	select {
+	case arc.decodeJobChans[key&uint64(arc.config.Work.WorkCount-1)] <- decodeJob{data: data, source: e.Source, decoder: dec}:
+	default:
+		return c.JSON(http.StatusServiceUnavailable, utils.ResponseV2{
+			Code: http.StatusServiceUnavailable,
+			Msg:  http.StatusText(http.StatusServiceUnavailable)},
+		)
+	}
+
+	if err := arc.deadLetters.Remove(e.ID); err != nil {
+		arc.logger.Errorw("deadLetters.Remove", "id", e.ID, "err", err)
+	}
+	arc.logger.Infow("reinjectDeadLetter", "id", e.ID, "sensorid", e.SensorID, "size", len(data))
+
+	return c.JSON(http.StatusOK, utils.ResponseV2{
+		Code: Success,
+		Msg:  "OK",
+		Data: e},
+	)
+}
+
+// loadDeadLetter -
+func (arc *ArcStorage) loadDeadLetter(id string) (*deadletter.Entry, []byte, *utils.ResponseV2) {
+	if arc.deadLetters == nil {
+		return nil, nil, &utils.ResponseV2{
+			Code: http.StatusNotFound,
+			Msg:  "dead letter disabled",
+		}
+	}
+
+	e, data, err := arc.deadLetters.Get(id)
+	if err == deadletter.ErrNotFound {
+		return nil, nil, &utils.ResponseV2{
+			Code: http.StatusNotFound,
+			Msg:  http.StatusText(http.StatusNotFound),
+		}
+	}
+	if err != nil {
+		arc.logger.Errorw("deadLetters.Get", "id", id, "err", err)
+		return nil, nil, &utils.ResponseV2{
+			Code: http.StatusInternalServerError,
+			Msg:  err.Error(),
+		}
+	}
+	return e, data, nil
+}
@@ -0,0 +1,149 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	dataFileType = ".bin"
+	metaFileType = ".json"
+)
+
+var (
+	// ErrNotFound -
+	ErrNotFound = errors.New("dead letter not found")
+
+	idPattern = regexp.MustCompile(`^[0-9]{20}-[0-9]{6}$`)
+)
+
+// Entry 死信元数据, 原始字节保存在同名.bin文件中
+type Entry struct {
+	ID       string    `json:"id"`
+	Reason   string    `json:"reason"`           // 拒绝原因
+	Detail   string    `json:"detail,omitempty"` // 错误详情
+	SensorID string    `json:"sensorid,omitempty"`
+	Source   string    `json:"source,omitempty"`  // 数据来源
+	Decoder  string    `json:"decoder,omitempty"` // 帧解码器, 重新注入时使用
+	Offset   int       `json:"offset"`            // 在原始数据中的偏移
+	Size     int       `json:"size"`
+	Time     time.Time `json:"time"`
+}
+
+// Store 死信目录, 保存解码失败的原始字节
+type Store struct {
+	dir string
+	seq uint64
+}
+
+// New -
+func New(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("MKdirAll: %v", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Put 保存被拒绝的字节, 生成ID
+func (s *Store) Put(e *Entry, data []byte) error {
+	e.Time = time.Now().UTC()
+	e.Size = len(data)
+	e.ID = fmt.Sprintf("%s-%06d", strings.Replace(e.Time.Format("20060102150405.000000"), ".", "", 1), atomic.AddUint64(&s.seq, 1)%1000000)
+
+	if err := os.WriteFile(s.path(e.ID, dataFileType), data, 0644); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	// 元数据最后写入, 列表只读取元数据完整的死信
+	return os.WriteFile(s.path(e.ID, metaFileType), meta, 0644)
+}
+
+// List 按时间顺序列出死信
+func (s *Store) List() ([]*Entry, error) {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := []*Entry{}
+	for _, file := range files {
+		id := strings.TrimSuffix(file.Name(), metaFileType)
+		if file.IsDir() || !strings.HasSuffix(file.Name(), metaFileType) || !idPattern.MatchString(id) {
+			continue
+		}
+		e, err := s.entry(id)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// Get 读取死信元数据及原始字节
+func (s *Store) Get(id string) (*Entry, []byte, error) {
+	if !idPattern.MatchString(id) {
+		return nil, nil, ErrNotFound
+	}
+	e, err := s.entry(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(s.path(id, dataFileType))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+	return e, data, nil
+}
+
+// Remove -
+func (s *Store) Remove(id string) error {
+	if !idPattern.MatchString(id) {
+		return ErrNotFound
+	}
+	if err := os.Remove(s.path(id, metaFileType)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if err := os.Remove(s.path(id, dataFileType)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) entry(id string) (*Entry, error) {
+	meta, err := os.ReadFile(s.path(id, metaFileType))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	e := &Entry{}
+	if err := json.Unmarshal(meta, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
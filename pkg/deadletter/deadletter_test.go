@@ -0,0 +1,37 @@
+package deadletter
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDeadLetter(t *testing.T) {
+	Convey("DeadLetter", t, func() {
+		s, err := New(t.TempDir())
+		So(err, ShouldBeNil)
+
+		e := &Entry{Reason: "crc", SensorID: "A00000000001", Offset: 10}
+		So(s.Put(e, []byte{1, 2, 3}), ShouldBeNil)
+		So(s.Put(&Entry{Reason: "head"}, []byte{4}), ShouldBeNil)
+
+		entries, err := s.List()
+		So(err, ShouldBeNil)
+		So(len(entries), ShouldEqual, 2)
+		So(entries[0].ID, ShouldEqual, e.ID)
+		So(entries[0].Size, ShouldEqual, 3)
+
+		got, data, err := s.Get(e.ID)
+		So(err, ShouldBeNil)
+		So(got.Reason, ShouldEqual, "crc")
+		So(data, ShouldResemble, []byte{1, 2, 3})
+
+		_, _, err = s.Get("../" + e.ID)
+		So(err, ShouldEqual, ErrNotFound)
+
+		So(s.Remove(e.ID), ShouldBeNil)
+		So(s.Remove(e.ID), ShouldEqual, ErrNotFound)
+		entries, _ = s.List()
+		So(len(entries), ShouldEqual, 1)
+	})
+}
@@ -1,40 +1,68 @@
 package pkg
 
 import (
-	"bytes"
-	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"time"
 
+	"github.com/kiga-hub/arc-storage/pkg/deadletter"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
 	"github.com/kiga-hub/arc/protocols"
 )
 
 // parsedFrame parsed frame
 type parsedFrame struct {
-	timestamp       time.Time
-	idString        string
-	filenamesuffix  string
-	id              []byte
-	dataToStore     []byte
-	dataToStoreSize int
-	idUint64        uint64
+	timestamp      time.Time
+	idString       string
+	filenamesuffix string
+	id             []byte
+	segments       []decoder.Segment
+	idUint64       uint64
+}
+
+// decodeJob 待解码数据
+type decodeJob struct {
+	data    []byte
+	source  string                     // 数据来源, 记录到死信
+	decoder decoder.FrameDecoder       // 为空时使用 decoder.Default
+	ack     func(frames, rejected int) // 解码并进入处理队列后在解码协程中回调, 不能阻塞, 可为空
+	// persisted 帧写入预写日志后由处理协程回调, 写入失败时err不为空. 可为空
+	persisted func(err error)
+	// drain 停机屏障, 不含数据. 处理协程处理完之前的数据后将数据卷落盘, 结果写入drain
+	drain chan drainResult
 }
 
 // decodeWorker decode
-func (arc *ArcStorage) decodeWorker(input chan []byte, output chan decodeResult) {
+func (arc *ArcStorage) decodeWorker(input chan decodeJob, output chan decodeResult) {
 	for j := range input {
-		output <- arc.decode(j)
+		if j.drain != nil {
+			output <- decodeResult{drain: j.drain}
+			continue
+		}
+		dec := j.decoder
+		if dec == nil {
+			dec = decoder.Default
+		}
+		r := arc.decode(dec, j.source, j.data)
+		r.persisted = j.persisted
+		output <- r
+		if j.ack != nil {
+			j.ack(len(r.items), r.rejected)
+		}
 	}
 }
 
 // decodeResult result
 type decodeResult struct {
-	err   error
-	items []*parsedFrame
+	err       error
+	items     []*parsedFrame
+	rejected  int // 被拒绝的数据段数
+	persisted func(err error)
+	drain     chan drainResult
 }
 
-// decode decode
-func (arc *ArcStorage) decode(srcdata []byte) decodeResult {
+// decode decode. 校验失败的数据写入死信目录, 并从下一个可能的帧起始位置重新同步, 之后的完整帧仍然保留
+func (arc *ArcStorage) decode(dec decoder.FrameDecoder, source string, srcdata []byte) decodeResult {
 	result := decodeResult{
 		items: []*parsedFrame{},
 	}
@@ -44,101 +72,51 @@ func (arc *ArcStorage) decode(srcdata []byte) decodeResult {
 	copy(data, srcdata)
 
 	for index := 0; index < l; {
-		f := &protocols.Frame{}
-		copy(f.Head[:], data[index:index+4])
-		if !bytes.Equal(f.Head[:], protocols.Head[:]) {
-			// utils.Hexdump("test", data)
-			arc.logger.Errorf("bytes.Equal(f.Head[:]:%s, protocols.Head[:]:%s ,%d)", fmt.Sprintf("%X", f.Head[:]), fmt.Sprintf("%X", protocols.Head[:]), index)
-			break
+		n, reason, detail := dec.Split(data[index:])
+		if reason == "" {
+			f, err := dec.Decode(data[index : index+n])
+			if err != nil {
+				reason, detail = decoder.RejectDecode, err.Error()
+			} else {
+				result.items = append(result.items, arc.parseFrame(f))
+				index += n
+				continue
+			}
 		}
 
-		index += 4
-		if index >= l {
-			arc.logger.Errorf("index:%d >=l:%d break", index, l)
-			break
-		}
-
-		bytesBuffer := bytes.NewBuffer(data[index : index+4])
-		err := binary.Read(bytesBuffer, binary.BigEndian, &f.Size)
-		if err != nil {
-			arc.logger.Error("binary.Read(bytesBuffer, binary.BigEndian, &f.Size)")
-			break
-		}
-
-		index += 4
-		if index >= l {
-			arc.logger.Errorf("index:%d >=l:%d break", index, l)
-			break
-		}
-
-		bytesBuffer = bytes.NewBuffer(data[index : index+8])
-		err = binary.Read(bytesBuffer, binary.BigEndian, &f.Timestamp)
-		if err != nil {
-			arc.logger.Error("binary.Read(bytesBuffer, binary.BigEndian, &f.Timestamp)")
-			break
-		}
-		index += 8
-		if index >= l {
-			arc.logger.Errorf("index:%d >=l:%d break", index, l)
-			break
-		}
-
-		copy(f.ID[:], data[index:index+6])
-		index += 6
-		if index >= l {
-			arc.logger.Errorf("index:%d >=l:%d break", index, l)
-			break
-		}
-
-		//
-		dataToStore := data[index-protocols.DefaultHeadLength : index+int(f.Size)-int(protocols.LengthWithoutData)]
-		fmt.Println("dataToStore len :", len(dataToStore))
-		fmt.Println("dataToStore:", dataToStore)
-
-		if len(data) < index+int(f.Size)-int(protocols.LengthWithoutData) {
-			arc.logger.Errorf("len(data):%d < index:%d", len(data), index)
-			break
-		}
+		next := dec.Resync(data, index+1)
+		arc.rejectFrame(dec, source, data[index:next], index, reason, detail)
+		result.rejected++
+		index = next
+	}
 
-		err = f.DataGroup.Decode(data[index : index+int(f.Size)-int(protocols.LengthWithoutData)])
-		if err != nil {
-			arc.logger.Error("DataGroup.Decode", err)
-			break
-		}
-		index += int(f.Size) - int(protocols.LengthWithoutData)
-		if index >= l {
-			arc.logger.Errorf("index:%d >=l:%d break", index, l)
-			break
-		}
+	return result
+}
 
-		bytesBuffer = bytes.NewBuffer(data[index : index+2])
-		err = binary.Read(bytesBuffer, binary.BigEndian, &f.Crc)
-		if err != nil {
-			arc.logger.Error("binary.Read(bytesBuffer, binary.BigEndian, &f.Crc)")
-			break
-		}
-		index += 2
-		if index >= l {
-			arc.logger.Errorf("index:%d >=l:%d break", index, l)
-			break
-		}
-		f.End = data[len(data)-1]
-		index++
-
-		pf, err := arc.parseFrame(f, dataToStore)
-		if err == nil && pf != nil {
-			result.items = append(result.items, pf)
-			f = nil
-		} else if err != nil {
-			arc.logger.Errorf("parseFrame\n", err)
-		}
+// rejectFrame 被拒绝的字节写入死信目录
+func (arc *ArcStorage) rejectFrame(dec decoder.FrameDecoder, source string, data []byte, offset int, reason, detail string) {
+	e := &deadletter.Entry{
+		Reason:  reason,
+		Detail:  detail,
+		Source:  source,
+		Decoder: dec.Name(),
+		Offset:  offset,
 	}
+	if id, ok := dec.SensorID(data); ok {
+		e.SensorID = fmt.Sprintf("%X", id)
+	}
+	arc.logger.Warnw("rejectFrame", "reason", reason, "detail", detail, "offset", offset, "size", len(data), "sensorid", e.SensorID, "source", source, "decoder", e.Decoder)
 
-	return result
+	if arc.deadLetters == nil {
+		return
+	}
+	if err := arc.deadLetters.Put(e, data); err != nil {
+		arc.logger.Errorw("deadLetters.Put", "reason", reason, "err", err)
+	}
 }
 
-// parseFrame TODO move to common project
-func (arc *ArcStorage) parseFrame(f *protocols.Frame, dataToSave []byte) (*parsedFrame, error) {
+// parseFrame -
+func (arc *ArcStorage) parseFrame(f *decoder.Frame) *parsedFrame {
 	// ID 94c96000c248 []byte{0x94,0xC9,0x60,0x00,0xC2,0x48}
 	idUint64 := ByteToUInt64(f.ID[:])
 
@@ -146,23 +124,20 @@ func (arc *ArcStorage) parseFrame(f *protocols.Frame, dataToSave []byte) (*parse
 
 	suffix := idString + "_" + idString
 
-	timestamp := time.Unix(0, f.Timestamp*1e3)
-
 	if arc.kafka != nil {
-		if err := arc.kafka.Write(idUint64, f); err != nil {
+		if err := arc.kafka.Write(idUint64, arcFrame(f)); err != nil {
 			arc.logger.Errorw("WriteTokafkaErr", "err", err)
 		}
 	}
 
 	return &parsedFrame{
-		id:              f.ID[:],
-		idString:        idString,
-		idUint64:        idUint64,
-		timestamp:       timestamp,
-		dataToStore:     dataToSave,
-		dataToStoreSize: len(dataToSave),
-		filenamesuffix:  suffix,
-	}, nil
+		id:             f.ID[:],
+		idString:       idString,
+		idUint64:       idUint64,
+		timestamp:      f.Timestamp,
+		segments:       f.Segments,
+		filenamesuffix: suffix,
+	}
 }
 
 // ByteToUInt64  convert sensor []byte to uint64.
@@ -175,6 +150,18 @@ func ByteToUInt64(sensorID []byte) uint64 {
 		uint64(sensorID[0])<<40
 }
 
+// SensorIDToUInt64 convert sensor id hex string to uint64.
+func SensorIDToUInt64(sensorID string) (uint64, error) {
+	b, err := hex.DecodeString(sensorID)
+	if err != nil {
+		return 0, err
+	}
+	if len(b) != 6 {
+		return 0, fmt.Errorf("invalid sensor id %s", sensorID)
+	}
+	return ByteToUInt64(b), nil
+}
+
 // ParsedFrameConstructor - construct frame structure
 func ParsedFrameConstructor(segmentData protocols.ISegment, id uint64, timestamp time.Time) ([]byte, error) {
 	group := protocols.NewDefaultDataGroup()
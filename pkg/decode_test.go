@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/deadletter"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+	"github.com/kiga-hub/arc/logging"
+	"github.com/kiga-hub/arc/protocols"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDecode(t *testing.T) {
+	CaseDecodeResync(t)
+}
+
+func CaseDecodeResync(t *testing.T) {
+	Convey("decode resync", t, func() {
+		letters, err := deadletter.New(t.TempDir())
+		So(err, ShouldBeNil)
+		arc := &ArcStorage{logger: &logging.NoopLogger{}, deadLetters: letters}
+
+		f := &decoder.Frame{
+			ID:        [decoder.IDLength]byte{0xA0, 0, 0, 0, 0, 0x01},
+			Timestamp: time.UnixMicro(1700000000123456),
+			Segments:  []decoder.Segment{{SType: protocols.STypeArc, Data: []byte{1, 2, 3, 4}}},
+		}
+		good := decoder.AppendArc(nil, f)
+		corrupt := decoder.AppendArc(nil, f)
+		corrupt[len(corrupt)-2]++
+
+		// good + bad crc + good
+		data := append(append(append([]byte{}, good...), corrupt...), good...)
+		r := arc.decode(decoder.Arc{}, sourceHTTP, data)
+		So(r.err, ShouldBeNil)
+		So(len(r.items), ShouldEqual, 2)
+		So(r.rejected, ShouldEqual, 1)
+		So(r.items[1].idString, ShouldEqual, "A00000000001")
+		So(r.items[1].timestamp.Equal(f.Timestamp), ShouldBeTrue)
+
+		entries, err := letters.List()
+		So(err, ShouldBeNil)
+		So(len(entries), ShouldEqual, 1)
+		So(entries[0].Reason, ShouldEqual, decoder.RejectCrc)
+		So(entries[0].Offset, ShouldEqual, len(good))
+		So(entries[0].Size, ShouldEqual, len(corrupt))
+		So(entries[0].SensorID, ShouldEqual, "A00000000001")
+		So(entries[0].Source, ShouldEqual, sourceHTTP)
+	})
+}
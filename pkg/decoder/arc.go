@@ -0,0 +1,135 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/kiga-hub/arc/protocols"
+	"github.com/kiga-hub/arc/utils"
+)
+
+const (
+	// frameIDOffset head(4) size(4) timestamp(8)
+	frameIDOffset = protocols.DefaultHeadLength + 8
+	// frameDataOffset head(4) size(4) timestamp(8) id(6)
+	frameDataOffset = frameIDOffset + IDLength
+)
+
+// Arc protocols 帧: head(4) size(4) timestamp(8,us) id(6) DataGroup crc(2) end(1), BigEndian
+type Arc struct{}
+
+// Name -
+func (Arc) Name() string {
+	return "arc"
+}
+
+// Split 按 protocols 规则校验帧头、长度、帧尾及CRC
+func (Arc) Split(buf []byte) (n int, reason, detail string) {
+	if len(buf) < protocols.DefaultHeadLength {
+		return 0, RejectShort, fmt.Sprintf("len:%d", len(buf))
+	}
+	if !bytes.Equal(buf[:4], protocols.Head[:]) {
+		return 0, RejectHead, fmt.Sprintf("%X", buf[:4])
+	}
+	size := binary.BigEndian.Uint32(buf[4:protocols.DefaultHeadLength])
+	if size <= protocols.LengthWithoutData || size > protocols.MaxSize {
+		return 0, RejectSize, fmt.Sprintf("size:%d", size)
+	}
+	n = protocols.DefaultHeadLength + int(size)
+	if len(buf) < n {
+		return 0, RejectTruncated, fmt.Sprintf("size:%d len:%d", n, len(buf))
+	}
+	if buf[n-1] != protocols.End {
+		return 0, RejectEnd, fmt.Sprintf("%X", buf[n-1])
+	}
+	crc := binary.BigEndian.Uint16(buf[n-3 : n-1])
+	if sum := utils.CheckSum(buf[protocols.DefaultHeadLength : n-3]); crc != sum {
+		return 0, RejectCrc, fmt.Sprintf("crc:%04X want:%04X", crc, sum)
+	}
+	return n, "", ""
+}
+
+// Resync 查找下一个 protocols.Head
+func (Arc) Resync(data []byte, from int) int {
+	if from >= len(data) {
+		return len(data)
+	}
+	i := bytes.Index(data[from:], protocols.Head[:])
+	if i < 0 {
+		return len(data)
+	}
+	return from + i
+}
+
+// SensorID -
+func (Arc) SensorID(data []byte) ([]byte, bool) {
+	if len(data) < frameDataOffset || !bytes.HasPrefix(data, protocols.Head[:]) {
+		return nil, false
+	}
+	return data[frameIDOffset:frameDataOffset], true
+}
+
+// Decode protocols.Frame.Decode 只支持Arc数据段, DataGroup按布局解析全部数据段
+func (Arc) Decode(frame []byte) (*Frame, error) {
+	if len(frame) < frameDataOffset+3 {
+		return nil, fmt.Errorf("frame too short %d", len(frame))
+	}
+	segments, err := decodeDataGroup(frame[frameDataOffset : len(frame)-3])
+	if err != nil {
+		return nil, err
+	}
+	f := &Frame{
+		Timestamp: time.UnixMicro(int64(binary.BigEndian.Uint64(frame[protocols.DefaultHeadLength:frameIDOffset]))),
+		Segments:  segments,
+	}
+	copy(f.ID[:], frame[frameIDOffset:frameDataOffset])
+	return f, nil
+}
+
+// AppendArc 编码为 protocols 帧, 供设备模拟及测试使用. protocols.DataGroup.Encode 写入的数据段布局与Decode不一致
+func AppendArc(b []byte, f *Frame) []byte {
+	group := []byte{byte(len(f.Segments))}
+	for _, s := range f.Segments {
+		group = binary.BigEndian.AppendUint32(group, uint32(1+len(s.Data)))
+	}
+	for _, s := range f.Segments {
+		group = append(append(group, s.SType), s.Data...)
+	}
+
+	body := binary.BigEndian.AppendUint64(nil, uint64(f.Timestamp.UnixMicro()))
+	body = append(append(body, f.ID[:]...), group...)
+	body = binary.BigEndian.AppendUint16(body, utils.CheckSum(body))
+	body = append(body, protocols.End)
+
+	b = append(b, protocols.Head[:]...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(body)))
+	return append(b, body...)
+}
+
+// decodeDataGroup count(1) sizes(4*count) 每段 sType(1)+data
+func decodeDataGroup(data []byte) ([]Segment, error) {
+	if len(data) < 1 || data[0] == 0 {
+		return nil, fmt.Errorf("data group count")
+	}
+	count := int(data[0])
+	idx := 1 + 4*count
+	if len(data) < idx {
+		return nil, fmt.Errorf("data group sizes truncated, count %d len %d", count, len(data))
+	}
+
+	segments := make([]Segment, 0, count)
+	for i := 0; i < count; i++ {
+		size := int(binary.BigEndian.Uint32(data[1+4*i:]))
+		if size < 1 || idx+size > len(data) {
+			return nil, fmt.Errorf("data group segment %d size %d out of range", i, size)
+		}
+		segments = append(segments, Segment{SType: data[idx], Data: data[idx+1 : idx+size]})
+		idx += size
+	}
+	if idx != len(data) {
+		return nil, fmt.Errorf("data group size mismatch %d != %d", idx, len(data))
+	}
+	return segments, nil
+}
@@ -0,0 +1,86 @@
+package decoder
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// 帧被拒绝的原因
+const (
+	RejectShort     = "short"     // 不足帧头长度
+	RejectHead      = "head"      // 帧头不是 protocols.Head
+	RejectSize      = "size"      // 帧长度超出范围
+	RejectTruncated = "truncated" // 数据不完整
+	RejectEnd       = "end"       // 帧尾不是 protocols.End
+	RejectCrc       = "crc"       // CRC校验失败
+	RejectDecode    = "decode"    // 数据段解析失败
+)
+
+// IDLength 传感器ID长度
+const IDLength = 6
+
+// Segment 帧中的数据段
+type Segment struct {
+	SType byte   // protocols 数据段类型
+	Data  []byte // 不含类型码
+}
+
+// Frame 解码后的帧
+type Frame struct {
+	ID        [IDLength]byte
+	Timestamp time.Time
+	Segments  []Segment
+}
+
+// FrameDecoder 将接入的原始字节切分并解码为帧, 每种接入来源可以配置不同的实现
+type FrameDecoder interface {
+	// Name 配置中使用的名称
+	Name() string
+	// Split 校验data开头的一帧, 返回帧长度. 校验失败时返回拒绝原因
+	Split(data []byte) (n int, reason, detail string)
+	// Resync 校验失败后从from开始查找下一帧可能的起始位置, 找不到时返回len(data)
+	Resync(data []byte, from int) int
+	// SensorID 从帧或被拒绝的字节中读取传感器ID, 用于分配解码队列及死信记录
+	SensorID(data []byte) ([]byte, bool)
+	// Decode 解码Split得到的完整帧, 数据段引用frame
+	Decode(frame []byte) (*Frame, error)
+}
+
+// decoders 按名称注册的实现
+var decoders = map[string]FrameDecoder{}
+
+// Default 默认的 protocols 帧
+var Default FrameDecoder = Arc{}
+
+func init() {
+	Register(Arc{})
+	Register(Protobuf{})
+}
+
+// Register 注册实现, 同名覆盖
+func Register(d FrameDecoder) {
+	decoders[d.Name()] = d
+}
+
+// Get 按名称查找, 名称为空时返回默认实现
+func Get(name string) (FrameDecoder, error) {
+	if name == "" {
+		return Default, nil
+	}
+	d, ok := decoders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown frame decoder %q, available: %v", name, Names())
+	}
+	return d, nil
+}
+
+// Names 已注册的名称
+func Names() []string {
+	names := make([]string, 0, len(decoders))
+	for name := range decoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,112 @@
+package decoder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc/protocols"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDecoder(t *testing.T) {
+	CaseArc(t)
+	CaseProtobuf(t)
+	CaseGet(t)
+}
+
+var testFrame = &Frame{
+	ID:        [IDLength]byte{0xA0, 0, 0, 0, 0, 0x01},
+	Timestamp: time.UnixMicro(1700000000123456),
+	Segments: []Segment{
+		{SType: protocols.STypeArc, Data: []byte{1, 2, 3, 4}},
+		{SType: 11, Data: []byte{5, 6}},
+	},
+}
+
+// split 切分并解码全部帧, 返回帧及拒绝原因
+func split(d FrameDecoder, data []byte) ([]*Frame, []string) {
+	var (
+		frames  []*Frame
+		reasons []string
+	)
+	for index := 0; index < len(data); {
+		n, reason, _ := d.Split(data[index:])
+		if reason == "" {
+			f, err := d.Decode(data[index : index+n])
+			if err == nil {
+				frames = append(frames, f)
+				index += n
+				continue
+			}
+			reason = RejectDecode
+		}
+		reasons = append(reasons, reason)
+		index = d.Resync(data, index+1)
+	}
+	return frames, reasons
+}
+
+func CaseArc(t *testing.T) {
+	Convey("Arc", t, func() {
+		d := Arc{}
+		frame := AppendArc(nil, testFrame)
+		id, ok := d.SensorID(frame)
+		So(ok, ShouldBeTrue)
+		So(id, ShouldResemble, testFrame.ID[:])
+
+		// garbage and a corrupt frame between valid frames
+		data := append(AppendArc(nil, testFrame), 0x00, 0x01)
+		corrupt := AppendArc(nil, testFrame)
+		corrupt[len(corrupt)-2]++
+		data = append(append(data, corrupt...), frame...)
+
+		frames, reasons := split(d, data)
+		So(reasons, ShouldResemble, []string{RejectHead, RejectCrc})
+		So(len(frames), ShouldEqual, 2)
+		So(frames[1].ID, ShouldEqual, testFrame.ID)
+		So(frames[1].Timestamp.Equal(testFrame.Timestamp), ShouldBeTrue)
+		So(frames[1].Segments, ShouldResemble, testFrame.Segments)
+	})
+}
+
+func CaseProtobuf(t *testing.T) {
+	Convey("Protobuf", t, func() {
+		d := Protobuf{}
+		frame := AppendProtobuf(nil, testFrame)
+		id, ok := d.SensorID(frame)
+		So(ok, ShouldBeTrue)
+		So(id, ShouldResemble, testFrame.ID[:])
+
+		data := AppendProtobuf(frame, testFrame)
+		frames, reasons := split(d, data)
+		So(reasons, ShouldBeEmpty)
+		So(len(frames), ShouldEqual, 2)
+		So(frames[1].ID, ShouldEqual, testFrame.ID)
+		So(frames[1].Timestamp.Equal(testFrame.Timestamp), ShouldBeTrue)
+		So(frames[1].Segments, ShouldResemble, testFrame.Segments)
+
+		Convey("truncated", func() {
+			frames, reasons := split(d, data[:len(data)-1])
+			So(len(frames), ShouldEqual, 1)
+			So(reasons, ShouldResemble, []string{RejectTruncated})
+		})
+		Convey("no segment", func() {
+			frames, reasons := split(d, AppendProtobuf(nil, &Frame{ID: testFrame.ID, Timestamp: testFrame.Timestamp}))
+			So(frames, ShouldBeEmpty)
+			So(reasons, ShouldResemble, []string{RejectDecode})
+		})
+	})
+}
+
+func CaseGet(t *testing.T) {
+	Convey("Get", t, func() {
+		d, err := Get("")
+		So(err, ShouldBeNil)
+		So(d.Name(), ShouldEqual, "arc")
+		d, err = Get("protobuf")
+		So(err, ShouldBeNil)
+		So(d.Name(), ShouldEqual, "protobuf")
+		_, err = Get("json")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,190 @@
+package decoder
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kiga-hub/arc/protocols"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// proto/frame.proto 字段号
+const (
+	pbFrameID       protowire.Number = 1
+	pbFrameTime     protowire.Number = 2
+	pbFrameSegments protowire.Number = 3
+	pbSegmentSType  protowire.Number = 1
+	pbSegmentData   protowire.Number = 2
+)
+
+// Protobuf 长度前缀的protobuf帧: varint(len) + proto/frame.proto Frame.
+// 使用protowire直接解析, 不需要生成代码. 没有帧头及校验, 长度前缀错误后无法重新同步, 之后的数据全部拒绝
+type Protobuf struct{}
+
+// Name -
+func (Protobuf) Name() string {
+	return "protobuf"
+}
+
+// Split -
+func (Protobuf) Split(buf []byte) (n int, reason, detail string) {
+	size, prefix := protowire.ConsumeVarint(buf)
+	if prefix < 0 {
+		if len(buf) < protowire.SizeVarint(1<<63) {
+			return 0, RejectShort, fmt.Sprintf("len:%d", len(buf))
+		}
+		return 0, RejectSize, protowire.ParseError(prefix).Error()
+	}
+	if size == 0 || size > uint64(protocols.MaxSize) {
+		return 0, RejectSize, fmt.Sprintf("size:%d", size)
+	}
+	n = prefix + int(size)
+	if len(buf) < n {
+		return 0, RejectTruncated, fmt.Sprintf("size:%d len:%d", n, len(buf))
+	}
+	return n, "", ""
+}
+
+// Resync -
+func (Protobuf) Resync(data []byte, from int) int {
+	return len(data)
+}
+
+// SensorID -
+func (p Protobuf) SensorID(data []byte) ([]byte, bool) {
+	n, reason, _ := p.Split(data)
+	if reason != "" {
+		return nil, false
+	}
+	_, prefix := protowire.ConsumeVarint(data)
+	var id []byte
+	err := consumeFields(data[prefix:n], func(num protowire.Number, typ protowire.Type, b []byte) int {
+		if num == pbFrameID && typ == protowire.BytesType {
+			v, m := protowire.ConsumeBytes(b)
+			id = v
+			return m
+		}
+		return protowire.ConsumeFieldValue(num, typ, b)
+	})
+	if err != nil || len(id) != IDLength {
+		return nil, false
+	}
+	return id, true
+}
+
+// Decode -
+func (Protobuf) Decode(frame []byte) (*Frame, error) {
+	_, prefix := protowire.ConsumeVarint(frame)
+	if prefix < 0 {
+		return nil, protowire.ParseError(prefix)
+	}
+
+	f := &Frame{}
+	var (
+		id     []byte
+		segErr error
+	)
+	err := consumeFields(frame[prefix:], func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch {
+		case num == pbFrameID && typ == protowire.BytesType:
+			v, m := protowire.ConsumeBytes(b)
+			id = v
+			return m
+		case num == pbFrameTime && typ == protowire.VarintType:
+			v, m := protowire.ConsumeVarint(b)
+			f.Timestamp = time.UnixMicro(int64(v))
+			return m
+		case num == pbFrameSegments && typ == protowire.BytesType:
+			v, m := protowire.ConsumeBytes(b)
+			if m < 0 {
+				return m
+			}
+			s, err := decodeSegment(v)
+			if err != nil {
+				segErr = err
+				return -1
+			}
+			f.Segments = append(f.Segments, s)
+			return m
+		}
+		return protowire.ConsumeFieldValue(num, typ, b)
+	})
+	if segErr != nil {
+		return nil, segErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(id) != IDLength {
+		return nil, fmt.Errorf("invalid id length %d", len(id))
+	}
+	if len(f.Segments) == 0 {
+		return nil, fmt.Errorf("no segment")
+	}
+	copy(f.ID[:], id)
+	return f, nil
+}
+
+// AppendProtobuf 编码为长度前缀的protobuf帧, 供设备模拟及测试使用
+func AppendProtobuf(b []byte, f *Frame) []byte {
+	var m []byte
+	m = protowire.AppendTag(m, pbFrameID, protowire.BytesType)
+	m = protowire.AppendBytes(m, f.ID[:])
+	m = protowire.AppendTag(m, pbFrameTime, protowire.VarintType)
+	m = protowire.AppendVarint(m, uint64(f.Timestamp.UnixMicro()))
+	for _, s := range f.Segments {
+		var sm []byte
+		sm = protowire.AppendTag(sm, pbSegmentSType, protowire.VarintType)
+		sm = protowire.AppendVarint(sm, uint64(s.SType))
+		sm = protowire.AppendTag(sm, pbSegmentData, protowire.BytesType)
+		sm = protowire.AppendBytes(sm, s.Data)
+		m = protowire.AppendTag(m, pbFrameSegments, protowire.BytesType)
+		m = protowire.AppendBytes(m, sm)
+	}
+	b = protowire.AppendVarint(b, uint64(len(m)))
+	return append(b, m...)
+}
+
+// decodeSegment -
+func decodeSegment(b []byte) (Segment, error) {
+	var s Segment
+	stype := uint64(0)
+	err := consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) int {
+		switch {
+		case num == pbSegmentSType && typ == protowire.VarintType:
+			v, m := protowire.ConsumeVarint(b)
+			stype = v
+			return m
+		case num == pbSegmentData && typ == protowire.BytesType:
+			v, m := protowire.ConsumeBytes(b)
+			s.Data = v
+			return m
+		}
+		return protowire.ConsumeFieldValue(num, typ, b)
+	})
+	if err != nil {
+		return s, err
+	}
+	if stype > 0xFF {
+		return s, fmt.Errorf("segment stype %d out of range", stype)
+	}
+	s.SType = byte(stype)
+	return s, nil
+}
+
+// consumeFields 遍历消息的字段, fn返回字段值的长度, 负数为解析错误
+func consumeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) int) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		m := fn(num, typ, b)
+		if m < 0 {
+			return fmt.Errorf("field %d: %v", num, protowire.ParseError(m))
+		}
+		b = b[m:]
+	}
+	return nil
+}
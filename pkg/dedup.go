@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+)
+
+// duplicateFrame 帧在去重窗口内出现过. 重试或重复投递的帧以(传感器ID, 帧时间戳, 数据hash)识别
+func (arc *ArcStorage) duplicateFrame(afi *arc_volume.ArcVolume, key uint64, t time.Time, data []byte) bool {
+	if arc.config.Work.DedupHorizon <= 0 {
+		return false
+	}
+	if afi.Dedup == nil {
+		afi.Dedup = arc.loadDedupWindow(afi, key, t)
+	}
+	return afi.Dedup.Seen(t, data)
+}
+
+// loadDedupWindow 创建传感器的去重窗口, 从内存数据卷(含回放的预写日志)中恢复, 最近落盘的数据卷索引在写入队列中异步读取,
+// 服务重启后重试的帧不会重复写入.
+func (arc *ArcStorage) loadDedupWindow(afi *arc_volume.ArcVolume, key uint64, t time.Time) *arc_volume.DedupWindow {
+	horizon := time.Duration(arc.config.Work.DedupHorizon) * time.Second
+	d := arc_volume.NewDedupWindow(horizon)
+
+	afi.Frames(d.Add)
+	if a, ok := arc.arcFileStore.DataCache.Load(key | lateVolumeFlag); ok {
+		a.(*arc_volume.ArcVolume).Frames(d.Add)
+	}
+
+	st, _ := arc_volume.SegmentTypeByName(afi.Type)
+	arc.arcFileStore.LoadDedupWindow(d, afi.SensorID, []string{st.Name, st.LateType().Name}, t.Add(-horizon), t.Add(horizon))
+	return d
+}
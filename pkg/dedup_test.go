@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDedup(t *testing.T) {
+	CaseDedupAfterRestart(t)
+}
+
+func CaseDedupAfterRestart(t *testing.T) {
+	Convey("frames retried right after a restart are dropped", t, func() {
+		dataPath := t.TempDir()
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		submit := func(arc *ArcStorage, frames ...[]byte) {
+			for _, f := range frames {
+				id, _ := decoder.Default.SensorID(f)
+				arc.decodeJobChans[ByteToUInt64(id)&uint64(arc.config.Work.WorkCount-1)] <- arc.decodeJob(sourceHTTP, f)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			So(arc.shutdown(ctx).Lost(), ShouldEqual, 0)
+		}
+
+		arc, err := newTestArcStorage(dataPath, "dedup")
+		So(err, ShouldBeNil)
+		So(arc.config.Work.DedupHorizon, ShouldBeGreaterThan, 0)
+		submit(arc, testArcFrame(1, start, []byte{1}))
+
+		// restarted, the frame on disk is retried as the first frame of the sensor
+		arc, err = newTestArcStorage(dataPath, "dedupRestart")
+		So(err, ShouldBeNil)
+		submit(arc, testArcFrame(1, start, []byte{1}), testArcFrame(1, start.Add(time.Second), []byte{2}))
+
+		var got [][]byte
+		_, err = arc.arcFileStore.ReadFrames(context.Background(), "A00000000001", arc_volume.SegmentTypeArc.Name, start, start.Add(time.Minute), func(t time.Time, data []byte) error {
+			got = append(got, data)
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(got, ShouldResemble, [][]byte{{1}, {2}})
+	})
+}
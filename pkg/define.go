@@ -3,6 +3,10 @@ package pkg
 const (
 	// Success -
 	Success = 0
+	// ArcDataPath raw arc data query path
+	ArcDataPath = "api/data/v1/history/arc/data"
+	// MIMEAudioWav -
+	MIMEAudioWav = "audio/wav"
 )
 
 // SensorIDResponse is the response for getting sensor ids
@@ -20,6 +24,9 @@ type SensorItem struct {
 	TimeFrom     int64       `json:"time_from,omitempty"`
 	TimeTo       int64       `json:"time_to,omitempty"`
 	TimeDuration int64       `json:"time_duration,omitempty"`
+	Size         int64       `json:"size,omitempty"`        // 压缩前大小
+	StoredSize   int64       `json:"stored_size,omitempty"` // 磁盘占用
+	Codec        string      `json:"codec,omitempty"`       // 压缩算法, 未压缩时为空
 }
 
 // SensorQuery query details
@@ -35,3 +42,24 @@ type SensorQuery struct {
 	TimeFrom int64  `json:"time_from,omitempty"`
 	TimeTo   int64  `json:"time_to,omitempty"`
 }
+
+// SensorSeries aggregated series of a sensor
+type SensorSeries struct {
+	SensorID string        `json:"sensorid"`
+	Data     []SeriesPoint `json:"data"`
+	Count    int           `json:"count"`
+}
+
+// SeriesPoint aggregated value of a window, arc is null when the window is empty and fill is NULL
+type SeriesPoint struct {
+	Time int64    `json:"Time"` // window start, ms
+	Arc  *float64 `json:"arc"`
+}
+
+// UploadResult result of a frame upload, reasons counts rejected frames by reason
+type UploadResult struct {
+	Bytes    int            `json:"bytes"`
+	Accepted int            `json:"accepted"`
+	Rejected int            `json:"rejected"`
+	Reasons  map[string]int `json:"reasons,omitempty"`
+}
@@ -1,7 +1,6 @@
 package pkg
 
 import (
-	"bytes"
 	"fmt"
 	"math"
 	"net"
@@ -21,13 +20,17 @@ import (
 	"github.com/kiga-hub/arc/protobuf/pb"
 	"github.com/kiga-hub/arc/protocols"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
 
 	arcGRPC "github.com/kiga-hub/arc-storage/pkg/arc_grpc"
 	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
 	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc-storage/pkg/deadletter"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
 	"github.com/kiga-hub/arc-storage/pkg/kafka"
 	"github.com/kiga-hub/arc-storage/pkg/protostream"
+	ingestpb "github.com/kiga-hub/arc-storage/pkg/protostream/pb"
 )
 
 const (
@@ -43,6 +46,8 @@ var ArcStorageVersion = "appversion"
 const (
 	// TypeArc "Arc"
 	TypeArc = "Arc"
+	// TypeArcLate 早于乱序窗口的迟到帧
+	TypeArcLate = "ArcLate"
 )
 
 // ArcStorage arc storage struct
@@ -56,15 +61,25 @@ type ArcStorage struct {
 	decodeResultChans []chan decodeResult
 	timeoutChans      []chan uint64
 	kafka             kafka.Handler
+	consumer          *kafka.Consumer
 	grpcserver        *grpc.Server
+	grpcCreds         credentials.TransportCredentials // grpc.tls开启时的服务端证书, 为空时不加密
 	arcFileStore      *arc_volume.ArcVolumeCache
 	arcCache          *cache.DataCacheRepo
+	deadLetters       *deadletter.Store
+	wals              []*arc_volume.WAL // 每个工作协程一个预写日志
 	config            *config.ArcConfig
 	sensorIDsChan     chan []string
-	decodeJobChans    []chan []byte
+	decodeJobChans    []chan decodeJob
+	decoders          map[string]decoder.FrameDecoder // 按数据来源配置的帧解码器
+	unknownSTypes     sync.Map                        // 已告警的未注册数据段类型, 每种类型只告警一次
 	once              sync.Once
 	isConnectTaos     bool
 	serviceIsClosing  bool
+	gate              sync.RWMutex   // serviceIsClosing 变更与HTTP接入互斥
+	inflight          sync.WaitGroup // 进行中的HTTP接入
+	closing           chan struct{}  // 停机开始时关闭
+	grpcStreamDone    chan struct{}  // gRPCServerStream 退出
 	exportMetrics     *metric.HandlerMonitor
 }
 
@@ -76,16 +91,60 @@ func NewArcStorage(config *config.ArcConfig, logger logging.ILogger, gossipKVCac
 		return nil, err
 	}
 
-	arcFileStore, err := arc_volume.NewArcVolumeCache(logger, config, arc_volume.DataTypeMap[TypeArc])
+	// sensor sample rate, channel, sample width
+	if err := config.Sensor.LoadProfileFile(); err != nil {
+		return nil, err
+	}
+
+	// segment types besides arc
+	if config.Segment != nil {
+		for _, t := range config.Segment.Types {
+			if err := arc_volume.RegisterSegmentType(arc_volume.SegmentType{
+				SType:       byte(t.SType),
+				Name:        t.Name,
+				Dir:         t.Dir,
+				Ext:         t.Ext,
+				Description: t.Description,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	decoders, err := newDecoders(config.Decoder)
+	if err != nil {
+		return nil, err
+	}
+
+	// gRPC server credentials, a TLS misconfiguration fails the startup instead of serving plaintext
+	var grpcCreds credentials.TransportCredentials
+	if config.Grpc.Enable && config.Grpc.TLS {
+		grpcCreds, err = arcGRPC.ServerCredentials(arcGRPC.TLSOptions{
+			CertFile:       config.Grpc.CertFile,
+			KeyFile:        config.Grpc.KeyFile,
+			CAFile:         config.Grpc.CAFile,
+			ClientAuth:     config.Grpc.ClientAuth,
+			ReloadInterval: time.Duration(config.Grpc.ReloadInterval) * time.Second,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("grpc server credentials: %v", err)
+		}
+	}
+
+	arcFileStore, err := arc_volume.NewArcVolumeCache(logger, config, arc_volume.SegmentTypeArc.Dir)
 	if err != nil {
 		return nil, err
 	}
+	arcFileStore.Version = ArcStorageVersion
 
 	// initialize the mtric collection module
 
 	g := monitor.NewGRPC()
 	cacheRead := monitor.NewCacheRead()
-	m, err := metric.NewHandlerMonitor(g, cacheRead)
+	upload := monitor.NewHTTPUpload()
+	frameOrder := monitor.NewFrameOrder()
+	duplicate := monitor.NewDuplicateFrame()
+	m, err := metric.NewHandlerMonitor(g, cacheRead, upload, frameOrder, duplicate)
 	if err != nil {
 		return nil, err
 	}
@@ -94,10 +153,14 @@ func NewArcStorage(config *config.ArcConfig, logger logging.ILogger, gossipKVCac
 		working:           new(sync.Mutex),
 		timeoutSyncMap:    &sync.Map{},
 		logger:            logger,
-		decodeJobChans:    make([]chan []byte, config.Work.WorkCount),
+		decodeJobChans:    make([]chan decodeJob, config.Work.WorkCount),
 		decodeResultChans: make([]chan decodeResult, config.Work.WorkCount),
 		timeoutChans:      make([]chan uint64, config.Work.WorkCount),
 		arcFileStore:      arcFileStore,
+		decoders:          decoders,
+		grpcCreds:         grpcCreds,
+		closing:           make(chan struct{}),
+		grpcStreamDone:    make(chan struct{}),
 		grpcmessage:       make(chan protostream.ProtoStream, 1024),
 		isConnectTaos:     false,
 		exportMetrics:     m,
@@ -125,10 +188,22 @@ func NewArcStorage(config *config.ArcConfig, logger logging.ILogger, gossipKVCac
 		db.kafka = k
 	}
 
+	// bytes rejected by decode
+	if db.config.DeadLetter != nil && db.config.DeadLetter.Enable {
+		if db.deadLetters, err = deadletter.New(db.config.DeadLetter.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	// replay data not persisted before the last exit
+	if err := db.openWAL(); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
-func (arc *ArcStorage) receiveDataTimerTask(sigchan chan os.Signal) {
+func (arc *ArcStorage) receiveDataTimerTask() {
 	ticker := time.NewTicker(time.Minute * 1)
 	defer func() {
 		ticker.Stop()
@@ -136,8 +211,7 @@ func (arc *ArcStorage) receiveDataTimerTask(sigchan chan os.Signal) {
 
 	for {
 		select {
-		case sig := <-sigchan:
-			arc.logger.Errorf("Caught signal %v: terminating\n", sig)
+		case <-arc.closing:
 			return
 		case <-ticker.C:
 			// regularly check the timeout
@@ -194,28 +268,55 @@ func (arc *ArcStorage) Start(stop chan struct{}) {
 	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
 	//decode
 	for i := 0; i < arc.config.Work.WorkCount; i++ {
-		arc.decodeJobChans[i] = make(chan []byte, arc.config.Work.ChanCapacity*4)
+		arc.decodeJobChans[i] = make(chan decodeJob, arc.config.Work.ChanCapacity*4)
 		arc.decodeResultChans[i] = make(chan decodeResult, arc.config.Work.ChanCapacity*4)
 		// When the channle handles optimal allcation. timeoutChan only needs to allocate the capacity of the number of sensors.
 		arc.timeoutChans[i] = make(chan uint64, arc.config.Work.ChanCapacity)
 		go arc.decodeWorker(arc.decodeJobChans[i], arc.decodeResultChans[i])
-		go arc.handleDecodeResult(sigchan, i)
+		go arc.handleDecodeResult(i)
 	}
 
-	arc.serviceIsClosing = false
-
 	// check for timeout
-	go arc.receiveDataTimerTask(sigchan)
+	go arc.receiveDataTimerTask()
+
+	// consume raw frames from kafka, offsets are committed after the frames are in the wal
+	if arc.config.Kafka.Consume {
+		if arc.wals == nil {
+			arc.logger.Errorw("kafkaConsumer", "err", "wal must be enabled to consume frames from kafka")
+		} else if arc.consumer, err = kafka.NewConsumer(arc.config.Kafka, arc, arc.logger); err != nil {
+			arc.logger.Errorw("kafkaConsumer", "topic", arc.config.Kafka.ConsumeTopic, "err", err)
+		} else {
+			arc.consumer.Start()
+		}
+	}
+
+	// compress closed volumes in the background, stopped before the write queue is closed
+	if arc.config.Compress != nil && arc.config.Compress.Enable {
+		if err := arc.arcFileStore.StartCompressor(arc.closing); err != nil {
+			arc.logger.Errorw("StartCompressor", "codec", arc.config.Compress.Codec, "err", err)
+		}
+	}
+
+	// merge small volumes in the day folders
+	if arc.config.Compact != nil && arc.config.Compact.Enable {
+		arc.arcFileStore.StartCompactor(arc.closing)
+	}
+
+	// remove old data by the retention rules
+	if arc.config.Retention != nil && arc.config.Retention.Enable {
+		arc.arcFileStore.StartRetention(arc.closing)
+	}
 
 	// start gRPC server
 	if arc.config.Grpc.Enable {
 		arc.logger.Infow("Start gRPC Server", "arc.config.GrpcServer", arc.config.Grpc.Server)
-		go arc.gRPCServerStream(sigchan, arc.grpcmessage)
+		go arc.gRPCServerStream(arc.grpcmessage)
 
 		go func() {
 			arc.listen, err = net.Listen("tcp", arc.config.Grpc.Server)
 			if err != nil {
 				arc.logger.Errorw("gRPCListen", "gRPCServer", arc.config.Grpc.Server, "err", err)
+				return
 			}
 
 			var kaep = keepalive.EnforcementPolicy{
@@ -226,198 +327,158 @@ func (arc *ArcStorage) Start(stop chan struct{}) {
 				Time:    time.Duration(10) * arcGRPC.KeepAliveTime,
 				Timeout: time.Duration(3) * arcGRPC.KeepAliveTimeout,
 			}
-			arc.grpcserver = grpc.NewServer(
+			opts := []grpc.ServerOption{
 				grpc.InitialWindowSize(arcGRPC.InitialWindowSize),
 				grpc.InitialConnWindowSize(arcGRPC.InitialConnWindowSize),
 				grpc.KeepaliveParams(kasp),
 				grpc.KeepaliveEnforcementPolicy(kaep),
 				grpc.MaxRecvMsgSize(arcGRPC.MaxRecvMsgSize),
 				grpc.MaxSendMsgSize(arcGRPC.MaxSendMsgSize),
-			)
+			}
+
+			// TLS, optional client certificate verification
+			if arc.grpcCreds != nil {
+				opts = append(opts, grpc.Creds(arc.grpcCreds))
+			}
+
+			arc.grpcserver = grpc.NewServer(opts...)
 			// debugging tool
 			// reflection.Register(arc.grpcserver)
 
 			pb.RegisterFrameDataServer(arc.grpcserver, &protostream.FrameData{Grpcmessage: arc.grpcmessage})
+			ingestpb.RegisterFrameIngestServer(arc.grpcserver, &protostream.Ingest{
+				Submitter:  arc,
+				RetryAfter: time.Duration(arc.config.Grpc.RetryAfterMs) * time.Millisecond,
+			})
 			err = arc.grpcserver.Serve(arc.listen)
 			if err != nil {
 				arc.logger.Errorf("grpcserver.Serve: %s", err)
 			}
 		}()
 	}
-	<-stop
-	arc.Close()
-}
-
-// Close kafka & haystack store
-func (arc *ArcStorage) Close() {
-
-	arc.once.Do(func() {
-		if arc.config.Grpc.Enable {
-			close(arc.grpcmessage)
-			arc.grpcserver.Stop()
-			arc.listen.Close()
-		}
-		arc.serviceIsClosing = true
-		arc.timeoutSyncMap.Range(arc.quitSyncMapWalk)
-
-	})
-	// wait for all data to be written to disk.
-	time.Sleep(time.Second * 20)
-	arc.logger.Info("Quit!")
-	arc.arcFileStore.SafeClose()
-
-	// arc cache stop
-	if arc.arcCache != nil {
-		arc.arcCache.Close()
+	select {
+	case <-stop:
+	case sig := <-sigchan:
+		arc.logger.Warnw("Caught signal, terminating", "signal", sig)
 	}
+	arc.Close()
 }
 
 // handleDecodeResult -
-func (arc *ArcStorage) handleDecodeResult(sigchan chan os.Signal, workindex int) {
+func (arc *ArcStorage) handleDecodeResult(workindex int) {
 	for {
 		select {
-		case sig := <-sigchan:
-			arc.Close()
-			arc.logger.Errorf("Caught signal %v: terminating\n", sig)
 		case id := <-arc.timeoutChans[workindex]: // timeout handling.
 			arc.loadAndStoreTimeOutData(id)
 		case drc := <-arc.decodeResultChans[workindex]:
 			r := drc
+			if r.drain != nil {
+				// all data queued before shutdown has been handled
+				r.drain <- arc.flushShard(workindex)
+				continue
+			}
 			if r.err != nil {
 				arc.logger.Error(r.err)
+				if r.persisted != nil {
+					r.persisted(nil)
+				}
 				continue
 			}
 
+			// a frame missing from the WAL must not be marked as persisted, the message is consumed again
+			var walErr error
 			for _, rItem := range r.items {
 				item := rItem
-				data2store := make([]byte, item.dataToStoreSize)
-				copy(data2store, item.dataToStore[9:])
-
-				datagroup := &protocols.DataGroup{}
-				if err := datagroup.Decode(data2store); err != nil {
-					arc.logger.Warnw("ProtocolDataGroupDecode", "id", item.idString, "time", item.timestamp, "err", err)
-					continue
-				}
-
-				var argSegment *protocols.SegmentArc
-				var err error
-				if argSegment, err = datagroup.GetArcSegment(); err != nil {
-					arc.logger.Debugw("arc", "id", item.idString, "err", err)
-				}
-
-				arcData := make([]byte, len(argSegment.Data))
-				copy(arcData, argSegment.Data)
-
-				if arc.config.Cache.Enable {
-					dataPoint := &dataCache.DataPoint{
-						ID:   item.idUint64,
-						Time: item.timestamp, //us
-						Data: argSegment.Data,
+				for _, segment := range item.segments {
+					st, ok := arc_volume.SegmentTypeBySType(segment.SType)
+					if !ok {
+						if _, logged := arc.unknownSTypes.LoadOrStore(segment.SType, struct{}{}); !logged {
+							arc.logger.Warnw("unknown segment type, dropped without further warnings", "id", item.idString, "time", item.timestamp, "stype", segment.SType)
+						}
+						continue
 					}
-					// real-time data caching
-					arc.arcCache.Input(dataPoint)
-				}
+					data := make([]byte, len(segment.Data))
+					copy(data, segment.Data)
 
-				var afi *arc_volume.ArcVolume
-
-				secondHalfSize := 0
-
-				a, isAfiExist := arc.arcFileStore.DataCache.Load(item.idUint64)
-				// store arcVolume for the first time, create a new one if it does not exist.
-				if !isAfiExist {
-					buffer := bytes.NewBuffer([]byte{})
-					buffer.Grow(len(argSegment.Data) * 2)
-					buffer.Write(arcData)
-					afi = &arc_volume.ArcVolume{
-						Dir:        arc.config.Work.DataPath,
-						CreateTime: item.timestamp,
-						SensorID:   item.idString,
-						Buffer:     buffer,
-						Type:       TypeArc,
+					key := segmentKey(item.idUint64, st)
+					buffered, err := arc.bufferFrame(st, key, item.idString, item.timestamp, data)
+					if err != nil && walErr == nil {
+						walErr = err
 					}
-
-					arc.arcFileStore.DataCache.Store(item.idUint64, afi)
-
-					isAfiExist = false
-				}
-
-				if isAfiExist {
-					afi = a.(*arc_volume.ArcVolume)
-					afi.SaveTime = item.timestamp.UTC() //item.timestamp.UTC()
-					afi.LastTimestamp = item.timestamp
-					afi.Buffer.Write(arcData)
-
-					if err := arc.arcFileStore.PreWriteToFileCache(afi, item.timestamp, secondHalfSize); err != nil {
-						arc.logger.Errorw("storeToArcBigFile", "err", err)
+					if !buffered {
+						arc.logger.Debugw("duplicateFrame", "id", item.idString, "type", st.Name, "time", item.timestamp)
+						continue
 					}
-					arc.logger.Debugw("PreWriteToFileCache", "id", item.idString, "timeStamp", item.timestamp, "size", afi.Buffer.Len(), "startTime", afi.CreateTime)
 
-					// add idstirng to gossipKVCache
-					if arc.gossipKVCache != nil {
-						arc.sensorIDsChan <- []string{item.idString}
+					if arc.config.Cache.Enable && st.SType == protocols.STypeArc {
+						dataPoint := &dataCache.DataPoint{
+							ID:   item.idUint64,
+							Time: item.timestamp, //us
+							Data: data,
+						}
+						// real-time data caching
+						arc.arcCache.Input(dataPoint)
 					}
 
-					afi.Update(afi.CreateTime)
-
-					afi.Buffer.Write(arcData)
-
 					// store the current time for timeout handling
-					arc.timeoutSyncMap.Store(item.idUint64, time.Now().UTC())
-
+					arc.timeoutSyncMap.Store(key, time.Now().UTC())
 				}
 			}
+			if walErr != nil {
+				arc.logger.Warnw("handleDecodeResult", "items", len(r.items), "msg", "walAppend failed, not persisted", "err", walErr)
+			}
+			if r.persisted != nil {
+				r.persisted(walErr)
+			}
 		}
 	}
 }
 
-// gRPCServerStream -
-func (arc *ArcStorage) gRPCServerStream(sigchan chan os.Signal, message chan protostream.ProtoStream) {
-	isStop := false
+// gRPCServerStream 转发到解码队列, 收到IsStop后退出, 之前的消息已全部转发
+func (arc *ArcStorage) gRPCServerStream(message chan protostream.ProtoStream) {
+	defer close(arc.grpcStreamDone)
 
-	for {
-		if isStop {
-			break
-		}
-
-		select {
-		case sig := <-sigchan:
-			arc.logger.Errorf("Caught signal %v: terminating\n", sig)
+	for mes := range message {
+		if mes.IsStop {
 			return
+		}
 
-		case mes := <-message:
-			isStop = mes.IsStop
-
-			if len(mes.Key) > 0 {
-				arc.exportMetrics.SetGRPCLabelValues(fmt.Sprintf("%X", mes.Key[:]), float64(len(mes.Value)))
-				arc.decodeJobChans[ByteToUInt64(mes.Key)&uint64(arc.config.Work.WorkCount-1)] <- mes.Value
-				arc.logger.Debugw("gRPCServerStream", "key", mes.Key, "bufferSize", len(mes.Value))
-			}
+		if len(mes.Key) > 0 {
+			arc.exportMetrics.SetGRPCLabelValues(fmt.Sprintf("%X", mes.Key[:]), float64(len(mes.Value)))
+			arc.decodeJobChans[ByteToUInt64(mes.Key)&uint64(arc.config.Work.WorkCount-1)] <- arc.decodeJob(sourceGRPC, mes.Value)
+			arc.logger.Debugw("gRPCServerStream", "key", mes.Key, "bufferSize", len(mes.Value))
 		}
 	}
 }
 
-// loadAndStoreTimeOutData -
-func (arc *ArcStorage) loadAndStoreTimeOutData(sensorid uint64) {
+// loadAndStoreTimeOutData 数据卷落盘, 返回帧数及字节数. 落盘失败的数据卷保留在内存及预写日志中
+func (arc *ArcStorage) loadAndStoreTimeOutData(sensorid uint64) (int, int, error) {
 	a, isAfiExist := arc.arcFileStore.DataCache.Load(sensorid)
 	if !isAfiExist {
-		return
+		return 0, 0, nil
 	}
 
 	afi := a.(*arc_volume.ArcVolume)
+	if afi.Reorder != nil {
+		arc.appendFrames(afi, sensorid, afi.Reorder.Drain())
+	}
 	arc.logger.Debugw("loadAndStoreTimeOutData", "len", afi.Buffer.Len())
 
 	if afi.Buffer.Len() < 1 {
-		return
+		return 0, 0, nil
 	}
 
-	arc.logger.Warnw("loadAndStoreTimeOutData", "id", afi.SensorID, "len", afi.Buffer.Len(), "afi.CreateTime", afi.CreateTime)
+	frames, size := len(afi.Index), afi.Buffer.Len()
+	arc.logger.Warnw("loadAndStoreTimeOutData", "id", afi.SensorID, "len", size, "afi.CreateTime", afi.CreateTime)
 	if err := arc.arcFileStore.PreWriteToFileCache(afi, afi.SaveTime, 0); err != nil {
 		arc.logger.Errorw("storeToAduioBigFile", "err", err)
-		return
+		return 0, 0, err
 	}
 	arc.arcFileStore.DataCache.Delete(sensorid)
+	arc.walCheckpoint(sensorid, false)
 	// delete timeout map elem
 	arc.timeoutSyncMap.Delete(sensorid)
+	return frames, size, nil
 }
 
 // timeOutSyncMapWalk - Timeout exceeds 1 minute，trigger a certain operation.
@@ -430,9 +491,3 @@ func (arc *ArcStorage) timeOutSyncMapWalk(key, value interface{}) bool {
 
 	return true
 }
-
-// quitSyncMapWalk - quit
-func (arc *ArcStorage) quitSyncMapWalk(key, value interface{}) bool {
-	arc.timeoutChans[key.(uint64)&uint64(arc.config.Work.WorkCount-1)] <- key.(uint64)
-	return true
-}
@@ -0,0 +1,98 @@
+package pkg
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+	"github.com/kiga-hub/arc-storage/pkg/metric"
+	"github.com/kiga-hub/arc-storage/pkg/metric/monitor"
+	"github.com/kiga-hub/arc/logging"
+	"github.com/kiga-hub/arc/protocols"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var (
+	testMetricsOnce sync.Once
+	testMetrics     *metric.HandlerMonitor
+	testMetricsErr  error
+)
+
+// newTestArcStorage 只运行解码及处理协程的ArcStorage, 不启动接入. fileType区分各测试注册的数据卷指标
+func newTestArcStorage(dataPath, fileType string) (*ArcStorage, error) {
+	testMetricsOnce.Do(func() {
+		testMetrics, testMetricsErr = metric.NewHandlerMonitor(monitor.NewGRPC(), monitor.NewCacheRead(), monitor.NewHTTPUpload(), monitor.NewFrameOrder(), monitor.NewDuplicateFrame())
+	})
+	if testMetricsErr != nil {
+		return nil, testMetricsErr
+	}
+
+	config.SetDefaultArcConfig()
+	conf := config.GetConfig()
+	conf.Work.DataPath = dataPath
+	conf.Work.WorkCount = 2
+	conf.Work.ChanCapacity = 16
+	conf.Work.ReorderWindow = 0
+	conf.Work.Durability = arc_volume.DurabilityNone
+	conf.Cache.Enable = false
+	conf.Grpc.Enable = false
+	conf.WAL.Enable = false
+
+	arcFileStore, err := arc_volume.NewArcVolumeCache(&logging.NoopLogger{}, conf, fileType)
+	if err != nil {
+		return nil, err
+	}
+	arc := &ArcStorage{
+		config:            conf,
+		working:           new(sync.Mutex),
+		timeoutSyncMap:    &sync.Map{},
+		logger:            &logging.NoopLogger{},
+		decodeJobChans:    make([]chan decodeJob, conf.Work.WorkCount),
+		decodeResultChans: make([]chan decodeResult, conf.Work.WorkCount),
+		timeoutChans:      make([]chan uint64, conf.Work.WorkCount),
+		arcFileStore:      arcFileStore,
+		closing:           make(chan struct{}),
+		grpcStreamDone:    make(chan struct{}),
+		exportMetrics:     testMetrics,
+	}
+	for i := 0; i < conf.Work.WorkCount; i++ {
+		arc.decodeJobChans[i] = make(chan decodeJob, conf.Work.ChanCapacity)
+		arc.decodeResultChans[i] = make(chan decodeResult, conf.Work.ChanCapacity)
+		arc.timeoutChans[i] = make(chan uint64, conf.Work.ChanCapacity)
+		go arc.decodeWorker(arc.decodeJobChans[i], arc.decodeResultChans[i])
+		go arc.handleDecodeResult(i)
+	}
+	return arc, nil
+}
+
+// testArcFrame 传感器的一个Arc数据段帧
+func testArcFrame(id byte, t time.Time, data []byte) []byte {
+	return decoder.AppendArc(nil, &decoder.Frame{
+		ID:        [decoder.IDLength]byte{0xA0, 0, 0, 0, 0, id},
+		Timestamp: t,
+		Segments:  []decoder.Segment{{SType: protocols.STypeArc, Data: data}},
+	})
+}
+
+func TestNewArcStorage(t *testing.T) {
+	CaseGRPCCredentials(t)
+}
+
+func CaseGRPCCredentials(t *testing.T) {
+	Convey("NewArcStorage fails on invalid gRPC TLS credentials", t, func() {
+		config.SetDefaultArcConfig()
+		conf := config.GetConfig()
+		conf.Work.DataPath = t.TempDir()
+		conf.Grpc.Enable = true
+		conf.Grpc.TLS = true
+		conf.Grpc.CertFile = conf.Work.DataPath + "/missing.crt"
+		conf.Grpc.KeyFile = conf.Work.DataPath + "/missing.key"
+
+		_, err := NewArcStorage(conf, &logging.NoopLogger{}, nil, nil)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "grpc server credentials")
+	})
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+)
+
+// 数据来源, 每种来源可以配置不同的帧解码器
+const (
+	sourceGRPC  = "grpc"
+	sourceKafka = "kafka"
+	sourceHTTP  = "http"
+)
+
+// newDecoders 按配置创建各数据来源的帧解码器
+func newDecoders(conf *config.DecoderConfig) (map[string]decoder.FrameDecoder, error) {
+	if conf == nil {
+		conf = &config.DecoderConfig{}
+	}
+	decoders := map[string]decoder.FrameDecoder{}
+	for source, name := range map[string]string{
+		sourceGRPC:  conf.GRPC,
+		sourceKafka: conf.Kafka,
+		sourceHTTP:  conf.HTTP,
+	} {
+		d, err := decoder.Get(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", source, err)
+		}
+		decoders[source] = d
+	}
+	return decoders, nil
+}
+
+// decoderOf 数据来源的帧解码器
+func (arc *ArcStorage) decoderOf(source string) decoder.FrameDecoder {
+	if d, ok := arc.decoders[source]; ok {
+		return d
+	}
+	return decoder.Default
+}
+
+// decodeJob -
+func (arc *ArcStorage) decodeJob(source string, data []byte) decodeJob {
+	return decodeJob{data: data, source: source, decoder: arc.decoderOf(source)}
+}
+
+// Submit 提交数据到传感器所在的解码队列, 队列已满时不阻塞, 返回false
+func (arc *ArcStorage) Submit(key, value []byte, ack func(frames, rejected int)) bool {
+	ch := arc.decodeJobChans[ByteToUInt64(key)&uint64(arc.config.Work.WorkCount-1)]
+	job := arc.decodeJob(sourceGRPC, value)
+	job.ack = ack
+	select {
+	case ch <- job:
+		arc.exportMetrics.SetGRPCLabelValues(fmt.Sprintf("%X", key), float64(len(value)))
+		return true
+	default:
+		return false
+	}
+}
+
+// Credit 传感器所在解码队列的剩余容量
+func (arc *ArcStorage) Credit(key []byte) int {
+	if len(key) < 6 {
+		return 0
+	}
+	ch := arc.decodeJobChans[ByteToUInt64(key)&uint64(arc.config.Work.WorkCount-1)]
+	return cap(ch) - len(ch)
+}
+
+// Enqueue 阻塞提交到传感器所在的解码队列, 帧写入预写日志后回调persisted, 写入失败时回调的err不为空.
+// key为空时使用帧内的传感器ID, 无法识别的数据进入第一个队列并由解码协程拒绝.
+func (arc *ArcStorage) Enqueue(ctx context.Context, key, value []byte, persisted func(err error)) error {
+	job := arc.decodeJob(sourceKafka, value)
+	job.persisted = persisted
+	if len(key) < decoder.IDLength {
+		key, _ = job.decoder.SensorID(value)
+	}
+	var id uint64
+	if len(key) >= decoder.IDLength {
+		id = ByteToUInt64(key)
+	}
+	select {
+	case arc.decodeJobChans[id&uint64(arc.config.Work.WorkCount-1)] <- job:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sync 预写日志刷盘
+func (arc *ArcStorage) Sync() error {
+	for _, w := range arc.wals {
+		if err := w.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	"github.com/kiga-hub/arc/logging"
+)
+
+// pollTimeout 单次拉取等待时间，单位:ms
+const pollTimeout = 100
+
+// FrameSink 原始协议帧的接收端
+type FrameSink interface {
+	// Enqueue 阻塞提交到解码队列, 数据写入预写日志后回调persisted, 写入失败时err不为空
+	Enqueue(ctx context.Context, key, value []byte, persisted func(err error)) error
+	// Sync 已回调persisted的数据刷盘
+	Sync() error
+}
+
+// Consumer 从kafka读取原始协议帧, 与gRPC数据进入同一组解码队列.
+// 自动提交关闭, 数据写入预写日志并刷盘后才提交偏移, 服务崩溃时未提交的消息重新消费.
+// 写入预写日志失败时分区回退到第一条未完成的消息重新消费, 回退失败时停止消费.
+type Consumer struct {
+	consumer *ckafka.Consumer
+	topic    string
+	interval time.Duration
+	sink     FrameSink
+	offsets  *offsetTracker
+	logger   logging.ILogger
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// NewConsumer -
+func NewConsumer(conf *config.KafkaConfig, sink FrameSink, logger logging.ILogger) (*Consumer, error) {
+	consumer, err := ckafka.NewConsumer(&ckafka.ConfigMap{
+		"bootstrap.servers":  conf.Server,
+		"group.id":           conf.ConsumeGroupID,
+		"message.max.bytes":  conf.MessageMaxBytes,
+		"enable.auto.commit": false,
+		"auto.offset.reset":  "earliest",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Consumer{
+		consumer: consumer,
+		topic:    conf.ConsumeTopic,
+		interval: time.Duration(conf.CommitInterval) * time.Millisecond,
+		sink:     sink,
+		offsets:  newOffsetTracker(),
+		logger:   logger,
+		done:     make(chan struct{}),
+	}
+	if err := consumer.SubscribeTopics([]string{c.topic}, c.rebalance); err != nil {
+		consumer.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Start -
+func (c *Consumer) Start() {
+	var ctx context.Context
+	ctx, c.cancel = context.WithCancel(context.Background())
+	go c.run(ctx)
+	c.logger.Infow("kafka consumer start", "topic", c.topic)
+}
+
+// Stop 停止拉取消息, 不提交偏移. 已提交到解码队列的消息由停机排空处理后再调用Close提交
+func (c *Consumer) Stop() {
+	if c.cancel == nil {
+		return
+	}
+	c.cancel()
+	<-c.done
+	c.logger.Infow("kafka consumer stop", "topic", c.topic)
+}
+
+// Close 停止消费, 提交已落盘的偏移并关闭
+func (c *Consumer) Close() {
+	c.Stop()
+	c.commit()
+	if err := c.consumer.Close(); err != nil {
+		c.logger.Errorw("kafka consumer close", "err", err)
+	}
+}
+
+// run -
+func (c *Consumer) run(ctx context.Context) {
+	defer close(c.done)
+
+	lastCommit := time.Now()
+	for ctx.Err() == nil {
+		switch e := c.consumer.Poll(pollTimeout).(type) {
+		case *ckafka.Message:
+			if err := c.handle(ctx, e); err != nil {
+				return
+			}
+		case ckafka.Error:
+			c.logger.Errorw("kafka consumer", "code", e.Code(), "err", e)
+		}
+
+		if err := c.rewind(ctx); err != nil {
+			c.logger.Errorw("kafka consumer stopped", "topic", c.topic, "err", err)
+			return
+		}
+
+		if time.Since(lastCommit) >= c.interval {
+			c.commit()
+			lastCommit = time.Now()
+		}
+	}
+}
+
+// rewind 写入预写日志失败的分区回退到第一条未完成的消息, 间隔一个提交周期后重新消费
+func (c *Consumer) rewind(ctx context.Context) error {
+	offsets := c.offsets.rewind()
+	if len(offsets) == 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		// 未提交的偏移在重启后重新消费
+		return nil
+	case <-time.After(c.interval):
+	}
+	for partition, offset := range offsets {
+		c.logger.Warnw("kafka consumer rewind", "partition", partition, "offset", offset)
+		if err := c.consumer.Seek(ckafka.TopicPartition{Topic: &c.topic, Partition: partition, Offset: ckafka.Offset(offset)}, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle -
+func (c *Consumer) handle(ctx context.Context, msg *ckafka.Message) error {
+	partition, offset := msg.TopicPartition.Partition, int64(msg.TopicPartition.Offset)
+	epoch := c.offsets.track(partition, offset)
+	if len(msg.Value) == 0 {
+		c.offsets.done(partition, offset, epoch)
+		return nil
+	}
+	return c.sink.Enqueue(ctx, msg.Key, msg.Value, func(err error) {
+		if err != nil {
+			c.offsets.fail(partition, offset, epoch)
+			return
+		}
+		c.offsets.done(partition, offset, epoch)
+	})
+}
+
+// commit 刷盘后提交连续完成的偏移
+func (c *Consumer) commit() {
+	offsets := c.offsets.ready()
+	if len(offsets) == 0 {
+		return
+	}
+	if err := c.sink.Sync(); err != nil {
+		c.logger.Errorw("kafka consumer sync", "err", err)
+		return
+	}
+
+	tps := make([]ckafka.TopicPartition, 0, len(offsets))
+	for partition, offset := range offsets {
+		tps = append(tps, ckafka.TopicPartition{Topic: &c.topic, Partition: partition, Offset: ckafka.Offset(offset)})
+	}
+	if _, err := c.consumer.CommitOffsets(tps); err != nil {
+		c.logger.Errorw("kafka consumer commit", "err", err)
+		return
+	}
+	c.offsets.committed(offsets)
+}
+
+// rebalance 分区被回收前提交已完成的偏移
+func (c *Consumer) rebalance(_ *ckafka.Consumer, e ckafka.Event) error {
+	if revoked, ok := e.(ckafka.RevokedPartitions); ok {
+		c.commit()
+		for _, tp := range revoked.Partitions {
+			c.offsets.revoke(tp.Partition)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package kafka
+
+import "sync"
+
+// partitionOffsets 分区内按消费顺序排列的偏移
+type partitionOffsets struct {
+	pending   []int64        // 已提交到解码队列, 按消费顺序
+	done      map[int64]bool // 已写入预写日志
+	next      int64          // 可提交的偏移, 即最后一条连续完成的消息偏移+1
+	committed int64
+	epoch     int  // 每次回退后加一, 回退前消费的消息的回调被忽略
+	failed    bool // 有消息写入预写日志失败, 需要回退重新消费
+}
+
+// offsetTracker 记录每个分区消息的处理进度.
+// 不同传感器的数据由不同工作协程处理, 完成顺序与消费顺序不同, 只提交连续完成的最大偏移.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[int32]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int32]*partitionOffsets)}
+}
+
+// track 消息提交到解码队列前调用, 返回回调done及fail时使用的epoch
+func (t *offsetTracker) track(partition int32, offset int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[partition]
+	if !ok {
+		p = &partitionOffsets{done: make(map[int64]bool), next: -1, committed: -1}
+		t.partitions[partition] = p
+	}
+	p.pending = append(p.pending, offset)
+	return p.epoch
+}
+
+// done 消息已写入预写日志
+func (t *offsetTracker) done(partition int32, offset int64, epoch int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.partitions[partition]
+	if !ok || p.epoch != epoch {
+		// 分区已被回收或已回退
+		return
+	}
+	p.done[offset] = true
+	i := 0
+	for ; i < len(p.pending) && p.done[p.pending[i]]; i++ {
+		delete(p.done, p.pending[i])
+		p.next = p.pending[i] + 1
+	}
+	p.pending = p.pending[i:]
+}
+
+// fail 消息写入预写日志失败, 分区在rewind时回退
+func (t *offsetTracker) fail(partition int32, offset int64, epoch int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.partitions[partition]; ok && p.epoch == epoch {
+		p.failed = true
+	}
+}
+
+// rewind 返回有失败消息的分区及第一条未完成的偏移, 消费者回退到该偏移重新消费.
+// 分区中未完成的消息全部重新消费, 之前的回调被忽略
+func (t *offsetTracker) rewind() map[int32]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	offsets := make(map[int32]int64)
+	for partition, p := range t.partitions {
+		if !p.failed || len(p.pending) == 0 {
+			continue
+		}
+		offsets[partition] = p.pending[0]
+		p.pending = nil
+		p.done = make(map[int64]bool)
+		p.failed = false
+		p.epoch++
+	}
+	return offsets
+}
+
+// ready 返回有新进度的分区及待提交偏移
+func (t *offsetTracker) ready() map[int32]int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	offsets := make(map[int32]int64)
+	for partition, p := range t.partitions {
+		if p.next > p.committed {
+			offsets[partition] = p.next
+		}
+	}
+	return offsets
+}
+
+// committed 偏移提交成功后调用
+func (t *offsetTracker) committed(offsets map[int32]int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for partition, offset := range offsets {
+		if p, ok := t.partitions[partition]; ok && offset > p.committed {
+			p.committed = offset
+		}
+	}
+}
+
+// revoke 分区被分配给其他消费者, 未完成的消息由新的消费者重新消费
+func (t *offsetTracker) revoke(partition int32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.partitions, partition)
+}
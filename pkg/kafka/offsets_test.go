@@ -0,0 +1,65 @@
+package kafka
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	Convey("OffsetTracker", t, func() {
+		tracker := newOffsetTracker()
+		for _, offset := range []int64{10, 11, 13} {
+			tracker.track(0, offset)
+		}
+		tracker.track(1, 5)
+		So(tracker.ready(), ShouldBeEmpty)
+
+		// out of order, 11 is not done yet
+		tracker.done(0, 13, 0)
+		tracker.done(0, 10, 0)
+		tracker.done(1, 5, 0)
+		So(tracker.ready(), ShouldResemble, map[int32]int64{0: 11, 1: 6})
+
+		tracker.committed(map[int32]int64{0: 11, 1: 6})
+		So(tracker.ready(), ShouldBeEmpty)
+
+		tracker.done(0, 11, 0)
+		So(tracker.ready(), ShouldResemble, map[int32]int64{0: 14})
+
+		// revoked partition ignores late acks
+		tracker.revoke(0)
+		tracker.done(0, 14, 0)
+		So(tracker.ready(), ShouldBeEmpty)
+	})
+
+	Convey("OffsetTracker rewinds a partition with a failed message", t, func() {
+		tracker := newOffsetTracker()
+		for _, offset := range []int64{10, 11, 12, 13} {
+			tracker.track(0, offset)
+		}
+		tracker.track(1, 5)
+		tracker.done(0, 10, 0)
+		tracker.done(0, 13, 0)
+		tracker.fail(0, 12, 0)
+		tracker.done(1, 5, 0)
+		So(tracker.ready(), ShouldResemble, map[int32]int64{0: 11, 1: 6})
+		tracker.committed(map[int32]int64{0: 11, 1: 6})
+
+		// consumed again from the first unfinished message
+		So(tracker.rewind(), ShouldResemble, map[int32]int64{0: 11})
+		So(tracker.rewind(), ShouldBeEmpty)
+
+		// acks of the messages consumed before the rewind are ignored
+		tracker.done(0, 11, 0)
+		So(tracker.ready(), ShouldBeEmpty)
+
+		for _, offset := range []int64{11, 12, 13} {
+			So(tracker.track(0, offset), ShouldEqual, 1)
+		}
+		for _, offset := range []int64{11, 12, 13} {
+			tracker.done(0, offset, 1)
+		}
+		So(tracker.ready(), ShouldResemble, map[int32]int64{0: 14})
+	})
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/metric"
+	"github.com/kiga-hub/arc/utils"
+)
+
+// readMergedData read the time range from disk first, then the part after disk coverage from the real-time cache.
+func (arc *ArcStorage) readMergedData(sensorid, filetype string, t1, t2 time.Time) ([]byte, *utils.ResponseV2) {
+	data, end, resp := arc.arcFileStore.ReadDataByQueue(sensorid, filetype, t1, t2)
+	if resp != nil && resp.Code != http.StatusNotFound {
+		return nil, resp
+	}
+
+	// data not yet persisted is still in the cache
+	from := t1
+	if len(data) > 0 && end.After(t1) {
+		from = end
+	}
+	data = append(data, arc.searchCacheTail(sensorid, filetype, from, t2)...)
+
+	if len(data) < 1 {
+		return nil, &utils.ResponseV2{
+			Code: http.StatusNotFound,
+			Msg:  http.StatusText(http.StatusNotFound),
+		}
+	}
+	return data, nil
+}
+
+// searchCacheTail search the real-time cache for [from, to). the overlap with disk data is excluded by the caller.
+func (arc *ArcStorage) searchCacheTail(sensorid, filetype string, from, to time.Time) []byte {
+	if arc.arcCache == nil || filetype != TypeArc || !from.Before(to) {
+		return nil
+	}
+
+	id, err := SensorIDToUInt64(sensorid)
+	if err != nil {
+		arc.logger.Debugw("SensorIDToUInt64", "sensorid", sensorid, "err", err)
+		return nil
+	}
+
+	data, _, err := arc.arcCache.Search(arc.logger, id, from, to, arc.config.Work.FrameOffset, arc.config.Sensor.GetProfile(sensorid))
+	if err != nil {
+		arc.logger.Debugw("arcCache.Search", "sensorid", sensorid, "from", from, "to", to, "err", err)
+		arc.exportMetrics.SetCacheReadValues(sensorid, metric.MonitorFailed)
+		return nil
+	}
+	arc.exportMetrics.SetCacheReadValues(sensorid, metric.MonitorSuccess)
+	return data
+}
@@ -11,6 +11,10 @@ const (
 	MonitorSuccess = "success"
 	// MonitorFailed 统计失败项
 	MonitorFailed = "failed"
+	// MonitorReordered 乱序窗口内重新排序的帧
+	MonitorReordered = "reordered"
+	// MonitorLate 早于已落入数据卷的帧, 写入迟到帧数据卷
+	MonitorLate = "late"
 )
 
 const (
@@ -31,6 +35,12 @@ const (
 	MonitorDiskWriteErr = "disk_write_err"
 	// MonitorGRPCBytes GRPC接受数据量
 	MonitorGRPCBytes = "gRPC_bytes"
+	// MonitorHTTPUploadBytes HTTP上传请求大小
+	MonitorHTTPUploadBytes = "http_upload_bytes"
+	// MonitorFrameOrder 乱序和迟到帧数
+	MonitorFrameOrder = "frame_order"
+	// MonitorDuplicateFrames 丢弃的重复帧数
+	MonitorDuplicateFrames = "duplicate_frames"
 
 )
 
@@ -38,13 +48,19 @@ const (
 type HandlerMonitor struct {
 	gRPCMetric              GRPCMetric              // GRPC数据量指标
 	cacheMetric             CacheReadMetric         // 缓存读取指标
+	uploadMetric            HTTPUploadMetric        // HTTP上传指标
+	frameOrderMetric        FrameOrderMetric        // 乱序帧指标
+	duplicateMetric         DuplicateFrameMetric    // 重复帧指标
 }
 
 // NewHandlerMonitor .
-func NewHandlerMonitor(grpc GRPCMetric,cacheRead CacheReadMetric) (*HandlerMonitor, error) {
+func NewHandlerMonitor(grpc GRPCMetric,cacheRead CacheReadMetric, upload HTTPUploadMetric, frameOrder FrameOrderMetric, duplicate DuplicateFrameMetric) (*HandlerMonitor, error) {
 	h := &HandlerMonitor{
 		gRPCMetric:              grpc,
 		cacheMetric:             cacheRead,
+		uploadMetric:            upload,
+		frameOrderMetric:        frameOrder,
+		duplicateMetric:         duplicate,
 	}
 	if err := h.registerHandlerMonitor(); err != nil {
 		return nil, errors.Wrap(err, "注册handler监控服务")
@@ -58,6 +74,15 @@ func (h *HandlerMonitor) registerHandlerMonitor() error {
 	if err := h.cacheMetric.Register(); err != nil {
 		return err
 	}
+	if err := h.uploadMetric.Register(); err != nil {
+		return err
+	}
+	if err := h.frameOrderMetric.Register(); err != nil {
+		return err
+	}
+	if err := h.duplicateMetric.Register(); err != nil {
+		return err
+	}
 	return h.gRPCMetric.Register()
 }
 
@@ -66,6 +91,21 @@ func (h *HandlerMonitor) SetGRPCLabelValues(sensorID string, size float64) {
 	h.gRPCMetric.Add(size, sensorID)
 }
 
+// SetHTTPUploadValues 采集HTTP上传请求大小
+func (h *HandlerMonitor) SetHTTPUploadValues(size float64, result string) {
+	h.uploadMetric.Observe(size, result)
+}
+
+// SetFrameOrderValues 采集乱序、迟到帧数, kind: MonitorReordered, MonitorLate
+func (h *HandlerMonitor) SetFrameOrderValues(sensorID, kind string) {
+	h.frameOrderMetric.Inc(sensorID, kind)
+}
+
+// SetDuplicateFrameValues 采集丢弃的重复帧数
+func (h *HandlerMonitor) SetDuplicateFrameValues(sensorID string) {
+	h.duplicateMetric.Inc(sensorID)
+}
+
 // SetCacheReadValues api获取缓存统计
 func (h *HandlerMonitor) SetCacheReadValues(sensorID, result string) {
 	h.cacheMetric.Inc(sensorID, result)
@@ -6,6 +6,24 @@ type GRPCMetric interface {
 	Register() error                 // 注册
 }
 
+// HTTPUploadMetric HTTP上传请求大小度量指标
+type HTTPUploadMetric interface {
+	Observe(val float64, args ...string) // 记录一次请求
+	Register() error                     // 注册
+}
+
+// FrameOrderMetric 乱序帧度量指标
+type FrameOrderMetric interface {
+	Inc(args ...string) // 自增1
+	Register() error    // 注册
+}
+
+// DuplicateFrameMetric 重复帧度量指标
+type DuplicateFrameMetric interface {
+	Inc(args ...string) // 自增1
+	Register() error    // 注册
+}
+
 // CacheReadMetric 读取缓存度量指标
 type CacheReadMetric interface {
 	Inc(args ...string) // 自增读取缓存
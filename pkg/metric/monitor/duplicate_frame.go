@@ -0,0 +1,37 @@
+package monitor
+
+import (
+	"github.com/kiga-hub/arc-storage/pkg/metric"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DuplicateFrame 丢弃的重复帧数
+type DuplicateFrame struct {
+	counterVec *prometheus.CounterVec
+}
+
+// NewDuplicateFrame .
+func NewDuplicateFrame() metric.DuplicateFrameMetric {
+	return &DuplicateFrame{
+		counterVec: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metric.MonitorNamespace,
+			Subsystem: metric.MonitorSubsystem,
+			Name:      metric.MonitorDuplicateFrames,
+			Help:      "record dropped duplicate frames",
+		}, []string{"sensorID"}),
+	}
+}
+
+// Inc .
+func (d *DuplicateFrame) Inc(args ...string) {
+	d.counterVec.WithLabelValues(args...).Inc()
+}
+
+// Register .
+func (d *DuplicateFrame) Register() error {
+	if err := prometheus.Register(d.counterVec); err != nil {
+		return errors.Wrap(err, "注册重复帧监控")
+	}
+	return nil
+}
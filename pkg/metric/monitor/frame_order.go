@@ -0,0 +1,37 @@
+package monitor
+
+import (
+	"github.com/kiga-hub/arc-storage/pkg/metric"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// FrameOrder 乱序和迟到帧数
+type FrameOrder struct {
+	counterVec *prometheus.CounterVec
+}
+
+// NewFrameOrder .
+func NewFrameOrder() metric.FrameOrderMetric {
+	return &FrameOrder{
+		counterVec: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metric.MonitorNamespace,
+			Subsystem: metric.MonitorSubsystem,
+			Name:      metric.MonitorFrameOrder,
+			Help:      "record reordered and late frames",
+		}, []string{"sensorID", "kind"}),
+	}
+}
+
+// Inc .
+func (f *FrameOrder) Inc(args ...string) {
+	f.counterVec.WithLabelValues(args...).Inc()
+}
+
+// Register .
+func (f *FrameOrder) Register() error {
+	if err := prometheus.Register(f.counterVec); err != nil {
+		return errors.Wrap(err, "注册乱序帧监控")
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package monitor
+
+import (
+	"github.com/kiga-hub/arc-storage/pkg/metric"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPUpload HTTP上传请求大小
+type HTTPUpload struct {
+	histogramVec *prometheus.HistogramVec
+}
+
+// NewHTTPUpload .
+func NewHTTPUpload() metric.HTTPUploadMetric {
+	return &HTTPUpload{
+		histogramVec: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metric.MonitorNamespace,
+			Subsystem: metric.MonitorSubsystem,
+			Name:      metric.MonitorHTTPUploadBytes,
+			Help:      "record body size of http frame uploads",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+		}, []string{"result"}),
+	}
+}
+
+// Observe .
+func (h *HTTPUpload) Observe(val float64, args ...string) {
+	h.histogramVec.WithLabelValues(args...).Observe(val)
+}
+
+// Register .
+func (h *HTTPUpload) Register() error {
+	if err := prometheus.Register(h.histogramVec); err != nil {
+		return errors.Wrap(err, "注册HTTP上传监控")
+	}
+	return nil
+}
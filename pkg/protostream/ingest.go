@@ -0,0 +1,152 @@
+package protostream
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/protostream/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ackBufferSize 每个流待发送的确认数, 超出时断开流
+const ackBufferSize = 256
+
+// Submitter 提交数据到解码队列
+type Submitter interface {
+	// Submit 队列已满时立即返回false, 接收后在解码并进入处理队列时回调ack, ack不能阻塞
+	Submit(key, value []byte, ack func(frames, rejected int)) bool
+	// Credit 传感器所在解码队列的剩余容量
+	Credit(key []byte) int
+}
+
+// Ingest 带确认和流控的数据接入, 每个批次返回一个确认
+type Ingest struct {
+	pb.UnimplementedFrameIngestServer
+	Submitter  Submitter
+	RetryAfter time.Duration // 队列已满时建议的重发间隔
+}
+
+// Ingest -
+func (t *Ingest) Ingest(stream pb.FrameIngest_IngestServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	acks := make(chan *pb.IngestAck, ackBufferSize)
+	sendErr := make(chan error, 1)
+
+	// grpc stream 不支持并发Send, 确认统一由发送协程返回
+	go func() {
+		for {
+			select {
+			case ack, ok := <-acks:
+				if !ok {
+					sendErr <- nil
+					return
+				}
+				if err := stream.Send(ack); err != nil {
+					sendErr <- err
+					cancel()
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// 确认在解码协程中回调, 不能阻塞. 客户端接收过慢导致确认堆积时断开流
+	var overflow int32
+	reply := func(ack *pb.IngestAck) {
+		select {
+		case acks <- ack:
+		default:
+			atomic.StoreInt32(&overflow, 1)
+			cancel()
+		}
+	}
+
+	var inflight sync.WaitGroup
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- t.receive(stream, reply, &inflight)
+	}()
+
+	var err error
+	select {
+	case err = <-recvErr:
+		// 发送端关闭后等待已接收批次的确认
+		inflight.Wait()
+		close(acks)
+		select {
+		case serr := <-sendErr:
+			if serr != nil {
+				return serr
+			}
+		case <-ctx.Done():
+		}
+	case <-ctx.Done():
+	}
+
+	if atomic.LoadInt32(&overflow) == 1 {
+		return status.Error(codes.ResourceExhausted, "ack buffer overflow")
+	}
+	select {
+	case serr := <-sendErr:
+		if serr != nil {
+			return serr
+		}
+	default:
+	}
+	if ctx.Err() != nil && err == nil {
+		return ctx.Err()
+	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// receive 接收批次并提交到解码队列, 返回接收错误. 发送端关闭时返回io.EOF
+func (t *Ingest) receive(stream pb.FrameIngest_IngestServer, reply func(*pb.IngestAck), inflight *sync.WaitGroup) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		if len(req.Key) < 6 || len(req.Value) < 1 {
+			reply(&pb.IngestAck{
+				Seq:     req.Seq,
+				Status:  pb.IngestAck_INVALID,
+				Credit:  uint32(t.Submitter.Credit(req.Key)),
+				Message: "key and value required",
+			})
+			continue
+		}
+
+		seq, key := req.Seq, req.Key
+		inflight.Add(1)
+		ok := t.Submitter.Submit(req.Key, req.Value, func(frames, rejected int) {
+			defer inflight.Done()
+			reply(&pb.IngestAck{
+				Seq:      seq,
+				Status:   pb.IngestAck_OK,
+				Frames:   uint32(frames),
+				Rejected: uint32(rejected),
+				Credit:   uint32(t.Submitter.Credit(key)),
+			})
+		})
+		if !ok {
+			inflight.Done()
+			reply(&pb.IngestAck{
+				Seq:          seq,
+				Status:       pb.IngestAck_BUSY,
+				RetryAfterMs: uint32(t.RetryAfter.Milliseconds()),
+				Credit:       uint32(t.Submitter.Credit(key)),
+			})
+		}
+	}
+}
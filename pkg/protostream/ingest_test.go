@@ -0,0 +1,134 @@
+package protostream
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/protostream/pb"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeSubmitter 接收前n个批次, 之后返回队列已满
+type fakeSubmitter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func (s *fakeSubmitter) Submit(key, value []byte, ack func(frames, rejected int)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.n <= 0 {
+		return false
+	}
+	s.n--
+	go ack(len(value), 0)
+	return true
+}
+
+// syncSubmitter 接收全部批次, 在Submit中直接回调ack
+type syncSubmitter struct{}
+
+func (syncSubmitter) Submit(key, value []byte, ack func(frames, rejected int)) bool {
+	ack(len(value), 0)
+	return true
+}
+
+func (syncSubmitter) Credit(key []byte) int {
+	return 1
+}
+
+// stalledStream 客户端发送n个批次后不再发送, 也不接收确认
+type stalledStream struct {
+	grpc.ServerStream
+	ctx context.Context
+	n   int
+}
+
+func (s *stalledStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stalledStream) Send(*pb.IngestAck) error {
+	<-s.ctx.Done()
+	return s.ctx.Err()
+}
+
+func (s *stalledStream) Recv() (*pb.IngestRequest, error) {
+	if s.n <= 0 {
+		<-s.ctx.Done()
+		return nil, s.ctx.Err()
+	}
+	s.n--
+	return &pb.IngestRequest{Seq: uint64(s.n), Key: []byte{0xA0, 0, 0, 0, 0, 1}, Value: []byte{1}}, nil
+}
+
+func (s *fakeSubmitter) Credit(key []byte) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
+
+func TestIngest(t *testing.T) {
+	CaseIngest(t)
+	CaseIngestAckOverflow(t)
+}
+
+func CaseIngest(t *testing.T) {
+	Convey("Ingest", t, func() {
+		lis := bufconn.Listen(1 << 20)
+		server := grpc.NewServer()
+		pb.RegisterFrameIngestServer(server, &Ingest{Submitter: &fakeSubmitter{n: 2}, RetryAfter: 50 * time.Millisecond})
+		go server.Serve(lis)
+		defer server.Stop()
+
+		conn, err := grpc.Dial("bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+			grpc.WithTransportCredentials(insecure.NewCredentials()))
+		So(err, ShouldBeNil)
+		defer conn.Close()
+
+		stream, err := pb.NewFrameIngestClient(conn).Ingest(context.Background())
+		So(err, ShouldBeNil)
+
+		key := []byte{0xA0, 0, 0, 0, 0, 1}
+		So(stream.Send(&pb.IngestRequest{Seq: 1, Key: key, Value: []byte{1}}), ShouldBeNil)
+		So(stream.Send(&pb.IngestRequest{Seq: 2, Key: key, Value: []byte{1, 2}}), ShouldBeNil)
+		So(stream.Send(&pb.IngestRequest{Seq: 3, Key: key, Value: []byte{1}}), ShouldBeNil)
+		So(stream.Send(&pb.IngestRequest{Seq: 4, Value: []byte{1}}), ShouldBeNil)
+		So(stream.CloseSend(), ShouldBeNil)
+
+		acks := map[uint64]*pb.IngestAck{}
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				break
+			}
+			acks[ack.Seq] = ack
+		}
+		So(len(acks), ShouldEqual, 4)
+		So(acks[1].Status, ShouldEqual, pb.IngestAck_OK)
+		So(acks[2].Frames, ShouldEqual, 2)
+		So(acks[3].Status, ShouldEqual, pb.IngestAck_BUSY)
+		So(acks[3].RetryAfterMs, ShouldEqual, 50)
+		So(acks[4].Status, ShouldEqual, pb.IngestAck_INVALID)
+	})
+}
+
+func CaseIngestAckOverflow(t *testing.T) {
+	Convey("IngestAckOverflow", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// acks never block the submitter, the stream is closed instead
+		err := (&Ingest{Submitter: syncSubmitter{}}).Ingest(&stalledStream{ctx: ctx, n: ackBufferSize + 10})
+		So(status.Code(err), ShouldEqual, codes.ResourceExhausted)
+	})
+}
@@ -0,0 +1,141 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: ingest.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	FrameIngest_Ingest_FullMethodName = "/ingest.FrameIngest/Ingest"
+)
+
+// FrameIngestClient is the client API for FrameIngest service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FrameIngestClient interface {
+	Ingest(ctx context.Context, opts ...grpc.CallOption) (FrameIngest_IngestClient, error)
+}
+
+type frameIngestClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFrameIngestClient(cc grpc.ClientConnInterface) FrameIngestClient {
+	return &frameIngestClient{cc}
+}
+
+func (c *frameIngestClient) Ingest(ctx context.Context, opts ...grpc.CallOption) (FrameIngest_IngestClient, error) {
+	stream, err := c.cc.NewStream(ctx, &FrameIngest_ServiceDesc.Streams[0], FrameIngest_Ingest_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &frameIngestIngestClient{stream}
+	return x, nil
+}
+
+type FrameIngest_IngestClient interface {
+	Send(*IngestRequest) error
+	Recv() (*IngestAck, error)
+	grpc.ClientStream
+}
+
+type frameIngestIngestClient struct {
+	grpc.ClientStream
+}
+
+func (x *frameIngestIngestClient) Send(m *IngestRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *frameIngestIngestClient) Recv() (*IngestAck, error) {
+	m := new(IngestAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FrameIngestServer is the server API for FrameIngest service.
+// All implementations must embed UnimplementedFrameIngestServer
+// for forward compatibility
+type FrameIngestServer interface {
+	Ingest(FrameIngest_IngestServer) error
+	mustEmbedUnimplementedFrameIngestServer()
+}
+
+// UnimplementedFrameIngestServer must be embedded to have forward compatible implementations.
+type UnimplementedFrameIngestServer struct {
+}
+
+func (UnimplementedFrameIngestServer) Ingest(FrameIngest_IngestServer) error {
+	return status.Errorf(codes.Unimplemented, "method Ingest not implemented")
+}
+func (UnimplementedFrameIngestServer) mustEmbedUnimplementedFrameIngestServer() {}
+
+// UnsafeFrameIngestServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FrameIngestServer will
+// result in compilation errors.
+type UnsafeFrameIngestServer interface {
+	mustEmbedUnimplementedFrameIngestServer()
+}
+
+func RegisterFrameIngestServer(s grpc.ServiceRegistrar, srv FrameIngestServer) {
+	s.RegisterService(&FrameIngest_ServiceDesc, srv)
+}
+
+func _FrameIngest_Ingest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FrameIngestServer).Ingest(&frameIngestIngestServer{stream})
+}
+
+type FrameIngest_IngestServer interface {
+	Send(*IngestAck) error
+	Recv() (*IngestRequest, error)
+	grpc.ServerStream
+}
+
+type frameIngestIngestServer struct {
+	grpc.ServerStream
+}
+
+func (x *frameIngestIngestServer) Send(m *IngestAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *frameIngestIngestServer) Recv() (*IngestRequest, error) {
+	m := new(IngestRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FrameIngest_ServiceDesc is the grpc.ServiceDesc for FrameIngest service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FrameIngest_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ingest.FrameIngest",
+	HandlerType: (*FrameIngestServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Ingest",
+			Handler:       _FrameIngest_Ingest_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "ingest.proto",
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+	"github.com/kiga-hub/arc-storage/pkg/metric"
+)
+
+// lateVolumeFlag 迟到帧数据卷在DataCache中的key标记. 传感器ID只占用低48位, 两个数据卷由同一个工作协程处理
+const lateVolumeFlag = uint64(1) << 63
+
+// bufferFrame 数据段按类型写入各自的数据卷. 丢弃重复帧, 帧写入预写日志后进入传感器的乱序窗口, 移出窗口的帧按时间戳追加到数据卷.
+// 早于已追加帧的帧写入迟到帧数据卷. 重复帧返回false, 预写日志写入失败时帧仍进入数据卷并返回错误
+func (arc *ArcStorage) bufferFrame(st arc_volume.SegmentType, key uint64, sensorID string, t time.Time, data []byte) (bool, error) {
+	afi := arc.loadVolume(key, sensorID, st.Name, t, len(data))
+	if afi.Reorder == nil {
+		afi.Reorder = arc_volume.NewReorderBuffer(time.Duration(arc.config.Work.ReorderWindow) * time.Millisecond)
+	}
+	if arc.duplicateFrame(afi, key, t, data) {
+		arc.exportMetrics.SetDuplicateFrameValues(sensorID)
+		return false, nil
+	}
+
+	if afi.Reorder.Late(t) {
+		lateKey := key | lateVolumeFlag
+		lateType := st.LateType().Name
+		seq, err := arc.walAppend(lateKey, sensorID, lateType, t, data)
+		late := arc.loadVolume(lateKey, sensorID, lateType, t, len(data))
+		arc.appendFrame(late, lateKey, t, data, func() uint64 { return seq })
+		arc.timeoutSyncMap.Store(lateKey, time.Now().UTC())
+		arc.exportMetrics.SetFrameOrderValues(sensorID, metric.MonitorLate)
+		return true, err
+	}
+
+	seq, err := arc.walAppend(key, sensorID, st.Name, t, data)
+	if afi.Reorder.Push(arc_volume.ReorderFrame{Timestamp: t, Data: data, WALSeq: seq}) {
+		arc.exportMetrics.SetFrameOrderValues(sensorID, metric.MonitorReordered)
+	}
+	arc.appendFrames(afi, key, afi.Reorder.Release())
+	return true, err
+}
+
+// appendFrames 追加移出乱序窗口的帧. 数据卷切换时, 之后的帧及窗口中的帧所在的预写日志段被保留
+func (arc *ArcStorage) appendFrames(afi *arc_volume.ArcVolume, key uint64, frames []arc_volume.ReorderFrame) {
+	for i, f := range frames {
+		rest := frames[i:]
+		arc.appendFrame(afi, key, f.Timestamp, f.Data, func() uint64 {
+			min := rest[0].WALSeq
+			for _, r := range rest[1:] {
+				if r.WALSeq < min {
+					min = r.WALSeq
+				}
+			}
+			if seq, ok := afi.Reorder.MinWALSeq(); ok && seq < min {
+				min = seq
+			}
+			return min
+		})
+	}
+}
+
+// appendFrame 帧追加到数据卷. 帧跨过时间边界或数据卷已满时, 先将数据卷交给写入队列,
+// keep返回仍有未落盘数据的最小预写日志段
+func (arc *ArcStorage) appendFrame(afi *arc_volume.ArcVolume, key uint64, t time.Time, data []byte, keep func() uint64) {
+	// the volume may have been created by a dropped duplicate, it starts at its first appended frame
+	if len(afi.Index) == 0 {
+		afi.CreateTime = t
+	}
+	if arc.arcFileStore.ShouldRollover(afi, t, len(data)) {
+		if err := arc.arcFileStore.PreWriteToFileCache(afi, t, 0); err != nil {
+			arc.logger.Errorw("storeToArcBigFile", "err", err)
+		} else {
+			// the current frame goes to the new volume
+			arc.walCheckpointAt(key, keep())
+		}
+		arc.logger.Debugw("PreWriteToFileCache", "id", afi.SensorID, "timeStamp", t, "size", afi.Buffer.Len(), "startTime", afi.CreateTime)
+
+		// add idstirng to gossipKVCache
+		if arc.gossipKVCache != nil {
+			arc.sensorIDsChan <- []string{afi.SensorID}
+		}
+
+		afi.Update(t)
+		afi.SaveTime = t.UTC()
+	}
+
+	if t.After(afi.SaveTime) {
+		afi.SaveTime = t.UTC()
+	}
+	if t.After(afi.LastTimestamp) {
+		afi.LastTimestamp = t
+	}
+	afi.Append(t, data)
+}
+
+// loadVolume 传感器的内存数据卷, 不存在时创建
+func (arc *ArcStorage) loadVolume(key uint64, sensorID, fileType string, t time.Time, size int) *arc_volume.ArcVolume {
+	if a, ok := arc.arcFileStore.DataCache.Load(key); ok {
+		return a.(*arc_volume.ArcVolume)
+	}
+	buffer := bytes.NewBuffer([]byte{})
+	buffer.Grow(size * 2)
+	afi := &arc_volume.ArcVolume{
+		Dir:        arc.config.Work.DataPath,
+		CreateTime: t,
+		SensorID:   sensorID,
+		Buffer:     buffer,
+		Type:       fileType,
+	}
+	arc.arcFileStore.DataCache.Store(key, afi)
+	return afi
+}
@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+	"github.com/kiga-hub/arc/protocols"
+)
+
+// segmentKeyShift 数据段类型码在DataCache key中的位置. 传感器ID占用低48位, 同一传感器的各类型数据卷由同一个工作协程处理
+const segmentKeyShift = 48
+
+// segmentKey 传感器某类数据段的数据卷在DataCache中的key
+func segmentKey(id uint64, st arc_volume.SegmentType) uint64 {
+	return id | uint64(st.SType)<<segmentKeyShift
+}
+
+// arcFrame 只包含Arc数据段的帧, 供kafka等只处理Arc数据的模块使用
+func arcFrame(f *decoder.Frame) *protocols.Frame {
+	frame := protocols.NewDefaultFrame()
+	frame.ID = f.ID
+	frame.Timestamp = f.Timestamp.UnixMicro()
+	for _, s := range f.Segments {
+		if s.SType == protocols.STypeArc {
+			frame.DataGroup.AppendSegment(&protocols.SegmentArc{SType: s.SType, Data: s.Data})
+		}
+	}
+	return frame
+}
@@ -0,0 +1,193 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+	"github.com/kiga-hub/arc-storage/pkg/protostream"
+)
+
+// DrainReport 停机排空结果
+type DrainReport struct {
+	Flushed     int           `json:"flushed"`     // 落盘的数据卷数
+	Frames      int           `json:"frames"`      // 落盘的帧数
+	Bytes       int64         `json:"bytes"`       // 落盘的字节数
+	Failed      []string      `json:"failed"`      // 落盘失败的数据卷, 传感器ID/数据类型
+	Pending     []string      `json:"pending"`     // 期限内未处理的数据卷, 传感器ID/数据类型
+	Recoverable bool          `json:"recoverable"` // 未落盘的数据保留在预写日志中, 重启后回放
+	Elapsed     time.Duration `json:"elapsed"`
+}
+
+// Lost 未落盘的数据卷数
+func (r *DrainReport) Lost() int {
+	return len(r.Failed) + len(r.Pending)
+}
+
+// drainResult 一个工作协程的落盘结果
+type drainResult struct {
+	workindex int
+	flushed   int
+	frames    int
+	bytes     int64
+	failed    []string
+}
+
+// Close 停止接入, 排空解码队列并将全部数据卷落盘, 最长等待arc.shutdownTimeout
+func (arc *ArcStorage) Close() {
+	arc.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(arc.config.Work.ShutdownTimeout)*time.Second)
+		defer cancel()
+
+		r := arc.shutdown(ctx)
+		if r.Lost() > 0 {
+			arc.logger.Errorw("shutdown", "flushed", r.Flushed, "frames", r.Frames, "bytes", r.Bytes,
+				"failed", r.Failed, "pending", r.Pending, "recoverable", r.Recoverable, "elapsed", r.Elapsed)
+			return
+		}
+		arc.logger.Infow("shutdown", "flushed", r.Flushed, "frames", r.Frames, "bytes", r.Bytes, "elapsed", r.Elapsed)
+	})
+}
+
+// shutdown 按接入、解码、落盘的顺序停止. 每个工作协程处理完队列中的数据后落盘自己的数据卷,
+// 期限内未完成的工作协程的数据卷计入Pending
+func (arc *ArcStorage) shutdown(ctx context.Context) *DrainReport {
+	start := time.Now()
+	report := &DrainReport{Recoverable: len(arc.wals) > 0}
+
+	// stop accepting, in-flight uploads finish before the barrier
+	arc.gate.Lock()
+	arc.serviceIsClosing = true
+	close(arc.closing)
+	arc.gate.Unlock()
+
+	if arc.consumer != nil {
+		arc.consumer.Stop()
+	}
+	if arc.config.Grpc.Enable {
+		arc.stopGRPC(ctx)
+	}
+	if !waitContext(ctx, arc.inflight.Wait) {
+		arc.logger.Warnw("shutdown", "msg", "uploads still in flight")
+	}
+
+	// a barrier behind the queued data of every worker
+	drain := make(chan drainResult, len(arc.decodeJobChans))
+	sent := map[int]bool{}
+	for i, ch := range arc.decodeJobChans {
+		select {
+		case ch <- decodeJob{drain: drain}:
+			sent[i] = true
+		case <-ctx.Done():
+		}
+	}
+	done := map[int]bool{}
+wait:
+	for len(done) < len(sent) {
+		select {
+		case r := <-drain:
+			done[r.workindex] = true
+			report.Flushed += r.flushed
+			report.Frames += r.frames
+			report.Bytes += r.bytes
+			report.Failed = append(report.Failed, r.failed...)
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	mask := uint64(arc.config.Work.WorkCount - 1)
+	arc.arcFileStore.DataCache.Range(func(key, value interface{}) bool {
+		if !done[int(key.(uint64)&mask)] {
+			report.Pending = append(report.Pending, volumeName(value.(*arc_volume.ArcVolume)))
+		}
+		return true
+	})
+
+	// offsets of the flushed frames
+	if arc.consumer != nil {
+		arc.consumer.Close()
+	}
+	// workers still running may write to the queue
+	if len(report.Pending) == 0 && len(done) == len(arc.decodeJobChans) {
+		arc.arcFileStore.SafeClose()
+	}
+	arc.closeWAL()
+	if arc.arcCache != nil {
+		arc.arcCache.Close()
+	}
+
+	report.Elapsed = time.Since(start)
+	return report
+}
+
+// stopGRPC 等待进行中的流结束, 超过期限后强制关闭. 已接收的消息转发到解码队列后返回
+func (arc *ArcStorage) stopGRPC(ctx context.Context) {
+	if arc.grpcserver != nil {
+		if !waitContext(ctx, arc.grpcserver.GracefulStop) {
+			arc.grpcserver.Stop()
+		}
+	}
+
+	select {
+	case arc.grpcmessage <- protostream.ProtoStream{IsStop: true}:
+		waitContext(ctx, func() { <-arc.grpcStreamDone })
+	case <-ctx.Done():
+	}
+}
+
+// flushShard 落盘工作协程的全部数据卷, 只在处理协程中调用
+func (arc *ArcStorage) flushShard(workindex int) drainResult {
+	r := drainResult{workindex: workindex}
+	mask := uint64(arc.config.Work.WorkCount - 1)
+	arc.arcFileStore.DataCache.Range(func(key, value interface{}) bool {
+		id := key.(uint64)
+		if int(id&mask) != workindex {
+			return true
+		}
+		frames, size, err := arc.loadAndStoreTimeOutData(id)
+		if err != nil {
+			r.failed = append(r.failed, volumeName(value.(*arc_volume.ArcVolume)))
+			return true
+		}
+		if frames > 0 {
+			r.flushed++
+			r.frames += frames
+			r.bytes += int64(size)
+		}
+		return true
+	})
+	return r
+}
+
+// volumeName 传感器ID/数据类型
+func volumeName(afi *arc_volume.ArcVolume) string {
+	return fmt.Sprintf("%s/%s", afi.SensorID, afi.Type)
+}
+
+// waitContext 等待fn返回, ctx结束时返回false, fn继续在后台运行
+func waitContext(ctx context.Context, fn func()) bool {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// acquire HTTP接入开始, 停机开始后返回false. 成功时需调用arc.inflight.Done
+func (arc *ArcStorage) acquire() bool {
+	arc.gate.RLock()
+	defer arc.gate.RUnlock()
+	if arc.serviceIsClosing {
+		return false
+	}
+	arc.inflight.Add(1)
+	return true
+}
@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestShutdown(t *testing.T) {
+	CaseShutdown(t)
+}
+
+func CaseShutdown(t *testing.T) {
+	Convey("shutdown", t, func() {
+		arc, err := newTestArcStorage(t.TempDir(), "shutdown")
+		So(err, ShouldBeNil)
+		// frames wait in the reorder window until the drain
+		arc.config.Work.ReorderWindow = 60000
+		// 配置中缺少[wal]
+		arc.config.WAL = nil
+
+		start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+		for _, f := range [][]byte{
+			testArcFrame(1, start, []byte{1}),
+			testArcFrame(1, start.Add(time.Second), []byte{2}),
+			testArcFrame(2, start, []byte{3}),
+		} {
+			id, _ := decoder.Default.SensorID(f)
+			arc.decodeJobChans[ByteToUInt64(id)&uint64(arc.config.Work.WorkCount-1)] <- arc.decodeJob(sourceHTTP, f)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		r := arc.shutdown(ctx)
+		So(r.Flushed, ShouldEqual, 2)
+		So(r.Frames, ShouldEqual, 3)
+		So(r.Failed, ShouldBeEmpty)
+		So(r.Pending, ShouldBeEmpty)
+		So(r.Lost(), ShouldEqual, 0)
+		So(r.Recoverable, ShouldBeFalse)
+		So(arc.acquire(), ShouldBeFalse)
+
+		var got [][]byte
+		_, err = arc.arcFileStore.ReadFrames(context.Background(), "A00000000001", arc_volume.SegmentTypeArc.Name, start, start.Add(time.Minute), func(t time.Time, data []byte) error {
+			got = append(got, data)
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(got, ShouldResemble, [][]byte{{1}, {2}})
+	})
+}
@@ -1,13 +1,19 @@
 package pkg
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
 	"github.com/kiga-hub/arc-storage/pkg/util"
+	"github.com/kiga-hub/arc-storage/pkg/wav"
 	"github.com/kiga-hub/arc/utils"
 
 	"github.com/labstack/echo/v4"
@@ -55,9 +61,40 @@ func (arc *ArcStorage) getSensorIDs(c echo.Context) error {
 	)
 }
 
-// getSensorLists metadata from needle & parse data to buffer
+// getSegmentTypes 已注册的数据段类型
+func (arc *ArcStorage) getSegmentTypes(c echo.Context) error {
+	return c.JSON(http.StatusOK, utils.ResponseV2{
+		Code: Success,
+		Msg:  "OK",
+		Data: arc_volume.SegmentTypes()},
+	)
+}
+
+// getCompactPlan dry run of the volume compaction with the compact config
+func (arc *ArcStorage) getCompactPlan(c echo.Context) error {
+	return c.JSON(http.StatusOK, utils.ResponseV2{
+		Code: Success,
+		Msg:  "OK",
+		Data: arc.arcFileStore.CompactPlan()},
+	)
+}
+
+// getRetentionPlan preview of the data removed by the retention policy
+func (arc *ArcStorage) getRetentionPlan(c echo.Context) error {
+	return c.JSON(http.StatusOK, utils.ResponseV2{
+		Code: Success,
+		Msg:  "OK",
+		Data: arc.arcFileStore.RetentionPlan()},
+	)
+}
+
+// getSensorLists metadata from needle & parse data to buffer.
+// aggregation query when function is set.
 func (arc *ArcStorage) getSensorLists(c echo.Context) error {
-	var err error
+	if c.QueryParam("function") != "" {
+		return arc.getSensorAggregate(c)
+	}
+
 	sensorIDStr := c.QueryParam("sensorid")
 	if sensorIDStr == "" {
 		arc.logger.Errorw("sensorid is null", "sensorid", sensorIDStr)
@@ -70,68 +107,20 @@ func (arc *ArcStorage) getSensorLists(c echo.Context) error {
 	sensorid := strings.ToUpper(sensorIDStr)
 	filetype := c.QueryParam("type")
 
-	var AllowExtMap map[string]bool = map[string]bool{
-		"Arc": true,
-	}
-	if _, ok := AllowExtMap[filetype]; !ok {
+	st, ok := arc_volume.SegmentTypeByName(filetype)
+	if !ok {
 		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
 			Code: http.StatusBadRequest,
 			Msg:  http.StatusText(http.StatusBadRequest)},
 		)
 	}
 
-	var t1 time.Time
-	var t2 time.Time
-
-	from := c.QueryParam("from")
-	to := c.QueryParam("to")
-	// if a timestamp is passed. it is cosidered as UTC time.
-	if util.IsDigit(from) && util.IsDigit(to) {
-		if len(from) == 10 && len(to) == 10 {
-			t1 = time.Unix(cast.ToInt64(from), 0)
-			t2 = time.Unix(cast.ToInt64(to), 0)
-		} else if len(from) == 13 && len(to) == 13 {
-			t1 = time.Unix(0, cast.ToInt64(from)*1e6)
-			t2 = time.Unix(0, cast.ToInt64(to)*1e6)
-		} else if len(from) == 16 && len(to) == 16 {
-			t1 = time.Unix(0, cast.ToInt64(from)*1e3)
-			t2 = time.Unix(0, cast.ToInt64(to)*1e3)
-		} else if len(from) == 19 && len(to) == 19 {
-			t1 = time.Unix(0, cast.ToInt64(from))
-			t2 = time.Unix(0, cast.ToInt64(to))
-		} else {
-			return c.JSON(http.StatusBadRequest, utils.ResponseV2{
-				Code: http.StatusBadRequest,
-				Msg:  http.StatusText(http.StatusBadRequest)},
-			)
-		}
-		// if it's not a timestamp. it's considered a string. if no custom timezone is defined. the default is UTC+8.
-	} else {
-		from = strings.ReplaceAll(from, " ", "T")
-		to = strings.ReplaceAll(to, " ", "T")
-
-		//长度不够20即认为没有带时区信息
-		if len(from) < 20 {
-			from += "+08:00"
-		}
-		if len(to) < 20 {
-			to += "+08:00"
-		}
-
-		t1, err = time.Parse(time.RFC3339, from)
-		if err != nil || t1.Unix() < 0 {
-			return c.JSON(http.StatusBadRequest, utils.ResponseV2{
-				Code: http.StatusBadRequest,
-				Msg:  http.StatusText(http.StatusBadRequest)},
-			)
-		}
-		t2, err = time.Parse(time.RFC3339, to)
-		if err != nil || t2.Unix() < 0 {
-			return c.JSON(http.StatusBadRequest, utils.ResponseV2{
-				Code: http.StatusBadRequest,
-				Msg:  http.StatusText(http.StatusBadRequest)},
-			)
-		}
+	t1, t2, err := parseQueryTimeRange(c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest)},
+		)
 	}
 
 	if t2.Before(t1) || t2.Equal(t1) {
@@ -159,8 +148,8 @@ func (arc *ArcStorage) getSensorLists(c echo.Context) error {
 	bigfilelists := make(map[string]string, count)
 
 	for _, day := range daysdiffer {
-		path := arc.config.Work.DataPath + "/" + sensorid + "/" + day + "/" + filetype
-		err := util.GetBigFileLists(path, bigfilelists, "")
+		path := arc.config.Work.DataPath + "/" + sensorid + "/" + day + "/" + st.Dir
+		err := util.GetBigFileLists(path, bigfilelists, st.Ext)
 		if err != nil {
 			arc.logger.Errorw("GetBigFileLists", "dataPath", path, "err", err)
 			// traverse the folder. skip if it doest not exist.
@@ -178,7 +167,7 @@ func (arc *ArcStorage) getSensorLists(c echo.Context) error {
 	sort.Strings(timestamplist)
 
 	for _, creattime := range timestamplist {
-		begin, end, _,  err := util.GetTimeRangeFromFileName(bigfilelists[creattime])
+		begin, end, _, err := util.GetTimeRangeFromFileName(bigfilelists[creattime])
 		if err != nil {
 			arc.logger.Errorw("GetTimeRangeFromFileName", "fileName", bigfilelists[creattime], "err", err)
 			return c.JSON(http.StatusNotFound, utils.ResponseV2{
@@ -222,6 +211,7 @@ func (arc *ArcStorage) getSensorLists(c echo.Context) error {
 		}
 
 		duration := end.Sub(start).Microseconds()
+		query := "sensorid=" + sensorid + "&type=" + filetype + "&from=" + cast.ToString(start.UnixNano()/1e3) + "&to=" + cast.ToString(end.UnixNano()/1e3)
 
 		item := SensorItem{
 			SensorID:     sensorid,
@@ -230,18 +220,21 @@ func (arc *ArcStorage) getSensorLists(c echo.Context) error {
 			TimeTo:       end.UnixNano() / 1e3,
 			TimeDuration: duration,
 			Query: SensorQuery{
-				URL:      "http://arc-storage/api/data/v1/history/arc?sensorid=" + sensorid + "&type=" + filetype + "&from=" + cast.ToString(start.UnixNano()/1e3) + "&to=" + cast.ToString(end.UnixNano()/1e3),
+				URL:      "http://arc-storage/" + ArcDataPath + "?" + query,
 				Scheme:   "http",
 				Domain:   "arc-storage",
 				Port:     80,
-				FullPath: "api/data/v1/history/arc?sensorid=" + sensorid + "&type=" + filetype + "&from=" + cast.ToString(start.UnixNano()/1e3) + "&to=" + cast.ToString(end.UnixNano()/1e3),
-				Path:     "api/data/v1/history/arc",
+				FullPath: ArcDataPath + "?" + query,
+				Path:     ArcDataPath,
 				SensorID: sensorid,
 				Type:     filetype,
 				TimeFrom: start.UnixNano() / 1e3,
 				TimeTo:   end.UnixNano() / 1e3,
 			},
 		}
+		if stat, err := arc_volume.StatVolume(bigfilelists[v]); err == nil {
+			item.Size, item.StoredSize, item.Codec = stat.Size, stat.StoredSize, stat.Codec
+		}
 		searchlists = append(searchlists, item)
 	}
 
@@ -251,3 +244,208 @@ func (arc *ArcStorage) getSensorLists(c echo.Context) error {
 		Data: searchlists},
 	)
 }
+
+// getSensorData read raw arc bytes of the time range through the read queue, completed by the real-time cache
+func (arc *ArcStorage) getSensorData(c echo.Context) error {
+	sensorid, filetype, t1, t2, resp := parseDataQuery(c)
+	if resp != nil {
+		return c.JSON(resp.Code, resp)
+	}
+
+	data, resp := arc.readMergedData(sensorid, filetype, t1, t2)
+	if resp != nil {
+		arc.logger.Debugw("readMergedData", "sensorid", sensorid, "t1", t1, "t2", t2, "code", resp.Code, "msg", resp.Msg)
+		return c.JSON(resp.Code, resp)
+	}
+
+	return c.Blob(http.StatusOK, echo.MIMEOctetStream, data)
+}
+
+// getSensorWav export arc data of the time range as a RIFF/WAVE file. format comes from the sensor profile.
+func (arc *ArcStorage) getSensorWav(c echo.Context) error {
+	sensorid, filetype, t1, t2, resp := parseDataQuery(c)
+	if resp != nil {
+		return c.JSON(resp.Code, resp)
+	}
+	if filetype != TypeArc {
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest)},
+		)
+	}
+
+	data, resp := arc.readMergedData(sensorid, filetype, t1, t2)
+	if resp != nil {
+		arc.logger.Debugw("readMergedData", "sensorid", sensorid, "t1", t1, "t2", t2, "code", resp.Code, "msg", resp.Msg)
+		return c.JSON(resp.Code, resp)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := wav.Write(buf, arc.config.Sensor.GetProfile(sensorid), data); err != nil {
+		arc.logger.Errorw("wav.Write", "sensorid", sensorid, "err", err)
+		return c.JSON(http.StatusInternalServerError, utils.ResponseV2{
+			Code: http.StatusInternalServerError,
+			Msg:  err.Error()},
+		)
+	}
+
+	filename := fmt.Sprintf("%s_%s_%s.wav", sensorid, t1.UTC().Format("20060102150405"), t2.UTC().Format("20060102150405"))
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+	return c.Blob(http.StatusOK, MIMEAudioWav, buf.Bytes())
+}
+
+// getSensorStream stream raw arc bytes of the time range with chunked transfer. no time range limit.
+func (arc *ArcStorage) getSensorStream(c echo.Context) error {
+	sensorid, filetype, t1, t2, resp := parseDataQuery(c)
+	if resp != nil {
+		return c.JSON(resp.Code, resp)
+	}
+
+	// volumes are opened in the sensor queue, written to the client outside of it
+	stream, err := arc.arcFileStore.OpenStreamByQueue(sensorid, filetype, t1, t2)
+	if errors.Is(err, context.DeadlineExceeded) {
+		arc.logger.Errorw("OpenStreamByQueue", "sensorid", sensorid, "err", err)
+		return c.JSON(http.StatusGatewayTimeout, utils.ResponseV2{
+			Code: http.StatusGatewayTimeout,
+			Msg:  http.StatusText(http.StatusGatewayTimeout)},
+		)
+	}
+	if err != nil {
+		arc.logger.Errorw("OpenStreamByQueue", "sensorid", sensorid, "err", err)
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest)},
+		)
+	}
+	defer stream.Close()
+
+	// without volumes the whole range may still be in the real-time cache
+	var tail []byte
+	if stream.Len() < 1 {
+		tail = arc.searchCacheTail(sensorid, filetype, t1, t2)
+		if len(tail) < 1 {
+			return c.JSON(http.StatusNotFound, utils.ResponseV2{
+				Code: http.StatusNotFound,
+				Msg:  http.StatusText(http.StatusNotFound)},
+			)
+		}
+	}
+
+	start := time.Now()
+	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	c.Response().WriteHeader(http.StatusOK)
+
+	n, end, err := arc.arcFileStore.StreamData(c.Request().Context(), sensorid, stream, c.Response())
+	if err != nil {
+		// header has been sent, the client sees a truncated body
+		arc.logger.Warnw("StreamData", "sensorid", sensorid, "t1", t1, "t2", t2, "written", n, "err", err)
+		return nil
+	}
+
+	// data not yet persisted is still in the cache
+	if stream.Len() > 0 {
+		from := t1
+		if n > 0 && end.After(t1) {
+			from = end
+		}
+		tail = arc.searchCacheTail(sensorid, filetype, from, t2)
+	}
+	if len(tail) > 0 {
+		if _, err := c.Response().Write(tail); err != nil {
+			arc.logger.Warnw("StreamData", "sensorid", sensorid, "written", n, "err", err)
+			return nil
+		}
+		c.Response().Flush()
+		n += int64(len(tail))
+	}
+	arc.logger.Infow("StreamData", "sensorid", sensorid, "t1", t1, "t2", t2, "written", n, "spend", time.Since(start).String())
+	return nil
+}
+
+// parseDataQuery parse sensorid, type, from & to of data query
+func parseDataQuery(c echo.Context) (sensorid, filetype string, t1, t2 time.Time, resp *utils.ResponseV2) {
+	sensorIDStr := c.QueryParam("sensorid")
+	if sensorIDStr == "" {
+		return "", "", t1, t2, &utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest),
+		}
+	}
+
+	sensorid = strings.ToUpper(sensorIDStr)
+	filetype = c.QueryParam("type")
+	if _, ok := arc_volume.SegmentTypeByName(filetype); !ok {
+		return "", "", t1, t2, &utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest),
+		}
+	}
+
+	t1, t2, err := parseQueryTimeRange(c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return "", "", t1, t2, &utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  http.StatusText(http.StatusBadRequest),
+		}
+	}
+
+	if t2.Before(t1) || t2.Equal(t1) {
+		return "", "", t1, t2, &utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  "time t1 = t2",
+		}
+	}
+
+	return sensorid, filetype, t1, t2, nil
+}
+
+// parseQueryTimeRange parse from & to query params.
+// if a timestamp is passed. it is cosidered as UTC time.
+// if it's not a timestamp. it's considered a string. if no custom timezone is defined. the default is UTC+8.
+func parseQueryTimeRange(from, to string) (t1, t2 time.Time, err error) {
+	if util.IsDigit(from) && util.IsDigit(to) {
+		if len(from) == 10 && len(to) == 10 {
+			t1 = time.Unix(cast.ToInt64(from), 0)
+			t2 = time.Unix(cast.ToInt64(to), 0)
+		} else if len(from) == 13 && len(to) == 13 {
+			t1 = time.Unix(0, cast.ToInt64(from)*1e6)
+			t2 = time.Unix(0, cast.ToInt64(to)*1e6)
+		} else if len(from) == 16 && len(to) == 16 {
+			t1 = time.Unix(0, cast.ToInt64(from)*1e3)
+			t2 = time.Unix(0, cast.ToInt64(to)*1e3)
+		} else if len(from) == 19 && len(to) == 19 {
+			t1 = time.Unix(0, cast.ToInt64(from))
+			t2 = time.Unix(0, cast.ToInt64(to))
+		} else {
+			return t1, t2, fmt.Errorf("invalid timestamp from:%s to:%s", from, to)
+		}
+		return t1, t2, nil
+	}
+
+	from = strings.ReplaceAll(from, " ", "T")
+	to = strings.ReplaceAll(to, " ", "T")
+
+	//长度不够20即认为没有带时区信息
+	if len(from) < 20 {
+		from += "+08:00"
+	}
+	if len(to) < 20 {
+		to += "+08:00"
+	}
+
+	t1, err = time.Parse(time.RFC3339, from)
+	if err != nil {
+		return t1, t2, err
+	}
+	if t1.Unix() < 0 {
+		return t1, t2, fmt.Errorf("invalid time from:%s", from)
+	}
+	t2, err = time.Parse(time.RFC3339, to)
+	if err != nil {
+		return t1, t2, err
+	}
+	if t2.Unix() < 0 {
+		return t1, t2, fmt.Errorf("invalid time to:%s", to)
+	}
+	return t1, t2, nil
+}
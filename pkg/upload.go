@@ -0,0 +1,151 @@
+package pkg
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+	"github.com/kiga-hub/arc-storage/pkg/metric"
+	"github.com/kiga-hub/arc/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// postSensorFrames 批量上传协议帧. 请求体为连续的协议帧, 或multipart的多个批次, 帧格式由decoder.http配置.
+// 帧按传感器ID分配到解码队列, 与gRPC数据的处理流程相同, 解码并进入处理队列后返回.
+func (arc *ArcStorage) postSensorFrames(c echo.Context) error {
+	if !arc.acquire() {
+		return c.JSON(http.StatusServiceUnavailable, utils.ResponseV2{
+			Code: http.StatusServiceUnavailable,
+			Msg:  http.StatusText(http.StatusServiceUnavailable)},
+		)
+	}
+	defer arc.inflight.Done()
+
+	batches, size, err := readUploadBatches(c.Request())
+	if err != nil {
+		arc.exportMetrics.SetHTTPUploadValues(float64(size), metric.MonitorFailed)
+		// body limit middleware
+		var he *echo.HTTPError
+		if errors.As(err, &he) {
+			return he
+		}
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  err.Error()},
+		)
+	}
+	if size == 0 {
+		arc.exportMetrics.SetHTTPUploadValues(0, metric.MonitorFailed)
+		return c.JSON(http.StatusBadRequest, utils.ResponseV2{
+			Code: http.StatusBadRequest,
+			Msg:  "empty body"},
+		)
+	}
+
+	result := &UploadResult{Bytes: size, Reasons: map[string]int{}}
+	dec := arc.decoderOf(sourceHTTP)
+	mask := uint64(arc.config.Work.WorkCount - 1)
+	jobs := map[uint64][]byte{}
+	for _, batch := range batches {
+		for index := 0; index < len(batch); {
+			n, reason, detail := dec.Split(batch[index:])
+			if reason == "" {
+				var shard uint64
+				if id, ok := dec.SensorID(batch[index : index+n]); ok {
+					shard = ByteToUInt64(id) & mask
+				}
+				jobs[shard] = append(jobs[shard], batch[index:index+n]...)
+				index += n
+				continue
+			}
+			next := dec.Resync(batch, index+1)
+			arc.rejectFrame(dec, sourceHTTP, batch[index:next], index, reason, detail)
+			result.Rejected++
+			result.Reasons[reason]++
+			index = next
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		cancelled bool
+	)
+	ctx := c.Request().Context()
+	for shard, data := range jobs {
+		wg.Add(1)
+		job := arc.decodeJob(sourceHTTP, data)
+		job.ack = func(frames, rejected int) {
+			defer wg.Done()
+			mu.Lock()
+			defer mu.Unlock()
+			result.Accepted += frames
+			result.Rejected += rejected
+			if rejected > 0 {
+				result.Reasons[decoder.RejectDecode] += rejected
+			}
+		}
+		select {
+		case arc.decodeJobChans[shard] <- job:
+		case <-ctx.Done():
+			wg.Done()
+			cancelled = true
+		}
+		if cancelled {
+			break
+		}
+	}
+	wg.Wait()
+
+	if cancelled {
+		arc.exportMetrics.SetHTTPUploadValues(float64(size), metric.MonitorFailed)
+		return c.JSON(http.StatusServiceUnavailable, utils.ResponseV2{
+			Code: http.StatusServiceUnavailable,
+			Msg:  http.StatusText(http.StatusServiceUnavailable),
+			Data: result},
+		)
+	}
+
+	arc.exportMetrics.SetHTTPUploadValues(float64(size), metric.MonitorSuccess)
+	arc.logger.Debugw("postSensorFrames", "bytes", size, "accepted", result.Accepted, "rejected", result.Rejected)
+	return c.JSON(http.StatusOK, utils.ResponseV2{
+		Code: Success,
+		Msg:  "OK",
+		Data: result},
+	)
+}
+
+// readUploadBatches 读取请求体, multipart请求的每个part作为一个批次
+func readUploadBatches(r *http.Request) ([][]byte, int, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(echo.HeaderContentType))
+	if mediaType != echo.MIMEMultipartForm {
+		data, err := io.ReadAll(r.Body)
+		return [][]byte{data}, len(data), err
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, 0, err
+	}
+	var batches [][]byte
+	size := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return batches, size, nil
+		}
+		if err != nil {
+			return nil, size, err
+		}
+		data, err := io.ReadAll(part)
+		part.Close()
+		size += len(data)
+		if err != nil {
+			return nil, size, err
+		}
+		batches = append(batches, data)
+	}
+}
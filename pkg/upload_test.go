@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/decoder"
+	"github.com/labstack/echo/v4"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUpload(t *testing.T) {
+	CasePostSensorFrames(t)
+}
+
+// uploadResponse -
+type uploadResponse struct {
+	Code int          `json:"code"`
+	Data UploadResult `json:"data"`
+}
+
+// postFrames 通过echo调用postSensorFrames
+func postFrames(ctx context.Context, arc *ArcStorage, contentType string, body []byte) (int, uploadResponse) {
+	req := httptest.NewRequest(http.MethodPost, "/arc/frames", bytes.NewReader(body)).WithContext(ctx)
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	if err := arc.postSensorFrames(echo.New().NewContext(req, rec)); err != nil {
+		return http.StatusInternalServerError, uploadResponse{}
+	}
+	var resp uploadResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func CasePostSensorFrames(t *testing.T) {
+	arc, err := newTestArcStorage(t.TempDir(), "upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	corrupt := testArcFrame(1, start.Add(time.Second), []byte{9})
+	corrupt[len(corrupt)-2]++
+
+	Convey("postSensorFrames", t, func() {
+		// the sensors go to different decode queues
+		var body []byte
+		body = append(body, testArcFrame(1, start, []byte{1, 2})...)
+		body = append(body, corrupt...)
+		body = append(body, testArcFrame(2, start, []byte{3})...)
+
+		code, resp := postFrames(context.Background(), arc, echo.MIMEOctetStream, body)
+		So(code, ShouldEqual, http.StatusOK)
+		So(resp.Data.Bytes, ShouldEqual, len(body))
+		So(resp.Data.Accepted, ShouldEqual, 2)
+		So(resp.Data.Rejected, ShouldEqual, 1)
+		So(resp.Data.Reasons, ShouldResemble, map[string]int{decoder.RejectCrc: 1})
+	})
+
+	Convey("postSensorFrames multipart", t, func() {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		part, _ := w.CreateFormFile("frames", "1.bin")
+		part.Write(testArcFrame(1, start.Add(time.Minute), []byte{4}))
+		part, _ = w.CreateFormFile("frames", "2.bin")
+		part.Write(append(append([]byte{}, corrupt...), testArcFrame(2, start.Add(time.Minute), []byte{5})...))
+		w.Close()
+
+		code, resp := postFrames(context.Background(), arc, w.FormDataContentType(), body.Bytes())
+		So(code, ShouldEqual, http.StatusOK)
+		So(resp.Data.Accepted, ShouldEqual, 2)
+		So(resp.Data.Rejected, ShouldEqual, 1)
+		So(resp.Data.Reasons, ShouldResemble, map[string]int{decoder.RejectCrc: 1})
+	})
+
+	Convey("postSensorFrames cancelled", t, func() {
+		// nobody reads the decode queues
+		stalled := &ArcStorage{
+			config:         arc.config,
+			logger:         arc.logger,
+			exportMetrics:  arc.exportMetrics,
+			decodeJobChans: []chan decodeJob{make(chan decodeJob), make(chan decodeJob)},
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		code, resp := postFrames(ctx, stalled, echo.MIMEOctetStream, testArcFrame(1, start, []byte{1}))
+		So(code, ShouldEqual, http.StatusServiceUnavailable)
+		So(resp.Data.Accepted, ShouldEqual, 0)
+	})
+}
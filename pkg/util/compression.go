@@ -103,6 +103,7 @@ func UnGzipData(input []byte, logger logging.ILogger) ([]byte, error) {
 	r, err := gzip.NewReader(buf)
 	if err != nil {
 		logger.Debug("NewReader:", err)
+		return nil, err
 	}
 	defer r.Close()
 
@@ -107,15 +107,15 @@ func GetFileList(path string, arcfiles map[string][]string, key string) {
 	}
 }
 
-// GetBigFileLists -
-func GetBigFileLists(path string, arcfiles map[string]string, key string) error {
+// GetBigFileLists 目录下后缀为ext的数据卷, map[createtime]fullpath
+func GetBigFileLists(path string, arcfiles map[string]string, ext string) error {
 	fs, err := ioutil.ReadDir(path)
 	if err != nil {
 		return err
 	}
 	for _, file := range fs {
-		if strings.Contains(file.Name(), ".arc") {
-			start, _, _,  err := GetTimeRangeFromFileName(file.Name())
+		if strings.HasSuffix(file.Name(), ext) {
+			start, _, _, err := GetTimeRangeFromFileName(file.Name())
 			if err != nil {
 				return err
 			}
@@ -150,6 +150,23 @@ func SeekBigFileHeader(filename string, start, size int64) ([]byte, error) {
 	return b[:n], nil
 }
 
+// ReadFileRange read size bytes from start of file
+func ReadFileRange(filename string, start, size int64) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return []byte{}, fmt.Errorf("file open failed. err: " + err.Error())
+	}
+	defer f.Close()
+
+	b := make([]byte, size)
+	n, err := f.ReadAt(b, start)
+	if err != nil && err != io.EOF {
+		return []byte{}, fmt.Errorf("f.ReadAt: " + err.Error())
+	}
+
+	return b[:n], nil
+}
+
 // GetFileListName -
 func GetFileListName(path string, arcfiles map[string]string, key string) error {
 	fs, _ := ioutil.ReadDir(path)
@@ -162,7 +179,7 @@ func GetFileListName(path string, arcfiles map[string]string, key string) error
 			}
 		} else {
 			if len(file.Name()) > 34 { //20211028080000000460
-				start, _, _,  err := GetTimeRangeFromFileName(file.Name())
+				start, _, _, err := GetTimeRangeFromFileName(file.Name())
 				if err != nil {
 					return err
 				}
@@ -180,7 +197,7 @@ func GetFileListNamebyCreateTime(path string, key string) (string, error) {
 	fs, _ := ioutil.ReadDir(path)
 	for _, file := range fs {
 		if !file.IsDir() {
-			start, _, _,  err := GetTimeRangeFromFileName(file.Name())
+			start, _, _, err := GetTimeRangeFromFileName(file.Name())
 			if err != nil {
 				return "", err
 			}
@@ -220,19 +237,36 @@ func GetTimeRangeFromFileName(filename string) (start, end time.Time, sensorid s
 	basename := path.Base(filename)
 	strSlice := strings.Split(basename, "_")
 	l := len(strSlice)
+	// <sensorid>_<type>_<start>_<end>.arc
+	if l == 4 {
+		endstr := strings.TrimSuffix(strSlice[3], path.Ext(strSlice[3]))
+		if len(strSlice[2]) == 20 && len(endstr) == 20 {
+			startstr := strSlice[2][:14] + "." + strSlice[2][14:]
+			startdate, err := time.Parse("20060102150405.999999", startstr)
+			if err != nil || startdate.Unix() < 0 {
+				return time.Now(), time.Now(), "", err
+			}
+			endstr = endstr[:14] + "." + endstr[14:]
+			enddate, err := time.Parse("20060102150405.999999", endstr)
+			if err != nil || enddate.Unix() < 0 {
+				return time.Now(), time.Now(), "", err
+			}
+			return startdate, enddate, strSlice[0], nil
+		}
+	}
 	if l == 6 {
 		if len(strSlice[0]) == 20 && len(strSlice[1]) == 20 {
 			startstr := strSlice[0][:14] + "." + strSlice[0][14:]
 			startdate, err := time.Parse("20060102150405.999999", startstr)
 			if err != nil || startdate.Unix() < 0 {
-				return time.Now(), time.Now(), "",   err
+				return time.Now(), time.Now(), "", err
 			}
 			endstr := strSlice[1][:14] + "." + strSlice[1][14:]
 			enddate, err := time.Parse("20060102150405.999999", endstr)
 			if err != nil || enddate.Unix() < 0 {
-				return time.Now(), time.Now(), "",   err
+				return time.Now(), time.Now(), "", err
 			}
-			return startdate, enddate, "",  nil
+			return startdate, enddate, "", nil
 		}
 	}
 	if l == 9 {
@@ -240,14 +274,14 @@ func GetTimeRangeFromFileName(filename string) (start, end time.Time, sensorid s
 			startstr := strSlice[2][:14] + "." + strSlice[2][14:]
 			startdate, err := time.Parse("20060102150405.999999", startstr)
 			if err != nil || startdate.Unix() < 0 {
-				return time.Now(), time.Now(), "",  err
+				return time.Now(), time.Now(), "", err
 			}
 			endstr := strSlice[3][:14] + "." + strSlice[3][14:]
 			enddate, err := time.Parse("20060102150405.999999", endstr)
 			if err != nil || enddate.Unix() < 0 {
-				return time.Now(), time.Now(), "",  err
+				return time.Now(), time.Now(), "", err
 			}
-			return startdate, enddate, "",  nil
+			return startdate, enddate, "", nil
 		}
 	}
 	if l == 10 {
@@ -255,15 +289,15 @@ func GetTimeRangeFromFileName(filename string) (start, end time.Time, sensorid s
 			startstr := strSlice[2][:14] + "." + strSlice[2][14:]
 			startdate, err := time.Parse("20060102150405.999999", startstr)
 			if err != nil || startdate.Unix() < 0 {
-				return time.Now(), time.Now(), "",  err
+				return time.Now(), time.Now(), "", err
 			}
 			endstr := strSlice[3][:14] + "." + strSlice[3][14:]
 			enddate, err := time.Parse("20060102150405.999999", endstr)
 			if err != nil || enddate.Unix() < 0 {
 				return time.Now(), time.Now(), "", err
 			}
-			return startdate, enddate, strSlice[0],   nil
+			return startdate, enddate, strSlice[0], nil
 		}
 	}
-	return time.Now(), time.Now(), "",  errors.New("get time range failed")
+	return time.Now(), time.Now(), "", errors.New("get time range failed")
 }
@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"go.uber.org/zap"
@@ -125,10 +126,56 @@ func CaseGetFileList(t *testing.T) {
 	})
 }
 
+func CaseGetTimeRangeFromFileName(t *testing.T) {
+	Convey("GetTimeRangeFromFileName", t, func() {
+		Convey("volume", func() {
+			start, end, sensorid, err := GetTimeRangeFromFileName("/data/A00000000000/20240102/TypeArc/A00000000000_Arc_20240102030405000001_20240102030905500000.arc")
+			So(err, ShouldBeNil)
+			So(sensorid, ShouldEqual, "A00000000000")
+			So(start.Equal(time.Date(2024, 1, 2, 3, 4, 5, 1000, time.UTC)), ShouldBeTrue)
+			So(end.Equal(time.Date(2024, 1, 2, 3, 9, 5, 500000000, time.UTC)), ShouldBeTrue)
+		})
+		Convey("invalid", func() {
+			_, _, _, err := GetTimeRangeFromFileName("A00000000000_Arc_2024_2024.arc")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func CaseReadFileRange(t *testing.T) {
+	dir, err := ioutil.TempDir("./", "tmp")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := dir + "/range.arc"
+	if err := ioutil.WriteFile(filename, []byte{0, 1, 2, 3, 4, 5, 6, 7}, 0644); err != nil {
+		t.Fatalf("ioutil.WriteFile %v", err)
+	}
 
+	Convey("ReadFileRange", t, func() {
+		Convey("middle", func() {
+			data, err := ReadFileRange(filename, 2, 3)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte{2, 3, 4})
+		})
+		Convey("beyond end", func() {
+			data, err := ReadFileRange(filename, 6, 10)
+			So(err, ShouldBeNil)
+			So(data, ShouldResemble, []byte{6, 7})
+		})
+		Convey("not exist", func() {
+			_, err := ReadFileRange(filename+"1", 0, 1)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
 
 func TestFileUtil(t *testing.T) {
 	CaseTestFolderWritable(t)
 	CaseRemoveFolders(t)
 	CaseGetFileList(t)
+	CaseGetTimeRangeFromFileName(t)
+	CaseReadFileRange(t)
 }
@@ -127,19 +127,25 @@ func TimeStringReplace(timer time.Time) string {
 	return timestr
 }
 
-// IsZeroTime 时间格式化: 零点，整小时，整分
+// IsZeroTime timestamp2 是否已到达 timestamp 所在区间的结束边界(零点，整小时，整分), 区间同 NextBoundary
 func IsZeroTime(timestamp, timestamp2 time.Time, duration string, num int) bool {
-	if timestamp.Unix() < 0 {
-		return timestamp.Format("200601021504") == timestamp2.Format("200601021504")
+	return !timestamp2.Before(NextBoundary(timestamp, duration, num))
+}
+
+// NextBoundary 时间所在区间的结束边界(UTC), 区间按 num 个 duration 对齐: 零点起每num分钟、每num小时, 或自1970-01-01起每num天
+func NextBoundary(timestamp time.Time, duration string, num int) time.Time {
+	if num < 1 {
+		num = 1
 	}
+	t := timestamp.UTC()
 	switch duration {
 	case "day":
-		return time.Date(timestamp.Year(), timestamp.Month(), timestamp.Day()+num, 0, 0, 0, 0, timestamp.Location()).Format("20060102") == timestamp2.Format("20060102")
+		days := t.Unix() / 86400
+		return time.Unix((days-days%int64(num)+int64(num))*86400, 0).UTC()
 	case "hour":
-		return time.Date(timestamp.Year(), timestamp.Month(), timestamp.Day(), timestamp.Hour()+num, 0, 0, 0, timestamp.Location()).Format("2006010215") == timestamp2.Format("2006010215")
-	case "min":
-		return time.Date(timestamp.Year(), timestamp.Month(), timestamp.Day(), timestamp.Hour(), timestamp.Minute()+num, 0, 0, timestamp.Location()).Format("200601021504") == timestamp2.Format("200601021504")
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()-t.Hour()%num+num, 0, 0, 0, time.UTC)
 	default:
-		return timestamp.Format("200601021504") == timestamp2.Format("200601021504")
+		minutes := t.Hour()*60 + t.Minute()
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, minutes-minutes%num+num, 0, 0, time.UTC)
 	}
 }
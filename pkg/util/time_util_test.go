@@ -3,6 +3,7 @@ package util
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func CaseGetBetweenDates(t *testing.T) {
@@ -112,7 +113,38 @@ func CaseGetHoueDiffer(t *testing.T) {
 		})
 	}
 }
+
+func CaseNextBoundary(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 13, 47, 5, 0, time.UTC)
+	tests := []struct {
+		duration string
+		num      int
+		want     time.Time
+	}{
+		{"min", 1, time.Date(2024, 1, 2, 13, 48, 0, 0, time.UTC)},
+		{"min", 15, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)},
+		{"hour", 1, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)},
+		{"hour", 12, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"day", 1, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"day", 3, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"day", 7, time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"hour", 0, time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.duration, func(t *testing.T) {
+			if got := NextBoundary(ts, tt.duration, tt.num); !got.Equal(tt.want) {
+				t.Errorf("NextBoundary got:%v want:%v", got, tt.want)
+			}
+			if IsZeroTime(ts, tt.want.Add(-time.Nanosecond), tt.duration, tt.num) || !IsZeroTime(ts, tt.want, tt.duration, tt.num) {
+				t.Errorf("IsZeroTime boundary:%v", tt.want)
+			}
+		})
+	}
+}
+
 func TestTimeUtil(t *testing.T) {
 	CaseGetBetweenDates(t)
 	CaseGetHoueDiffer(t)
+	CaseNextBoundary(t)
 }
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"time"
+
+	"github.com/kiga-hub/arc-storage/pkg/arc_volume"
+)
+
+// openWAL 为每个工作协程创建预写日志, 并将上次退出时未落盘的数据回放到数据卷.
+// 回放的记录先写入新的日志, 再删除旧的段文件, 回放过程中崩溃不会丢失数据.
+func (arc *ArcStorage) openWAL() error {
+	if arc.config.WAL == nil || !arc.config.WAL.Enable {
+		return nil
+	}
+	dir := arc.config.WAL.Dir
+	segments, err := arc_volume.ListWALSegments(dir)
+	if err != nil {
+		return err
+	}
+
+	arc.wals = make([]*arc_volume.WAL, arc.config.Work.WorkCount)
+	for i := range arc.wals {
+		arc.wals[i], err = arc_volume.OpenWAL(dir, i, arc.config.WAL.SegmentSize, time.Duration(arc.config.WAL.SyncInterval)*time.Millisecond)
+		if err != nil {
+			return err
+		}
+	}
+
+	count := 0
+	err = arc_volume.ReplayWAL(segments, func(r *arc_volume.WALRecord) error {
+		if err := arc.walOf(r.Key).Append(r); err != nil {
+			return err
+		}
+		arc.replayFrame(r)
+		count++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, w := range arc.wals {
+		if err := w.Sync(); err != nil {
+			return err
+		}
+	}
+	arc.logger.Infow("replay wal", "dir", dir, "segments", len(segments), "records", count)
+
+	return arc_volume.RemoveWALSegments(segments)
+}
+
+// replayFrame 回放的帧按日志顺序追加到传感器的数据卷, 超时后排序落盘
+func (arc *ArcStorage) replayFrame(r *arc_volume.WALRecord) {
+	afi := arc.loadVolume(r.Key, r.SensorID, r.Type, r.Timestamp, len(r.Data))
+	if r.Timestamp.After(afi.SaveTime) {
+		afi.SaveTime = r.Timestamp.UTC()
+		afi.LastTimestamp = r.Timestamp
+	}
+	afi.Append(r.Timestamp, r.Data)
+	arc.timeoutSyncMap.Store(r.Key, time.Now().UTC())
+}
+
+// walOf 传感器所在工作协程的预写日志
+func (arc *ArcStorage) walOf(key uint64) *arc_volume.WAL {
+	if len(arc.wals) == 0 {
+		return nil
+	}
+	return arc.wals[key&uint64(arc.config.Work.WorkCount-1)]
+}
+
+// walAppend 帧数据进入内存数据卷前写入预写日志, 返回记录所在的段. 写入失败时帧只在内存中
+func (arc *ArcStorage) walAppend(key uint64, sensorID, fileType string, t time.Time, data []byte) (uint64, error) {
+	w := arc.walOf(key)
+	if w == nil {
+		return 0, nil
+	}
+	if err := w.Append(&arc_volume.WALRecord{
+		Key:       key,
+		SensorID:  sensorID,
+		Type:      fileType,
+		Timestamp: t,
+		Data:      data,
+	}); err != nil {
+		arc.logger.Errorw("walAppend", "id", sensorID, "err", err)
+		return w.Seq(), err
+	}
+	return w.Seq(), nil
+}
+
+// walCheckpoint 数据卷落盘后截断预写日志
+func (arc *ArcStorage) walCheckpoint(key uint64, keepLast bool) {
+	w := arc.walOf(key)
+	if w == nil {
+		return
+	}
+	if err := w.Checkpoint(key, keepLast); err != nil {
+		arc.logger.Errorw("walCheckpoint", "key", key, "err", err)
+	}
+}
+
+// walCheckpointAt 数据卷切换后截断预写日志, seq之后的段仍有未落盘的数据
+func (arc *ArcStorage) walCheckpointAt(key, seq uint64) {
+	w := arc.walOf(key)
+	if w == nil {
+		return
+	}
+	if err := w.CheckpointAt(key, seq); err != nil {
+		arc.logger.Errorw("walCheckpointAt", "key", key, "err", err)
+	}
+}
+
+// closeWAL -
+func (arc *ArcStorage) closeWAL() {
+	for _, w := range arc.wals {
+		if err := w.Close(); err != nil {
+			arc.logger.Errorw("closeWAL", "err", err)
+		}
+	}
+}
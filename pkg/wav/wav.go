@@ -0,0 +1,70 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+)
+
+// HeaderSize RIFF/WAVE PCM 文件头大小
+const HeaderSize = 44
+
+// formatPCM WAVE_FORMAT_PCM
+const formatPCM = 1
+
+var (
+	// ErrInvalidProfile 采样参数无效
+	ErrInvalidProfile = errors.New("wav: invalid sensor profile")
+	// ErrTooLarge 数据超过RIFF 4GB限制
+	ErrTooLarge = errors.New("wav: data exceeds 4GB")
+)
+
+// Header 生成PCM格式的RIFF/WAVE文件头, dataSize为data块字节数
+func Header(profile config.SensorProfile, dataSize int64) ([]byte, error) {
+	blockAlign := profile.BlockAlign()
+	if profile.SampleRate <= 0 || blockAlign <= 0 {
+		return nil, ErrInvalidProfile
+	}
+	if dataSize < 0 || dataSize > math.MaxUint32-HeaderSize+8 {
+		return nil, ErrTooLarge
+	}
+
+	h := make([]byte, HeaderSize)
+	copy(h[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:8], uint32(HeaderSize-8+dataSize))
+	copy(h[8:12], "WAVE")
+	copy(h[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(h[16:20], 16)
+	binary.LittleEndian.PutUint16(h[20:22], formatPCM)
+	binary.LittleEndian.PutUint16(h[22:24], uint16(profile.Channel))
+	binary.LittleEndian.PutUint32(h[24:28], uint32(profile.SampleRate))
+	binary.LittleEndian.PutUint32(h[28:32], uint32(profile.BytesPerSecond()))
+	binary.LittleEndian.PutUint16(h[32:34], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(h[34:36], uint16(profile.SampleWidth*8))
+	copy(h[36:40], "data")
+	binary.LittleEndian.PutUint32(h[40:44], uint32(dataSize))
+	return h, nil
+}
+
+// Write 将PCM数据封装为WAV写入w, 末尾不完整的采样点被丢弃. 返回写入字节数
+func Write(w io.Writer, profile config.SensorProfile, data []byte) (int64, error) {
+	blockAlign := profile.BlockAlign()
+	if blockAlign <= 0 {
+		return 0, ErrInvalidProfile
+	}
+	data = data[:len(data)-len(data)%blockAlign]
+
+	h, err := Header(profile, int64(len(data)))
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(h)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(data)
+	return int64(n + m), err
+}
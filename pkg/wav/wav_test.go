@@ -0,0 +1,49 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/kiga-hub/arc-storage/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWav(t *testing.T) {
+	CaseHeader(t)
+	CaseWrite(t)
+}
+
+func CaseHeader(t *testing.T) {
+	Convey("Header", t, func() {
+		profile := config.SensorProfile{SampleRate: 8000, Channel: 2, SampleWidth: 2}
+		h, err := Header(profile, 1000)
+		So(err, ShouldBeNil)
+		So(len(h), ShouldEqual, HeaderSize)
+		So(string(h[0:4]), ShouldEqual, "RIFF")
+		So(binary.LittleEndian.Uint32(h[4:8]), ShouldEqual, 1036)
+		So(string(h[8:16]), ShouldEqual, "WAVEfmt ")
+		So(binary.LittleEndian.Uint16(h[22:24]), ShouldEqual, 2)
+		So(binary.LittleEndian.Uint32(h[24:28]), ShouldEqual, 8000)
+		So(binary.LittleEndian.Uint32(h[28:32]), ShouldEqual, 32000)
+		So(binary.LittleEndian.Uint16(h[32:34]), ShouldEqual, 4)
+		So(binary.LittleEndian.Uint16(h[34:36]), ShouldEqual, 16)
+		So(string(h[36:40]), ShouldEqual, "data")
+		So(binary.LittleEndian.Uint32(h[40:44]), ShouldEqual, 1000)
+
+		_, err = Header(config.SensorProfile{}, 1000)
+		So(err, ShouldEqual, ErrInvalidProfile)
+	})
+}
+
+func CaseWrite(t *testing.T) {
+	Convey("Write", t, func() {
+		profile := config.SensorProfile{SampleRate: 8000, Channel: 1, SampleWidth: 2}
+		buf := &bytes.Buffer{}
+		n, err := Write(buf, profile, []byte{1, 2, 3, 4, 5})
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, HeaderSize+4)
+		So(buf.Bytes()[HeaderSize:], ShouldResemble, []byte{1, 2, 3, 4})
+		So(binary.LittleEndian.Uint32(buf.Bytes()[40:44]), ShouldEqual, 4)
+	})
+}